@@ -0,0 +1,155 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	models "github.com/madfelps/challenge-nuitee/internal/data"
+	"github.com/madfelps/challenge-nuitee/internal/validator"
+)
+
+const activationTokenTTL = 3 * 24 * time.Hour
+
+type ActivateUserRequest struct {
+	Token string `json:"token"`
+}
+
+type CreateActivationTokenRequest struct {
+	Email string `json:"email"`
+}
+
+// updateUserHandler serves PUT /v1/users/:id. httprouter does not allow a
+// static segment next to a named parameter, so PUT /v1/users/activated and
+// PUT /v1/users/password land here and are dispatched by hand.
+func (app *application) updateUserHandler(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	switch params.ByName("id") {
+	case "activated":
+		app.activateUserHandler(w, r)
+	case "password":
+		app.resetPasswordHandler(w, r)
+	default:
+		app.notFoundResponse(w, r)
+	}
+}
+
+// sendActivationEmail creates a one-time activation token for user and
+// emails it in the background.
+func (app *application) sendActivationEmail(user *models.User) error {
+	token, err := app.models.Tokens.New(user.ID, activationTokenTTL, models.ScopeActivation)
+	if err != nil {
+		return err
+	}
+
+	app.background(func() {
+		data := map[string]interface{}{
+			"name":            user.Name,
+			"userID":          user.ID,
+			"activationToken": token.Plaintext,
+			"expiresIn":       "3 days",
+		}
+
+		err := app.mailer.Send(user.Email, "user_welcome.tmpl", data)
+		if err != nil {
+			app.logger.PrintError(err, map[string]string{"template": "user_welcome.tmpl"})
+		}
+	})
+
+	return nil
+}
+
+func (app *application) activateUserHandler(w http.ResponseWriter, r *http.Request) {
+	var req ActivateUserRequest
+
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		app.errorResponse(w, r, http.StatusBadRequest, "invalid JSON")
+		return
+	}
+
+	v := validator.New()
+	ValidateTokenPlaintext(v, "token", req.Token)
+
+	if !v.Valid() {
+		app.errorResponse(w, r, http.StatusUnprocessableEntity, v.Errors)
+		return
+	}
+
+	user, err := app.models.Users.GetForToken(models.ScopeActivation, req.Token)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrRecordNotFound):
+			app.errorResponse(w, r, http.StatusUnprocessableEntity, map[string]string{"token": "invalid or expired activation token"})
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.models.Users.Activate(user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	user.Activated = true
+
+	err = app.models.Tokens.DeleteAllForUser(models.ScopeActivation, user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"data": map[string]interface{}{"user": userFromModel(*user)}}, nil)
+	if err != nil {
+		app.logError(r, err)
+		app.errorResponse(w, r, http.StatusInternalServerError, "failed to encode response")
+		return
+	}
+}
+
+// createActivationTokenHandler sends a new activation email. It answers the
+// same way whether or not the address belongs to an account awaiting
+// activation, so it cannot be used to discover registered emails.
+func (app *application) createActivationTokenHandler(w http.ResponseWriter, r *http.Request) {
+	var req CreateActivationTokenRequest
+
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		app.errorResponse(w, r, http.StatusBadRequest, "invalid JSON")
+		return
+	}
+
+	v := validator.New()
+	ValidateEmail(v, req.Email)
+
+	if !v.Valid() {
+		app.errorResponse(w, r, http.StatusUnprocessableEntity, v.Errors)
+		return
+	}
+
+	user, err := app.models.Users.GetByEmail(req.Email)
+	switch {
+	case errors.Is(err, models.ErrRecordNotFound):
+	case err != nil:
+		app.serverErrorResponse(w, r, err)
+		return
+	case !user.Activated:
+		if err := app.sendActivationEmail(user); err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	message := "if the address belongs to an account awaiting activation, an email will be sent to it containing activation instructions"
+
+	err = app.writeJSON(w, http.StatusAccepted, envelope{"message": message}, nil)
+	if err != nil {
+		app.logError(r, err)
+		app.errorResponse(w, r, http.StatusInternalServerError, "failed to encode response")
+		return
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+func TestUpdateUserHandler(t *testing.T) {
+	app := &application{}
+
+	tests := []struct {
+		name     string
+		id       string
+		body     string
+		expected int
+	}{
+		{name: "unknown action", id: "42", body: `{}`, expected: http.StatusNotFound},
+		{name: "invalid JSON", id: "activated", body: `{`, expected: http.StatusBadRequest},
+		{name: "missing token", id: "activated", body: `{}`, expected: http.StatusUnprocessableEntity},
+		{name: "short token", id: "activated", body: `{"token": "ABC"}`, expected: http.StatusUnprocessableEntity},
+		{name: "reset with short password", id: "password", body: `{"password": "short", "token": "` + strings.Repeat("A", 52) + `"}`, expected: http.StatusUnprocessableEntity},
+		{name: "reset without token", id: "password", body: `{"password": "long enough"}`, expected: http.StatusUnprocessableEntity},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := httprouter.New()
+			router.HandlerFunc(http.MethodPut, "/v1/users/:id", app.updateUserHandler)
+
+			r := httptest.NewRequest(http.MethodPut, "/v1/users/"+tt.id, strings.NewReader(tt.body))
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, r)
+
+			if w.Code != tt.expected {
+				t.Errorf("status = %d, expected %d", w.Code, tt.expected)
+			}
+		})
+	}
+}
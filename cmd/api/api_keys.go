@@ -0,0 +1,122 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	models "github.com/madfelps/challenge-nuitee/internal/data"
+	"github.com/madfelps/challenge-nuitee/internal/validator"
+)
+
+type CreateAPIKeyRequest struct {
+	Name      string     `json:"name"`
+	Scopes    []string   `json:"scopes"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// createAPIKeyHandler creates a personal API key. The key is only returned
+// in this response. Keys created without scopes get read and write access.
+func (app *application) createAPIKeyHandler(w http.ResponseWriter, r *http.Request) {
+	var req CreateAPIKeyRequest
+
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		app.errorResponse(w, r, http.StatusBadRequest, "invalid JSON")
+		return
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	if len(req.Scopes) == 0 {
+		req.Scopes = models.APIKeyScopes
+	}
+
+	v := validator.New()
+	ValidateAPIKey(v, &req, time.Now())
+
+	if !v.Valid() {
+		app.errorResponse(w, r, http.StatusUnprocessableEntity, v.Errors)
+		return
+	}
+
+	key, err := app.models.APIKeys.New(app.contextGetUser(r).ID, req.Name, req.Scopes, req.ExpiresAt)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"data": map[string]interface{}{"api_key": key}}, nil)
+	if err != nil {
+		app.logError(r, err)
+		app.errorResponse(w, r, http.StatusInternalServerError, "failed to encode response")
+		return
+	}
+}
+
+func (app *application) listAPIKeysHandler(w http.ResponseWriter, r *http.Request) {
+	keys, err := app.models.APIKeys.ListForUser(app.contextGetUser(r).ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"data": map[string]interface{}{"api_keys": keys}}, nil)
+	if err != nil {
+		app.logError(r, err)
+		app.errorResponse(w, r, http.StatusInternalServerError, "failed to encode response")
+		return
+	}
+}
+
+func (app *application) deleteAPIKeyHandler(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	keyID, err := strconv.Atoi(params.ByName("api_key_id"))
+	if err != nil || keyID <= 0 {
+		app.errorResponse(w, r, http.StatusBadRequest, "invalid api_key_id parameter")
+		return
+	}
+
+	err = app.models.APIKeys.DeleteForUser(keyID, app.contextGetUser(r).ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "api key successfully revoked"}, nil)
+	if err != nil {
+		app.logError(r, err)
+		app.errorResponse(w, r, http.StatusInternalServerError, "failed to encode response")
+		return
+	}
+}
+
+func ValidateAPIKey(v *validator.Validator, req *CreateAPIKeyRequest, now time.Time) {
+	v.Check(req.Name != "", "name", "must be provided")
+	v.Check(len(req.Name) <= 100, "name", "must not be more than 100 characters long")
+
+	for i, scope := range req.Scopes {
+		if !slices.Contains(models.APIKeyScopes, scope) {
+			v.AddError("scopes", "must only contain read or write")
+			break
+		}
+		if slices.Contains(req.Scopes[:i], scope) {
+			v.AddError("scopes", "must not contain duplicate values")
+			break
+		}
+	}
+
+	if req.ExpiresAt != nil {
+		v.Check(req.ExpiresAt.After(now), "expires_at", "must be in the future")
+	}
+}
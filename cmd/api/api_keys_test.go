@@ -0,0 +1,66 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	models "github.com/madfelps/challenge-nuitee/internal/data"
+	"github.com/madfelps/challenge-nuitee/internal/validator"
+)
+
+func TestValidateAPIKey(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	future := now.Add(24 * time.Hour)
+	past := now.Add(-time.Hour)
+
+	tests := []struct {
+		name     string
+		req      CreateAPIKeyRequest
+		expected bool
+	}{
+		{name: "read and write", req: CreateAPIKeyRequest{Name: "nightly sync", Scopes: []string{"read", "write"}}, expected: true},
+		{name: "read only with expiry", req: CreateAPIKeyRequest{Name: "report", Scopes: []string{"read"}, ExpiresAt: &future}, expected: true},
+		{name: "missing name", req: CreateAPIKeyRequest{Scopes: []string{"read"}}, expected: false},
+		{name: "unknown scope", req: CreateAPIKeyRequest{Name: "admin", Scopes: []string{"admin"}}, expected: false},
+		{name: "duplicate scope", req: CreateAPIKeyRequest{Name: "dup", Scopes: []string{"read", "read"}}, expected: false},
+		{name: "expiry in the past", req: CreateAPIKeyRequest{Name: "old", Scopes: []string{"read"}, ExpiresAt: &past}, expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := validator.New()
+			ValidateAPIKey(v, &tt.req, now)
+
+			if v.Valid() != tt.expected {
+				t.Errorf("ValidateAPIKey() = %v, expected %v. Errors: %v", v.Valid(), tt.expected, v.Errors)
+			}
+		})
+	}
+}
+
+func TestAPIKeyAllows(t *testing.T) {
+	tests := []struct {
+		name     string
+		scopes   []string
+		method   string
+		expected bool
+	}{
+		{name: "read key reads", scopes: []string{"read"}, method: http.MethodGet, expected: true},
+		{name: "read key writes", scopes: []string{"read"}, method: http.MethodPost, expected: false},
+		{name: "read key deletes", scopes: []string{"read"}, method: http.MethodDelete, expected: false},
+		{name: "write key writes", scopes: []string{"write"}, method: http.MethodPatch, expected: true},
+		{name: "read and write key", scopes: []string{"read", "write"}, method: http.MethodDelete, expected: true},
+		{name: "no scopes", scopes: nil, method: http.MethodGet, expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key := &models.APIKey{Scopes: tt.scopes}
+
+			if got := key.Allows(tt.method); got != tt.expected {
+				t.Errorf("Allows(%s) = %v, expected %v", tt.method, got, tt.expected)
+			}
+		})
+	}
+}
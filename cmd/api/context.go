@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"net/http"
+
+	models "github.com/madfelps/challenge-nuitee/internal/data"
+)
+
+type contextKey string
+
+const (
+	tenantContextKey = contextKey("tenant")
+	userContextKey   = contextKey("user")
+	apiKeyContextKey = contextKey("apiKey")
+	memberContextKey = contextKey("member")
+)
+
+// tenant is the API client a request was authenticated as. liteAPIKey is the
+// tenant's decrypted LiteAPI key, empty when it uses the server's key.
+type tenant struct {
+	ID         int
+	Name       string
+	liteAPIKey string
+}
+
+func (app *application) contextSetTenant(r *http.Request, t *tenant) *http.Request {
+	ctx := context.WithValue(r.Context(), tenantContextKey, t)
+	return r.WithContext(ctx)
+}
+
+// contextGetTenant returns the tenant for r, or nil for anonymous requests.
+func (app *application) contextGetTenant(r *http.Request) *tenant {
+	t, _ := r.Context().Value(tenantContextKey).(*tenant)
+	return t
+}
+
+func (app *application) contextSetUser(r *http.Request, user *models.User) *http.Request {
+	ctx := context.WithValue(r.Context(), userContextKey, user)
+	return r.WithContext(ctx)
+}
+
+// contextGetUser returns the user for r. It must only be called after the
+// authenticate middleware ran, which always stores a user, possibly
+// models.AnonymousUser.
+func (app *application) contextGetUser(r *http.Request) *models.User {
+	user, ok := r.Context().Value(userContextKey).(*models.User)
+	if !ok {
+		panic("missing user value in request context")
+	}
+	return user
+}
+
+func (app *application) contextSetAPIKey(r *http.Request, key *models.APIKey) *http.Request {
+	ctx := context.WithValue(r.Context(), apiKeyContextKey, key)
+	return r.WithContext(ctx)
+}
+
+// contextGetAPIKey returns the personal API key r was authenticated with, or
+// nil when it was not authenticated with one.
+func (app *application) contextGetAPIKey(r *http.Request) *models.APIKey {
+	key, _ := r.Context().Value(apiKeyContextKey).(*models.APIKey)
+	return key
+}
+
+func (app *application) contextSetMember(r *http.Request, member *models.Member) *http.Request {
+	ctx := context.WithValue(r.Context(), memberContextKey, member)
+	return r.WithContext(ctx)
+}
+
+// contextGetMember returns the membership loaded by requireMember, or nil on
+// routes outside an organization.
+func (app *application) contextGetMember(r *http.Request) *models.Member {
+	member, _ := r.Context().Value(memberContextKey).(*models.Member)
+	return member
+}
@@ -2,8 +2,14 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
 	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/madfelps/challenge-nuitee/internal/upstream"
 )
 
 func (app *application) logError(r *http.Request, err error) {
@@ -44,6 +50,68 @@ func (app *application) rateLimitExceededResponse(w http.ResponseWriter, r *http
 	app.errorResponse(w, r, http.StatusTooManyRequests, message)
 }
 
+func (app *application) loginThrottledResponse(w http.ResponseWriter, r *http.Request, retryAfter time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+
+	message := "too many failed login attempts, try again later or reset your password"
+	app.errorResponse(w, r, http.StatusTooManyRequests, message)
+}
+
+func (app *application) invalidAPIKeyResponse(w http.ResponseWriter, r *http.Request) {
+	message := "invalid or unknown API key"
+	app.errorResponse(w, r, http.StatusUnauthorized, message)
+}
+
+func (app *application) invalidCredentialsResponse(w http.ResponseWriter, r *http.Request) {
+	message := "invalid authentication credentials"
+	app.errorResponse(w, r, http.StatusUnauthorized, message)
+}
+
+func (app *application) invalidAuthenticationTokenResponse(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("WWW-Authenticate", "Bearer")
+
+	message := "invalid or missing authentication token"
+	app.errorResponse(w, r, http.StatusUnauthorized, message)
+}
+
+func (app *application) authenticationRequiredResponse(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("WWW-Authenticate", "Bearer")
+
+	message := "you must be authenticated to access this resource"
+	app.errorResponse(w, r, http.StatusUnauthorized, message)
+}
+
+func (app *application) notPermittedResponse(w http.ResponseWriter, r *http.Request) {
+	message := "your user account doesn't have the necessary permissions to access this resource"
+	app.errorResponse(w, r, http.StatusForbidden, message)
+}
+
+func (app *application) upstreamUnavailableResponse(w http.ResponseWriter, r *http.Request) {
+	retryAfter := int(math.Ceil(app.upstream.Breaker.RetryAfter().Seconds()))
+	w.Header().Set("Retry-After", strconv.Itoa(max(1, retryAfter)))
+
+	message := "the hotel provider is temporarily unavailable, please try again later"
+	app.errorResponse(w, r, http.StatusServiceUnavailable, message)
+}
+
+// upstreamErrorResponse reports a failed LiteAPI call. An open circuit breaker
+// or an exhausted daily quota becomes a 503 so clients back off; anything
+// else is logged and answered with a 500 carrying message.
+func (app *application) upstreamErrorResponse(w http.ResponseWriter, r *http.Request, err error, message string) {
+	switch {
+	case errors.Is(err, upstream.ErrCircuitOpen):
+		app.upstreamUnavailableResponse(w, r)
+		return
+	case errors.Is(err, upstream.ErrQuotaExhausted):
+		app.logError(r, err)
+		app.errorResponse(w, r, http.StatusServiceUnavailable, "the hotel provider quota is exhausted for today, please try again later")
+		return
+	}
+
+	app.logError(r, err)
+	app.errorResponse(w, r, http.StatusInternalServerError, message)
+}
+
 func (app *application) writeJSON(w http.ResponseWriter, status int, data envelope, headers http.Header) error {
 	js, err := json.MarshalIndent(data, "", "\t")
 	if err != nil {
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	models "github.com/madfelps/challenge-nuitee/internal/data"
+)
+
+// UserExport is the archive returned for privacy requests: everything this
+// service stores about a user.
+type UserExport struct {
+	User              User                      `json:"user"`
+	Roles             []string                  `json:"roles"`
+	Preferences       *models.Preferences       `json:"preferences"`
+	Favorites         []HotelFavorite           `json:"favorites"`
+	Watchlists        []models.Watchlist        `json:"watchlists"`
+	PriceObservations []models.PriceObservation `json:"price_observations"`
+	Notifications     []models.Notification     `json:"notifications"`
+	APIKeys           []models.APIKey           `json:"api_keys"`
+	ExportedAt        time.Time                 `json:"exported_at"`
+}
+
+func (app *application) exportUserHandler(w http.ResponseWriter, r *http.Request) {
+	user, err := app.models.Users.Get(readUserIDParam(r))
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	export := UserExport{
+		User:       userFromModel(*user),
+		ExportedAt: time.Now().UTC(),
+	}
+
+	export.Roles, err = app.models.Roles.GetAllForUser(user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	export.Preferences, err = app.models.Preferences.Get(user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	favorites, err := app.models.Favorites.ListForUser(user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	export.Favorites = make([]HotelFavorite, len(favorites))
+	for i, favorite := range favorites {
+		export.Favorites[i] = hotelFavoriteFromModel(favorite)
+	}
+
+	export.Watchlists, err = app.models.Watchlists.List(models.FavoriteOwner{UserID: user.ID})
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	export.PriceObservations, err = app.models.PriceObservations.ListForUser(user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	export.Notifications, err = app.models.Notifications.ListForUser(user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	export.APIKeys, err = app.models.APIKeys.ListForUser(user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Content-Disposition", fmt.Sprintf(`attachment; filename="user-%d-export.json"`, user.ID))
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"data": export}, headers)
+	if err != nil {
+		app.logError(r, err)
+		app.errorResponse(w, r, http.StatusInternalServerError, "failed to encode response")
+		return
+	}
+}
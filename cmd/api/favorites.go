@@ -1,50 +1,89 @@
 package main
 
 import (
-	"database/sql"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/julienschmidt/httprouter"
+	models "github.com/madfelps/challenge-nuitee/internal/data"
+	"github.com/madfelps/challenge-nuitee/internal/fx"
+	"github.com/madfelps/challenge-nuitee/internal/validator"
 )
 
 type HotelFavorite struct {
-	ID          int       `json:"id"`
-	UserID      int       `json:"user_id"`
-	HotelID     string    `json:"hotel_id"`
-	TargetPrice float64   `json:"target_price"`
-	CreatedAt   time.Time `json:"created_at"`
+	ID             int                  `json:"id"`
+	UserID         int                  `json:"user_id"`
+	OrganizationID *int                 `json:"organization_id,omitempty"`
+	WatchlistID    *int                 `json:"watchlist_id"`
+	Status         string               `json:"status"`
+	HotelID        string               `json:"hotel_id"`
+	CheckIn        string               `json:"check_in,omitempty"`
+	CheckOut       string               `json:"check_out,omitempty"`
+	Adults         int                  `json:"adults"`
+	Children       []int64              `json:"children"`
+	EndDate        string               `json:"end_date,omitempty"`
+	TargetPrice    float64              `json:"target_price"`
+	Currency       string               `json:"currency"`
+	Criteria       models.WatchCriteria `json:"criteria"`
+	CreatedAt      time.Time            `json:"created_at"`
 }
 
+// CreateFavoriteRequest watches a hotel. CheckIn, CheckOut and EndDate are
+// YYYY-MM-DD dates; without check-in and check-out the favorite is priced
+// for the default stay of the monitor. It expires after EndDate.
 type CreateFavoriteRequest struct {
-	HotelID     string  `json:"hotel_id"`
-	TargetPrice float64 `json:"target_price"`
+	HotelID     string               `json:"hotel_id"`
+	WatchlistID *int                 `json:"watchlist_id"`
+	CheckIn     string               `json:"check_in"`
+	CheckOut    string               `json:"check_out"`
+	Adults      int                  `json:"adults"`
+	Children    []int64              `json:"children"`
+	EndDate     string               `json:"end_date"`
+	TargetPrice float64              `json:"target_price"`
+	Currency    string               `json:"currency"`
+	Criteria    models.WatchCriteria `json:"criteria"`
+}
+
+// UpdateFavoriteRequest changes a favorite. WatchlistID moves it to another
+// watchlist, or out of any watchlist when it is JSON null.
+type UpdateFavoriteRequest struct {
+	WatchlistID json.RawMessage `json:"watchlist_id"`
+	TargetPrice *float64        `json:"target_price"`
+	Currency    *string         `json:"currency"`
 }
 
 type CreateFavoriteResponse struct {
 	Favorite HotelFavorite `json:"favorite"`
 }
 
-func (app *application) createFavoriteHandler(w http.ResponseWriter, r *http.Request) {
-
-	params := httprouter.ParamsFromContext(r.Context())
-	userIDStr := params.ByName("user_id")
+// favoriteOwner returns whose favorites a request works on: the
+// organization on /v1/organizations/:org_id/favorites routes, otherwise the
+// authenticated user.
+func (app *application) favoriteOwner(r *http.Request) models.FavoriteOwner {
+	owner := models.FavoriteOwner{UserID: app.contextGetUser(r).ID}
 
-	if userIDStr == "" {
-		app.errorResponse(w, r, http.StatusBadRequest, "user_id parameter is required")
-		return
+	if member := app.contextGetMember(r); member != nil {
+		owner.OrganizationID = &member.OrganizationID
 	}
 
-	userID, err := strconv.Atoi(userIDStr)
-	if err != nil || userID <= 0 {
-		app.errorResponse(w, r, http.StatusBadRequest, "invalid user_id parameter")
-		return
-	}
+	return owner
+}
+
+// createFavoriteHandler adds a favorite for the authenticated user, or for
+// their organization. On /v1/favorites/:user_id the requireOwner middleware
+// has already checked that :user_id is that user.
+func (app *application) createFavoriteHandler(w http.ResponseWriter, r *http.Request) {
+	owner := app.favoriteOwner(r)
+	userID := owner.UserID
 
 	var req CreateFavoriteRequest
-	err = json.NewDecoder(r.Body).Decode(&req)
+	err := json.NewDecoder(r.Body).Decode(&req)
 	if err != nil {
 		app.errorResponse(w, r, http.StatusBadRequest, "invalid JSON")
 		return
@@ -60,69 +99,435 @@ func (app *application) createFavoriteHandler(w http.ResponseWriter, r *http.Req
 		return
 	}
 
-	db, err := sql.Open("postgres", app.config.db.dsn)
+	for i, boardType := range req.Criteria.BoardTypes {
+		req.Criteria.BoardTypes[i] = strings.ToUpper(strings.TrimSpace(boardType))
+	}
+
+	// Target prices default to the currency the user prefers.
+	req.Currency = strings.ToUpper(strings.TrimSpace(req.Currency))
+	if req.Currency == "" {
+		prefs, err := app.models.Preferences.Get(userID)
+		if err != nil {
+			app.logError(r, err)
+			app.errorResponse(w, r, http.StatusInternalServerError, "database error")
+			return
+		}
+		req.Currency = prefs.Currency
+	}
+
+	v := validator.New()
+	today := time.Now().UTC()
+	stay := stayFromRequest(v, req, today)
+	endDate := endDateFromRequest(v, req.EndDate, today)
+	app.validateFavoriteCurrency(v, req.Currency)
+	ValidateWatchCriteria(v, req.Criteria)
+
+	if !v.Valid() {
+		app.errorResponse(w, r, http.StatusUnprocessableEntity, v.Errors)
+		return
+	}
+
+	if !app.checkWatchlist(w, r, req.WatchlistID, owner) {
+		return
+	}
+
+	record := &models.Favorite{
+		UserID:         userID,
+		OrganizationID: owner.OrganizationID,
+		WatchlistID:    req.WatchlistID,
+		HotelID:        req.HotelID,
+		Stay:           stay,
+		EndDate:        endDate,
+		TargetPrice:    req.TargetPrice,
+		Currency:       req.Currency,
+		Criteria:       req.Criteria,
+	}
+
+	err = app.models.Favorites.Insert(record)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrDuplicateFavorite):
+			app.errorResponse(w, r, http.StatusConflict, "an identical watch of this hotel already exists")
+		default:
+			app.logError(r, err)
+			app.errorResponse(w, r, http.StatusInternalServerError, "failed to create favorite")
+		}
+		return
+	}
+
+	response := CreateFavoriteResponse{
+		Favorite: hotelFavoriteFromModel(*record),
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"data": response}, nil)
 	if err != nil {
 		app.logError(r, err)
-		app.errorResponse(w, r, http.StatusInternalServerError, "database connection failed")
+		app.errorResponse(w, r, http.StatusInternalServerError, "failed to encode response")
 		return
 	}
-	defer db.Close()
+}
 
-	var userExists bool
-	err = db.QueryRow("SELECT EXISTS(SELECT 1 FROM users WHERE id = $1)", userID).Scan(&userExists)
+// listFavoritesHandler lists the favorites of the authenticated user, or of
+// their organization.
+func (app *application) listFavoritesHandler(w http.ResponseWriter, r *http.Request) {
+	records, err := app.models.Favorites.List(app.favoriteOwner(r))
 	if err != nil {
 		app.logError(r, err)
 		app.errorResponse(w, r, http.StatusInternalServerError, "database error")
 		return
 	}
 
-	if !userExists {
-		app.errorResponse(w, r, http.StatusNotFound, "user not found")
+	favorites := make([]HotelFavorite, len(records))
+	for i, record := range records {
+		favorites[i] = hotelFavoriteFromModel(record)
+	}
+
+	response := map[string]interface{}{
+		"favorites": favorites,
+		"total":     len(favorites),
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"data": response}, nil)
+	if err != nil {
+		app.logError(r, err)
+		app.errorResponse(w, r, http.StatusInternalServerError, "failed to encode response")
+		return
+	}
+}
+
+// showFavoriteHandler returns one favorite of the authenticated user, or of
+// their organization. Favorites of others are reported as not found.
+func (app *application) showFavoriteHandler(w http.ResponseWriter, r *http.Request) {
+	favoriteID, ok := app.readFavoriteIDParam(w, r)
+	if !ok {
 		return
 	}
 
-	var favoriteExists bool
-	err = db.QueryRow("SELECT EXISTS(SELECT 1 FROM users_favorites WHERE user_id = $1 AND hotel_id = $2)", userID, req.HotelID).Scan(&favoriteExists)
+	record, err := app.models.Favorites.Get(favoriteID, app.favoriteOwner(r))
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.logError(r, err)
+			app.errorResponse(w, r, http.StatusInternalServerError, "database error")
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"data": map[string]interface{}{"favorite": hotelFavoriteFromModel(*record)}}, nil)
 	if err != nil {
 		app.logError(r, err)
-		app.errorResponse(w, r, http.StatusInternalServerError, "database error")
+		app.errorResponse(w, r, http.StatusInternalServerError, "failed to encode response")
 		return
 	}
+}
 
-	if favoriteExists {
-		app.errorResponse(w, r, http.StatusConflict, "hotel already in favorites")
+// updateFavoriteHandler changes the target price, currency or watchlist of
+// a favorite. Changing only the currency converts the target price into it.
+func (app *application) updateFavoriteHandler(w http.ResponseWriter, r *http.Request) {
+	favoriteID, ok := app.readFavoriteIDParam(w, r)
+	if !ok {
 		return
 	}
 
-	var favoriteID int
-	var createdAt time.Time
-	err = db.QueryRow(
-		"INSERT INTO users_favorites (user_id, hotel_id, target_price) VALUES ($1, $2, $3) RETURNING id, created_at",
-		userID, req.HotelID, req.TargetPrice,
-	).Scan(&favoriteID, &createdAt)
+	owner := app.favoriteOwner(r)
 
+	record, err := app.models.Favorites.Get(favoriteID, owner)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	var req UpdateFavoriteRequest
+
+	err = json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		app.errorResponse(w, r, http.StatusBadRequest, "invalid JSON")
+		return
+	}
+
+	if len(req.WatchlistID) > 0 {
+		var watchlistID *int
+		if err := json.Unmarshal(req.WatchlistID, &watchlistID); err != nil {
+			app.errorResponse(w, r, http.StatusBadRequest, "watchlist_id must be an integer or null")
+			return
+		}
+		if !app.checkWatchlist(w, r, watchlistID, owner) {
+			return
+		}
+		record.WatchlistID = watchlistID
+	}
+
+	v := validator.New()
+
+	if req.Currency != nil {
+		currency := strings.ToUpper(strings.TrimSpace(*req.Currency))
+		app.validateFavoriteCurrency(v, currency)
+
+		if v.Valid() && currency != record.Currency {
+			if err := app.convertFavoriteCurrency(record, currency); err != nil {
+				app.convertPriceResponse(w, r, err)
+				return
+			}
+		}
+		record.Currency = currency
+	}
+
+	if req.TargetPrice != nil {
+		record.TargetPrice = *req.TargetPrice
+		v.Check(record.TargetPrice > 0, "target_price", "must be greater than 0")
+	}
+
+	if !v.Valid() {
+		app.errorResponse(w, r, http.StatusUnprocessableEntity, v.Errors)
+		return
+	}
+
+	err = app.models.Favorites.Update(record)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrDuplicateFavorite):
+			app.errorResponse(w, r, http.StatusConflict, "an identical watch of this hotel already exists")
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"data": map[string]interface{}{"favorite": hotelFavoriteFromModel(*record)}}, nil)
 	if err != nil {
 		app.logError(r, err)
-		app.errorResponse(w, r, http.StatusInternalServerError, "failed to create favorite")
+		app.errorResponse(w, r, http.StatusInternalServerError, "failed to encode response")
 		return
 	}
+}
 
-	favorite := HotelFavorite{
-		ID:          favoriteID,
-		UserID:      userID,
-		HotelID:     req.HotelID,
-		TargetPrice: req.TargetPrice,
-		CreatedAt:   createdAt,
+// pauseFavoriteHandler stops monitoring a favorite until it is resumed.
+func (app *application) pauseFavoriteHandler(w http.ResponseWriter, r *http.Request) {
+	app.setFavoriteStatus(w, r, models.FavoriteStatusPaused)
+}
+
+// resumeFavoriteHandler monitors a paused or fulfilled favorite again.
+func (app *application) resumeFavoriteHandler(w http.ResponseWriter, r *http.Request) {
+	app.setFavoriteStatus(w, r, models.FavoriteStatusActive)
+}
+
+func (app *application) setFavoriteStatus(w http.ResponseWriter, r *http.Request, status string) {
+	favoriteID, ok := app.readFavoriteIDParam(w, r)
+	if !ok {
+		return
 	}
 
-	response := CreateFavoriteResponse{
-		Favorite: favorite,
+	record, err := app.models.Favorites.Get(favoriteID, app.favoriteOwner(r))
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
 	}
 
-	err = app.writeJSON(w, http.StatusCreated, envelope{"data": response}, nil)
+	allowed := record.CanResume()
+	if status == models.FavoriteStatusPaused {
+		allowed = record.CanPause()
+	}
+
+	if !allowed {
+		app.errorResponse(w, r, http.StatusConflict, fmt.Sprintf("favorite is %s", record.Status))
+		return
+	}
+
+	err = app.models.Favorites.UpdateStatus(record.ID, status)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	record.Status = status
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"data": map[string]interface{}{"favorite": hotelFavoriteFromModel(*record)}}, nil)
+	if err != nil {
+		app.logError(r, err)
+		app.errorResponse(w, r, http.StatusInternalServerError, "failed to encode response")
+		return
+	}
+}
+
+func (app *application) deleteFavoriteHandler(w http.ResponseWriter, r *http.Request) {
+	favoriteID, ok := app.readFavoriteIDParam(w, r)
+	if !ok {
+		return
+	}
+
+	err := app.models.Favorites.Delete(favoriteID, app.favoriteOwner(r))
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.logError(r, err)
+			app.errorResponse(w, r, http.StatusInternalServerError, "database error")
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "favorite successfully deleted"}, nil)
 	if err != nil {
 		app.logError(r, err)
 		app.errorResponse(w, r, http.StatusInternalServerError, "failed to encode response")
 		return
 	}
 }
+
+func (app *application) readFavoriteIDParam(w http.ResponseWriter, r *http.Request) (int, bool) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	favoriteID, err := strconv.Atoi(params.ByName("favorite_id"))
+	if err != nil || favoriteID <= 0 {
+		app.errorResponse(w, r, http.StatusBadRequest, "invalid favorite_id parameter")
+		return 0, false
+	}
+
+	return favoriteID, true
+}
+
+func hotelFavoriteFromModel(record models.Favorite) HotelFavorite {
+	favorite := HotelFavorite{
+		ID:             record.ID,
+		UserID:         record.UserID,
+		OrganizationID: record.OrganizationID,
+		WatchlistID:    record.WatchlistID,
+		Status:         record.Status,
+		HotelID:        record.HotelID,
+		Adults:         record.Stay.Adults,
+		Children:       record.Stay.Children,
+		TargetPrice:    record.TargetPrice,
+		Currency:       record.Currency,
+		Criteria:       record.Criteria,
+		CreatedAt:      record.CreatedAt,
+	}
+
+	if record.Stay.CheckIn != nil && record.Stay.CheckOut != nil {
+		favorite.CheckIn = record.Stay.CheckIn.Format("2006-01-02")
+		favorite.CheckOut = record.Stay.CheckOut.Format("2006-01-02")
+	}
+	if record.EndDate != nil {
+		favorite.EndDate = record.EndDate.Format("2006-01-02")
+	}
+
+	return favorite
+}
+
+// stayFromRequest validates the stay a favorite is watched for. Dates are
+// optional but go together and must not be in the past; adults default
+// to 1.
+func stayFromRequest(v *validator.Validator, req CreateFavoriteRequest, today time.Time) models.Stay {
+	stay := models.Stay{Adults: req.Adults, Children: req.Children}
+	if stay.Adults == 0 {
+		stay.Adults = 1
+	}
+	if stay.Children == nil {
+		stay.Children = []int64{}
+	}
+
+	v.Check(stay.Adults >= 1 && stay.Adults <= 8, "adults", "must be between 1 and 8")
+	v.Check(len(stay.Children) <= 8, "children", "must not contain more than 8 entries")
+	for _, age := range stay.Children {
+		v.Check(age >= 0 && age <= 17, "children", "must contain ages between 0 and 17")
+	}
+
+	if req.CheckIn == "" && req.CheckOut == "" {
+		return stay
+	}
+
+	checkIn, errIn := time.Parse("2006-01-02", req.CheckIn)
+	checkOut, errOut := time.Parse("2006-01-02", req.CheckOut)
+	v.Check(errIn == nil, "check_in", "must be a YYYY-MM-DD date, provided with check_out")
+	v.Check(errOut == nil, "check_out", "must be a YYYY-MM-DD date, provided with check_in")
+	if errIn != nil || errOut != nil {
+		return stay
+	}
+
+	v.Check(checkOut.After(checkIn), "check_out", "must be after check_in")
+	v.Check(!checkIn.Before(today.Truncate(24*time.Hour)), "check_in", "must not be in the past")
+
+	stay.CheckIn, stay.CheckOut = &checkIn, &checkOut
+	return stay
+}
+
+// endDateFromRequest validates the optional date after which a favorite
+// expires.
+func endDateFromRequest(v *validator.Validator, value string, today time.Time) *time.Time {
+	if value == "" {
+		return nil
+	}
+
+	endDate, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		v.AddError("end_date", "must be a YYYY-MM-DD date")
+		return nil
+	}
+
+	v.Check(!endDate.Before(today.Truncate(24*time.Hour)), "end_date", "must not be in the past")
+	return &endDate
+}
+
+func ValidateWatchCriteria(v *validator.Validator, criteria models.WatchCriteria) {
+	v.Check(len(criteria.BoardTypes) <= 10, "criteria.board_types", "must not contain more than 10 entries")
+	for _, boardType := range criteria.BoardTypes {
+		v.Check(boardType != "", "criteria.board_types", "must not contain empty values")
+		v.Check(len(boardType) <= 10, "criteria.board_types", "must contain board type codes such as RO, BB, HB, FB or AI")
+	}
+
+	v.Check(criteria.MinCapacity >= 0, "criteria.min_capacity", "must not be negative")
+	v.Check(criteria.MinCapacity <= 8, "criteria.min_capacity", "must not be more than 8")
+
+	if criteria.MaxTaxes != nil {
+		v.Check(*criteria.MaxTaxes >= 0, "criteria.max_taxes", "must not be negative")
+	}
+}
+
+// convertFavoriteCurrency moves favorite into currency, converting its target
+// price and the amounts of its criteria, which are all expressed in the
+// favorite's currency.
+func (app *application) convertFavoriteCurrency(favorite *models.Favorite, currency string) error {
+	amounts := []*float64{&favorite.TargetPrice}
+	if favorite.Criteria.MaxTaxes != nil {
+		maxTaxes := *favorite.Criteria.MaxTaxes
+		favorite.Criteria.MaxTaxes = &maxTaxes
+		amounts = append(amounts, &maxTaxes)
+	}
+
+	for _, amount := range amounts {
+		conversion, err := app.fx.Convert(*amount, favorite.Currency, currency)
+		if err != nil {
+			return err
+		}
+		*amount = math.Round(conversion.Amount*100) / 100
+	}
+
+	favorite.Currency = currency
+	return nil
+}
+
+// validateFavoriteCurrency checks the currency a target price is expressed
+// in. Once rates are loaded it must also be one the monitor can convert to.
+func (app *application) validateFavoriteCurrency(v *validator.Validator, currency string) {
+	if !validator.Matches(currency, fx.CurrencyRX) {
+		v.AddError("currency", "must be an ISO 4217 code such as USD or EUR")
+		return
+	}
+
+	if rates := app.fx.Current(); rates != nil {
+		_, err := rates.Rate(liteAPICurrency, currency)
+		v.Check(err == nil, "currency", "is not supported")
+	}
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	models "github.com/madfelps/challenge-nuitee/internal/data"
+	"github.com/madfelps/challenge-nuitee/internal/fx"
+	"github.com/madfelps/challenge-nuitee/internal/validator"
+)
+
+func TestStayFromRequest(t *testing.T) {
+	today := time.Date(2025, 6, 1, 15, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		req      CreateFavoriteRequest
+		expected bool
+		dates    bool
+	}{
+		{name: "default stay", req: CreateFavoriteRequest{}, expected: true},
+		{name: "dates", req: CreateFavoriteRequest{CheckIn: "2025-07-01", CheckOut: "2025-07-04"}, expected: true, dates: true},
+		{name: "check-in today", req: CreateFavoriteRequest{CheckIn: "2025-06-01", CheckOut: "2025-06-02"}, expected: true, dates: true},
+		{name: "family", req: CreateFavoriteRequest{Adults: 2, Children: []int64{4, 9}}, expected: true},
+		{name: "check-in only", req: CreateFavoriteRequest{CheckIn: "2025-07-01"}, expected: false},
+		{name: "invalid date", req: CreateFavoriteRequest{CheckIn: "01/07/2025", CheckOut: "2025-07-04"}, expected: false},
+		{name: "check-out before check-in", req: CreateFavoriteRequest{CheckIn: "2025-07-04", CheckOut: "2025-07-01"}, expected: false},
+		{name: "same day", req: CreateFavoriteRequest{CheckIn: "2025-07-01", CheckOut: "2025-07-01"}, expected: false},
+		{name: "past check-in", req: CreateFavoriteRequest{CheckIn: "2025-05-31", CheckOut: "2025-06-02"}, expected: false},
+		{name: "too many adults", req: CreateFavoriteRequest{Adults: 9}, expected: false},
+		{name: "negative adults", req: CreateFavoriteRequest{Adults: -1}, expected: false},
+		{name: "adult child", req: CreateFavoriteRequest{Children: []int64{18}}, expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := validator.New()
+			stay := stayFromRequest(v, tt.req, today)
+
+			if v.Valid() != tt.expected {
+				t.Errorf("stayFromRequest() valid = %v, expected %v (%v)", v.Valid(), tt.expected, v.Errors)
+			}
+			if !v.Valid() {
+				return
+			}
+
+			if (stay.CheckIn != nil) != tt.dates {
+				t.Errorf("stayFromRequest() dates set = %v, expected %v", stay.CheckIn != nil, tt.dates)
+			}
+			if stay.Adults < 1 || stay.Children == nil {
+				t.Errorf("stayFromRequest() = %+v, expected at least one adult and non-nil children", stay)
+			}
+		})
+	}
+}
+
+func TestFavoriteStay(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	prefs := &models.Preferences{Timezone: "UTC"}
+	checkIn := time.Date(2025, 8, 10, 0, 0, 0, 0, time.UTC)
+	checkOut := time.Date(2025, 8, 14, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name             string
+		stay             models.Stay
+		expectedCheckIn  string
+		expectedCheckOut string
+		expectedAdults   int
+		expectedChildren []int
+	}{
+		{
+			name:             "default stay",
+			stay:             models.Stay{},
+			expectedCheckIn:  "2025-07-01",
+			expectedCheckOut: "2025-07-02",
+			expectedAdults:   1,
+			expectedChildren: []int{},
+		},
+		{
+			name:             "trip",
+			stay:             models.Stay{CheckIn: &checkIn, CheckOut: &checkOut, Adults: 2, Children: []int64{5}},
+			expectedCheckIn:  "2025-08-10",
+			expectedCheckOut: "2025-08-14",
+			expectedAdults:   2,
+			expectedChildren: []int{5},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in, out, occupancy := favoriteStay(models.Favorite{Stay: tt.stay}, prefs, now)
+
+			if in != tt.expectedCheckIn || out != tt.expectedCheckOut {
+				t.Errorf("favoriteStay() dates = %s, %s, expected %s, %s", in, out, tt.expectedCheckIn, tt.expectedCheckOut)
+			}
+			if occupancy.Adults != tt.expectedAdults || !slices.Equal(occupancy.Children, tt.expectedChildren) {
+				t.Errorf("favoriteStay() occupancy = %+v, expected %d adults and children %v", occupancy, tt.expectedAdults, tt.expectedChildren)
+			}
+		})
+	}
+}
+
+func TestEndDateFromRequest(t *testing.T) {
+	today := time.Date(2025, 6, 1, 15, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		value    string
+		expected bool
+		set      bool
+	}{
+		{value: "", expected: true, set: false},
+		{value: "2025-06-01", expected: true, set: true},
+		{value: "2025-12-31", expected: true, set: true},
+		{value: "2025-05-31", expected: false},
+		{value: "31/12/2025", expected: false},
+	}
+
+	for _, tt := range tests {
+		v := validator.New()
+		endDate := endDateFromRequest(v, tt.value, today)
+
+		if v.Valid() != tt.expected {
+			t.Errorf("endDateFromRequest(%q) valid = %v, expected %v", tt.value, v.Valid(), tt.expected)
+		}
+		if v.Valid() && (endDate != nil) != tt.set {
+			t.Errorf("endDateFromRequest(%q) set = %v, expected %v", tt.value, endDate != nil, tt.set)
+		}
+	}
+}
+
+func TestFavoriteStatusTransitions(t *testing.T) {
+	tests := []struct {
+		status    string
+		canPause  bool
+		canResume bool
+	}{
+		{status: models.FavoriteStatusActive, canPause: true, canResume: true},
+		{status: models.FavoriteStatusPaused, canPause: true, canResume: true},
+		{status: models.FavoriteStatusFulfilled, canPause: false, canResume: true},
+		{status: models.FavoriteStatusExpired, canPause: false, canResume: false},
+	}
+
+	for _, tt := range tests {
+		favorite := models.Favorite{Status: tt.status}
+
+		if got := favorite.CanPause(); got != tt.canPause {
+			t.Errorf("CanPause() for %s = %v, expected %v", tt.status, got, tt.canPause)
+		}
+		if got := favorite.CanResume(); got != tt.canResume {
+			t.Errorf("CanResume() for %s = %v, expected %v", tt.status, got, tt.canResume)
+		}
+	}
+}
+
+func TestConvertFavoriteCurrency(t *testing.T) {
+	app := &application{fx: fx.NewConverter()}
+	app.fx.Set(&fx.Rates{Base: "USD", Rates: map[string]float64{"EUR": 0.9}, FetchedAt: time.Now()})
+
+	maxTaxes, convertedMaxTaxes := 20.0, 18.0
+	tests := []struct {
+		name     string
+		favorite models.Favorite
+		maxTaxes *float64
+	}{
+		{name: "without criteria", favorite: models.Favorite{TargetPrice: 150, Currency: "USD"}},
+		{name: "with max taxes", favorite: models.Favorite{TargetPrice: 150, Currency: "USD", Criteria: models.WatchCriteria{MaxTaxes: &maxTaxes}}, maxTaxes: &convertedMaxTaxes},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			favorite := tt.favorite
+
+			if err := app.convertFavoriteCurrency(&favorite, "EUR"); err != nil {
+				t.Fatal(err)
+			}
+
+			if favorite.Currency != "EUR" || favorite.TargetPrice != 135 {
+				t.Errorf("target = %.2f %s, expected 135.00 EUR", favorite.TargetPrice, favorite.Currency)
+			}
+
+			switch {
+			case tt.maxTaxes == nil && favorite.Criteria.MaxTaxes != nil:
+				t.Errorf("max taxes = %.2f, expected none", *favorite.Criteria.MaxTaxes)
+			case tt.maxTaxes != nil && (favorite.Criteria.MaxTaxes == nil || *favorite.Criteria.MaxTaxes != *tt.maxTaxes):
+				t.Errorf("max taxes = %v, expected %.2f", favorite.Criteria.MaxTaxes, *tt.maxTaxes)
+			}
+		})
+	}
+
+	if maxTaxes != 20 {
+		t.Errorf("original max taxes changed to %.2f", maxTaxes)
+	}
+
+	favorite := models.Favorite{TargetPrice: 150, Currency: "USD"}
+	if err := app.convertFavoriteCurrency(&favorite, "JPY"); err == nil {
+		t.Error("expected an error for an unknown currency")
+	}
+}
+
+func TestMonitorCacheDeliverable(t *testing.T) {
+	orgWithoutSubscribers, orgWithSubscriber := 1, 2
+
+	cache := newMonitorCache(&application{})
+	cache.users = map[int]*models.User{
+		1: {ID: 1, Email: "log@example.com"},
+		2: {ID: 2, Email: "verified@example.com", Activated: true},
+		3: {ID: 3, Email: "unverified@example.com"},
+		4: {ID: 4, Email: "unsubscribed@example.com", Activated: true},
+	}
+	cache.prefs = map[int]*models.Preferences{
+		1: {AlertChannels: []string{models.AlertChannelLog}},
+		2: {AlertChannels: []string{models.AlertChannelEmail}},
+		3: {AlertChannels: []string{models.AlertChannelEmail}},
+		4: {AlertChannels: []string{}},
+	}
+	cache.subscribers = map[int][]int{
+		orgWithoutSubscribers: {},
+		orgWithSubscriber:     {3, 2},
+	}
+
+	tests := []struct {
+		name     string
+		favorite models.Favorite
+		expected bool
+	}{
+		{name: "owner alerted in the log", favorite: models.Favorite{UserID: 1}, expected: true},
+		{name: "owner with a verified email", favorite: models.Favorite{UserID: 2}, expected: true},
+		{name: "owner with an unverified email", favorite: models.Favorite{UserID: 3}, expected: false},
+		{name: "owner without channels", favorite: models.Favorite{UserID: 4}, expected: false},
+		{name: "organization without subscribers", favorite: models.Favorite{UserID: 1, OrganizationID: &orgWithoutSubscribers}, expected: false},
+		{name: "organization with a reachable subscriber", favorite: models.Favorite{UserID: 4, OrganizationID: &orgWithSubscriber}, expected: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := cache.deliverable(tt.favorite)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.expected {
+				t.Errorf("deliverable = %v, expected %v", got, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	models "github.com/madfelps/challenge-nuitee/internal/data"
+	"github.com/madfelps/challenge-nuitee/internal/fx"
+)
+
+// liteAPICurrency is the currency prices are requested from LiteAPI in.
+// Conversions into other currencies use our own stored rates, so every price
+// observation can be traced back to the rate it was converted with.
+const liteAPICurrency = "USD"
+
+func (app *application) StartFXRefresher() {
+	if app.config.fx.source == "" {
+		return
+	}
+
+	log.Printf("fx refresher started (source: %s)", app.config.fx.source)
+
+	app.refreshFXRates()
+
+	ticker := time.NewTicker(app.config.fx.refreshInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		app.refreshFXRates()
+	}
+}
+
+func (app *application) refreshFXRates() {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	rates, err := fx.Load(ctx, &http.Client{}, app.config.fx.source)
+	if err != nil {
+		log.Printf("error loading exchange rates: %v", err)
+		return
+	}
+
+	if current := app.fx.Current(); current != nil && !rates.FetchedAt.After(current.FetchedAt) {
+		return
+	}
+
+	err = app.models.FXRates.Insert(rates)
+	if err != nil {
+		log.Printf("error storing exchange rates: %v", err)
+		return
+	}
+
+	app.fx.Set(rates)
+
+	log.Printf("loaded %d exchange rates (base %s, updated at %s)",
+		len(rates.Rates), rates.Base, rates.FetchedAt.Format(time.RFC3339))
+}
+
+// loadStoredFXRates restores the latest stored snapshot so conversions work
+// before the first refresh, or when the source is unavailable.
+func (app *application) loadStoredFXRates() error {
+	rates, err := app.models.FXRates.Latest()
+	if err != nil {
+		if errors.Is(err, models.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	app.fx.Set(rates)
+
+	return nil
+}
+
+// readCurrencyParam returns the currency requested in the query string,
+// defaulting to the default user currency.
+func readCurrencyParam(qs url.Values) (string, bool) {
+	currency := strings.ToUpper(qs.Get("currency"))
+	if currency == "" {
+		return models.DefaultCurrency, true
+	}
+
+	return currency, fx.CurrencyRX.MatchString(currency)
+}
+
+// readDisplayCurrency returns the currency prices are shown in: the currency
+// query parameter, else the preferred currency of the authenticated user,
+// else the default currency. It writes the error response and returns false
+// when the parameter is invalid or preferences cannot be loaded.
+func (app *application) readDisplayCurrency(w http.ResponseWriter, r *http.Request) (string, bool) {
+	qs := r.URL.Query()
+
+	currency, ok := readCurrencyParam(qs)
+	if !ok {
+		app.errorResponse(w, r, http.StatusBadRequest, "invalid currency parameter (must be an ISO 4217 code)")
+		return "", false
+	}
+
+	if qs.Get("currency") != "" {
+		return currency, true
+	}
+
+	user := app.contextGetUser(r)
+	if user.IsAnonymous() {
+		return currency, true
+	}
+
+	prefs, err := app.models.Preferences.Get(user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return "", false
+	}
+
+	return prefs.Currency, true
+}
+
+// convertPriceResponse reports a failed conversion of a displayed price.
+func (app *application) convertPriceResponse(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, fx.ErrUnknownCurrency):
+		app.errorResponse(w, r, http.StatusUnprocessableEntity, map[string]string{"currency": "is not supported"})
+	case errors.Is(err, fx.ErrNoRates):
+		app.errorResponse(w, r, http.StatusServiceUnavailable, "exchange rates are not available yet, please try again later")
+	default:
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// observePrice converts a price seen by the monitor into the favorite's
+// currency and records the observation together with the rate used.
+func (app *application) observePrice(favorite models.Favorite, price float64, currency string) (float64, error) {
+	conversion, err := app.fx.Convert(price, currency, favorite.Currency)
+	if err != nil {
+		return 0, err
+	}
+
+	observation := &models.PriceObservation{
+		FavoriteID:        favorite.ID,
+		HotelID:           favorite.HotelID,
+		Price:             price,
+		Currency:          currency,
+		FXRate:            conversion.Rate,
+		FXRatesFetchedAt:  conversion.FetchedAt,
+		ConvertedPrice:    conversion.Amount,
+		ConvertedCurrency: conversion.Currency,
+	}
+
+	if err := app.models.PriceObservations.Insert(observation); err != nil {
+		log.Printf("error recording price observation for favorite %d: %v", favorite.ID, err)
+	}
+
+	return conversion.Amount, nil
+}
+
+func (app *application) showFXRatesHandler(w http.ResponseWriter, r *http.Request) {
+	rates := app.fx.Current()
+	if rates == nil {
+		app.errorResponse(w, r, http.StatusServiceUnavailable, "exchange rates are not available yet, please try again later")
+		return
+	}
+
+	err := app.writeJSON(w, http.StatusOK, envelope{"data": rates}, nil)
+	if err != nil {
+		app.logError(r, err)
+		app.errorResponse(w, r, http.StatusInternalServerError, "failed to encode response")
+		return
+	}
+}
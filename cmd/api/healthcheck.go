@@ -1,15 +1,29 @@
 package main
 
-import "net/http"
+import (
+	"net/http"
+
+	"github.com/madfelps/challenge-nuitee/internal/upstream"
+)
 
 func (app *application) healthcheckHandler(w http.ResponseWriter, r *http.Request) {
 
+	status := "available"
+
+	breakerState := app.upstream.Breaker.State()
+	if breakerState == upstream.StateOpen {
+		status = "degraded"
+	}
+
 	env := envelope{
-		"status": "available",
+		"status": status,
 		"system_info": map[string]string{
 			"environment": app.config.env,
 			"version":     version,
 		},
+		"upstream": map[string]string{
+			"liteapi_circuit_breaker": breakerState.String(),
+		},
 	}
 
 	err := app.writeJSON(w, http.StatusOK, env, nil)
@@ -1,3 +1,23 @@
 package main
 
+import "fmt"
+
 type envelope map[string]interface{}
+
+// background runs fn in a goroutine tracked by app.wg, recovering and
+// logging any panic so it cannot crash the server.
+func (app *application) background(fn func()) {
+	app.wg.Add(1)
+
+	go func() {
+		defer app.wg.Done()
+
+		defer func() {
+			if err := recover(); err != nil {
+				app.logger.PrintError(fmt.Errorf("%s", err), nil)
+			}
+		}()
+
+		fn()
+	}()
+}
@@ -0,0 +1,306 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+const earthRadiusKm = 6371.0
+
+// maxHotelSearchCandidates bounds how many hotels are pulled from LiteAPI for
+// a single search before local filtering, sorting and pagination are applied.
+const maxHotelSearchCandidates = 1000
+
+// hotelSearchPageSize is the number of hotels requested per LiteAPI call.
+const hotelSearchPageSize = 200
+
+// defaultSearchRadiusKm is the radius sent to LiteAPI for coordinate searches
+// that do not set radius_km.
+const defaultSearchRadiusKm = 10.0
+
+const (
+	sortRelevance = "relevance"
+	sortStars     = "stars"
+	sortDistance  = "distance"
+	sortName      = "name"
+)
+
+type geoPoint struct {
+	Latitude  float64
+	Longitude float64
+}
+
+type boundingBox struct {
+	MinLatitude  float64
+	MinLongitude float64
+	MaxLatitude  float64
+	MaxLongitude float64
+}
+
+func (b boundingBox) contains(lat, lng float64) bool {
+	return lat >= b.MinLatitude && lat <= b.MaxLatitude &&
+		lng >= b.MinLongitude && lng <= b.MaxLongitude
+}
+
+type hotelSearch struct {
+	Name     string
+	MinStars float64
+	BBox     *boundingBox
+	Center   *geoPoint
+	RadiusKm float64
+	Sort     string
+	Desc     bool
+}
+
+type hotelMatch struct {
+	hotel Hotel
+	score int
+}
+
+// haversineKm returns the great-circle distance in kilometers between two
+// points given in decimal degrees.
+func haversineKm(lat1, lng1, lat2, lng2 float64) float64 {
+	toRad := func(deg float64) float64 { return deg * math.Pi / 180 }
+
+	dLat := toRad(lat2 - lat1)
+	dLng := toRad(lng2 - lng1)
+
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(toRad(lat1))*math.Cos(toRad(lat2))*math.Sin(dLng/2)*math.Sin(dLng/2)
+
+	return earthRadiusKm * 2 * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
+}
+
+func readHotelSearch(qs url.Values) (hotelSearch, error) {
+	s := hotelSearch{
+		Name: strings.TrimSpace(qs.Get("name")),
+	}
+
+	var err error
+
+	if s.MinStars, err = readFloatParam(qs, "min_stars"); err != nil {
+		return s, err
+	}
+	if s.MinStars < 0 || s.MinStars > 5 {
+		return s, fmt.Errorf("invalid min_stars parameter (must be between 0 and 5)")
+	}
+
+	if qs.Has("lat") || qs.Has("lng") {
+		lat, err := readFloatParam(qs, "lat")
+		if err != nil || !qs.Has("lat") || lat < -90 || lat > 90 {
+			return s, fmt.Errorf("invalid lat parameter")
+		}
+		lng, err := readFloatParam(qs, "lng")
+		if err != nil || !qs.Has("lng") || lng < -180 || lng > 180 {
+			return s, fmt.Errorf("invalid lng parameter")
+		}
+		s.Center = &geoPoint{Latitude: lat, Longitude: lng}
+	}
+
+	if qs.Has("radius_km") {
+		if s.Center == nil {
+			return s, fmt.Errorf("radius_km requires lat and lng parameters")
+		}
+		if s.RadiusKm, err = readFloatParam(qs, "radius_km"); err != nil || s.RadiusKm <= 0 || s.RadiusKm > 500 {
+			return s, fmt.Errorf("invalid radius_km parameter (must be between 0 and 500)")
+		}
+	}
+
+	bboxKeys := []string{"min_lat", "min_lng", "max_lat", "max_lng"}
+	bboxValues := make([]float64, 0, len(bboxKeys))
+	for _, key := range bboxKeys {
+		if !qs.Has(key) {
+			continue
+		}
+		value, err := readFloatParam(qs, key)
+		if err != nil {
+			return s, err
+		}
+		maxAbs := 180.0
+		if strings.HasSuffix(key, "_lat") {
+			maxAbs = 90
+		}
+		if math.Abs(value) > maxAbs {
+			return s, fmt.Errorf("invalid %s parameter", key)
+		}
+		bboxValues = append(bboxValues, value)
+	}
+
+	switch len(bboxValues) {
+	case 0:
+	case len(bboxKeys):
+		s.BBox = &boundingBox{
+			MinLatitude:  bboxValues[0],
+			MinLongitude: bboxValues[1],
+			MaxLatitude:  bboxValues[2],
+			MaxLongitude: bboxValues[3],
+		}
+		if s.BBox.MinLatitude > s.BBox.MaxLatitude || s.BBox.MinLongitude > s.BBox.MaxLongitude {
+			return s, fmt.Errorf("invalid bounding box (min values must not exceed max values)")
+		}
+	default:
+		return s, fmt.Errorf("min_lat, min_lng, max_lat and max_lng must be provided together")
+	}
+
+	s.Sort = qs.Get("sort")
+	if strings.HasPrefix(s.Sort, "-") {
+		s.Desc = true
+		s.Sort = strings.TrimPrefix(s.Sort, "-")
+	}
+
+	switch s.Sort {
+	case "":
+		s.Sort = sortRelevance
+	case sortRelevance, sortStars, sortName:
+	case sortDistance:
+		if s.Center == nil {
+			return s, fmt.Errorf("sort by distance requires lat and lng parameters")
+		}
+	default:
+		return s, fmt.Errorf("invalid sort parameter (must be one of relevance, stars, distance, name)")
+	}
+
+	return s, nil
+}
+
+// apply filters hotels against the search criteria and returns the matches
+// in the requested order. When a center point is set, each match carries its
+// distance from it.
+func (s hotelSearch) apply(hotels []Hotel) []Hotel {
+	terms := searchTerms(s.Name)
+
+	matches := make([]hotelMatch, 0, len(hotels))
+
+	for _, h := range hotels {
+		if h.Stars < s.MinStars {
+			continue
+		}
+
+		if s.BBox != nil && !s.BBox.contains(h.Latitude, h.Longitude) {
+			continue
+		}
+
+		if s.Center != nil {
+			distance := haversineKm(s.Center.Latitude, s.Center.Longitude, h.Latitude, h.Longitude)
+			if s.RadiusKm > 0 && distance > s.RadiusKm {
+				continue
+			}
+			h.DistanceKm = &distance
+		}
+
+		score, ok := matchHotel(terms, h)
+		if !ok {
+			continue
+		}
+
+		matches = append(matches, hotelMatch{hotel: h, score: score})
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		a, b := matches[i], matches[j]
+		if s.Desc {
+			a, b = b, a
+		}
+
+		switch s.Sort {
+		case sortStars:
+			return a.hotel.Stars > b.hotel.Stars
+		case sortDistance:
+			return *a.hotel.DistanceKm < *b.hotel.DistanceKm
+		case sortName:
+			return strings.ToLower(a.hotel.Name) < strings.ToLower(b.hotel.Name)
+		default:
+			if a.score != b.score {
+				return a.score > b.score
+			}
+			return a.hotel.Stars > b.hotel.Stars
+		}
+	})
+
+	result := make([]Hotel, len(matches))
+	for i, m := range matches {
+		result[i] = m.hotel
+	}
+
+	return result
+}
+
+// matchHotel reports whether every search term matches the hotel and scores
+// the match. Terms hitting the hotel name weigh more than terms found only in
+// its address or city, and whole-word hits weigh more than prefix hits.
+func matchHotel(terms []string, h Hotel) (int, bool) {
+	if len(terms) == 0 {
+		return 0, true
+	}
+
+	nameWords := searchTerms(h.Name)
+	otherWords := searchTerms(h.Address + " " + h.City)
+
+	score := 0
+	for _, term := range terms {
+		switch {
+		case containsWord(nameWords, term, true):
+			score += 4
+		case containsWord(nameWords, term, false):
+			score += 2
+		case containsWord(otherWords, term, false):
+			score += 1
+		default:
+			return 0, false
+		}
+	}
+
+	return score, true
+}
+
+func containsWord(words []string, term string, exact bool) bool {
+	for _, word := range words {
+		if word == term || (!exact && strings.HasPrefix(word, term)) {
+			return true
+		}
+	}
+	return false
+}
+
+var accentFolder = strings.NewReplacer(
+	"á", "a", "à", "a", "â", "a", "ã", "a", "ä", "a", "å", "a",
+	"é", "e", "è", "e", "ê", "e", "ë", "e",
+	"í", "i", "ì", "i", "î", "i", "ï", "i",
+	"ó", "o", "ò", "o", "ô", "o", "õ", "o", "ö", "o",
+	"ú", "u", "ù", "u", "û", "u", "ü", "u",
+	"ç", "c", "ñ", "n", "ß", "ss",
+)
+
+// searchTerms lowercases text, folds common accents and splits it into words.
+func searchTerms(text string) []string {
+	text = accentFolder.Replace(strings.ToLower(text))
+
+	return strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// readFloatParam returns the float query parameter key, or 0 when it is
+// absent. A parameter present with an empty value is invalid.
+func readFloatParam(qs url.Values, key string) (float64, error) {
+	if !qs.Has(key) {
+		return 0, nil
+	}
+
+	value := qs.Get(key)
+	if value == "" {
+		return 0, fmt.Errorf("invalid %s parameter", key)
+	}
+
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+		return 0, fmt.Errorf("invalid %s parameter", key)
+	}
+
+	return f, nil
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestHaversineKm(t *testing.T) {
+	tests := []struct {
+		name     string
+		lat1     float64
+		lng1     float64
+		lat2     float64
+		lng2     float64
+		expected float64
+	}{
+		{
+			name:     "same point",
+			lat1:     38.7223,
+			lng1:     -9.1393,
+			lat2:     38.7223,
+			lng2:     -9.1393,
+			expected: 0,
+		},
+		{
+			name:     "lisbon to porto",
+			lat1:     38.7223,
+			lng1:     -9.1393,
+			lat2:     41.1579,
+			lng2:     -8.6291,
+			expected: 274,
+		},
+		{
+			name:     "paris to london",
+			lat1:     48.8566,
+			lng1:     2.3522,
+			lat2:     51.5074,
+			lng2:     -0.1278,
+			expected: 344,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := haversineKm(tt.lat1, tt.lng1, tt.lat2, tt.lng2)
+
+			if math.Abs(got-tt.expected) > 2 {
+				t.Errorf("haversineKm() = %.2f, expected about %.2f", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestReadHotelSearch(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    string
+		expected bool
+	}{
+		{
+			name:     "empty query",
+			query:    "",
+			expected: true,
+		},
+		{
+			name:     "full query",
+			query:    "name=ritz&min_stars=4&lat=38.72&lng=-9.14&radius_km=5&sort=-distance",
+			expected: true,
+		},
+		{
+			name:     "bounding box",
+			query:    "min_lat=38.6&min_lng=-9.3&max_lat=38.8&max_lng=-9.0",
+			expected: true,
+		},
+		{
+			name:     "partial bounding box",
+			query:    "min_lat=38.6&min_lng=-9.3",
+			expected: false,
+		},
+		{
+			name:     "inverted bounding box",
+			query:    "min_lat=38.8&min_lng=-9.3&max_lat=38.6&max_lng=-9.0",
+			expected: false,
+		},
+		{
+			name:     "empty lat",
+			query:    "lat=&lng=-9.14",
+			expected: false,
+		},
+		{
+			name:     "empty min stars",
+			query:    "min_stars=",
+			expected: false,
+		},
+		{
+			name:     "bounding box latitude out of range",
+			query:    "min_lat=-91&min_lng=-9.3&max_lat=38.8&max_lng=-9.0",
+			expected: false,
+		},
+		{
+			name:     "bounding box longitude out of range",
+			query:    "min_lat=38.6&min_lng=-9.3&max_lat=38.8&max_lng=181",
+			expected: false,
+		},
+		{
+			name:     "lat without lng",
+			query:    "lat=38.72",
+			expected: false,
+		},
+		{
+			name:     "radius without center",
+			query:    "radius_km=5",
+			expected: false,
+		},
+		{
+			name:     "distance sort without center",
+			query:    "sort=distance",
+			expected: false,
+		},
+		{
+			name:     "invalid min stars",
+			query:    "min_stars=7",
+			expected: false,
+		},
+		{
+			name:     "unknown sort",
+			query:    "sort=price",
+			expected: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			qs, err := url.ParseQuery(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = readHotelSearch(qs)
+
+			if (err == nil) != tt.expected {
+				t.Errorf("readHotelSearch() error = %v, expected valid = %v", err, tt.expected)
+			}
+		})
+	}
+}
+
+func TestHotelSearchApply(t *testing.T) {
+	hotels := []Hotel{
+		{HotelID: "lp1", Name: "Pestana Palace Lisboa", City: "Lisbon", Stars: 5, Latitude: 38.7040, Longitude: -9.1860},
+		{HotelID: "lp2", Name: "Hotel Avenida Palace", City: "Lisbon", Stars: 4, Latitude: 38.7146, Longitude: -9.1416},
+		{HotelID: "lp3", Name: "Lisboa Budget Inn", City: "Lisbon", Stars: 2, Latitude: 38.7200, Longitude: -9.1400},
+		{HotelID: "op1", Name: "Porto Palácio Hotel", City: "Porto", Stars: 5, Latitude: 41.1579, Longitude: -8.6291},
+	}
+
+	center := &geoPoint{Latitude: 38.7139, Longitude: -9.1394}
+
+	tests := []struct {
+		name     string
+		search   hotelSearch
+		expected []string
+	}{
+		{
+			name:     "name match ranks whole words first",
+			search:   hotelSearch{Name: "palace", Sort: sortRelevance},
+			expected: []string{"lp1", "lp2"},
+		},
+		{
+			name:     "accent folding and prefix match",
+			search:   hotelSearch{Name: "palac", Sort: sortRelevance},
+			expected: []string{"lp1", "op1", "lp2"},
+		},
+		{
+			name:     "min stars",
+			search:   hotelSearch{MinStars: 4, Sort: sortStars, Desc: true},
+			expected: []string{"lp2", "lp1", "op1"},
+		},
+		{
+			name:     "radius sorted by distance",
+			search:   hotelSearch{Center: center, RadiusKm: 2, Sort: sortDistance},
+			expected: []string{"lp2", "lp3"},
+		},
+		{
+			name: "bounding box",
+			search: hotelSearch{
+				BBox: &boundingBox{MinLatitude: 41, MinLongitude: -9, MaxLatitude: 42, MaxLongitude: -8},
+				Sort: sortRelevance,
+			},
+			expected: []string{"op1"},
+		},
+		{
+			name:     "all terms must match",
+			search:   hotelSearch{Name: "palace porto", Sort: sortRelevance},
+			expected: []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.search.apply(hotels)
+
+			ids := make([]string, len(got))
+			for i, h := range got {
+				ids[i] = h.HotelID
+			}
+
+			if len(ids) != len(tt.expected) {
+				t.Fatalf("apply() = %v, expected %v", ids, tt.expected)
+			}
+			for i := range ids {
+				if ids[i] != tt.expected[i] {
+					t.Fatalf("apply() = %v, expected %v", ids, tt.expected)
+				}
+			}
+		})
+	}
+}
+
+func TestSearchHotelsFromAPI(t *testing.T) {
+	tests := []struct {
+		name      string
+		available int
+		expected  int
+		truncated bool
+	}{
+		{name: "short list", available: 3, expected: 3},
+		{name: "whole pages", available: 2 * hotelSearchPageSize, expected: 2 * hotelSearchPageSize},
+		{name: "exactly the cap", available: maxHotelSearchCandidates, expected: maxHotelSearchCandidates},
+		{name: "above the cap", available: maxHotelSearchCandidates + 1, expected: maxHotelSearchCandidates, truncated: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApplication(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+				limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+
+				items := []string{}
+				for i := offset; i < min(offset+limit, tt.available); i++ {
+					items = append(items, fmt.Sprintf(`{"id": "h%d"}`, i))
+				}
+				fmt.Fprintf(w, `{"data": [%s]}`, strings.Join(items, ","))
+			}))
+
+			hotels, truncated, err := app.searchHotelsFromAPI(context.Background(), url.Values{}, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(hotels) != tt.expected || truncated != tt.truncated {
+				t.Errorf("got %d hotels, truncated = %t, expected %d, %t", len(hotels), truncated, tt.expected, tt.truncated)
+			}
+		})
+	}
+}
@@ -1,36 +1,39 @@
 package main
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
 	"fmt"
-	"io"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/julienschmidt/httprouter"
-	liteapi "github.com/liteapi-travel/go-sdk/v3"
 )
 
 type Hotel struct {
-	HotelID     string  `json:"hotel_id"`
-	Name        string  `json:"name"`
-	Address     string  `json:"address"`
-	City        string  `json:"city"`
-	Country     string  `json:"country"`
-	CountryCode string  `json:"country_code"`
-	Stars       float64 `json:"stars"`
-	Latitude    float64 `json:"latitude,omitempty"`
-	Longitude   float64 `json:"longitude,omitempty"`
+	HotelID     string   `json:"hotel_id"`
+	Name        string   `json:"name"`
+	Address     string   `json:"address"`
+	City        string   `json:"city"`
+	Country     string   `json:"country"`
+	CountryCode string   `json:"country_code"`
+	Stars       float64  `json:"stars"`
+	Latitude    float64  `json:"latitude,omitempty"`
+	Longitude   float64  `json:"longitude,omitempty"`
+	DistanceKm  *float64 `json:"distance_km,omitempty"`
 }
 
+// HotelsResponse is a page of search results. Total counts the matches
+// among the candidates loaded from LiteAPI; Truncated reports that more
+// candidates were left unloaded, so Total is a lower bound.
 type HotelsResponse struct {
-	Hotels []Hotel `json:"hotels"`
-	Total  int     `json:"total"`
-	Offset int     `json:"offset"`
-	Limit  int     `json:"limit"`
+	Hotels    []Hotel `json:"hotels"`
+	Total     int     `json:"total"`
+	Truncated bool    `json:"truncated"`
+	Offset    int     `json:"offset"`
+	Limit     int     `json:"limit"`
 }
 
 type Occupancy struct {
@@ -38,6 +41,8 @@ type Occupancy struct {
 	Children []int `json:"children"`
 }
 
+// MinRateSearchRequest is the body of the LiteAPI min-rates and full-rates
+// searches.
 type MinRateSearchRequest struct {
 	HotelIds         []string    `json:"hotelIds"`
 	Checkin          string      `json:"checkin"`
@@ -50,27 +55,36 @@ type MinRateSearchRequest struct {
 
 func (app *application) listHotelsHandler(w http.ResponseWriter, r *http.Request) {
 
-	countryCode := r.URL.Query().Get("countryCode")
-	cityName := r.URL.Query().Get("cityName")
+	qs := r.URL.Query()
 
-	if countryCode == "" {
-		app.errorResponse(w, r, http.StatusBadRequest, "countryCode parameter is required")
+	countryCode := qs.Get("countryCode")
+	cityName := qs.Get("cityName")
+
+	search, err := readHotelSearch(qs)
+	if err != nil {
+		app.errorResponse(w, r, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	if cityName == "" {
-		app.errorResponse(w, r, http.StatusBadRequest, "cityName parameter is required")
-		return
+	if search.Center == nil {
+		if countryCode == "" {
+			app.errorResponse(w, r, http.StatusBadRequest, "countryCode parameter is required")
+			return
+		}
+
+		if cityName == "" {
+			app.errorResponse(w, r, http.StatusBadRequest, "cityName parameter is required")
+			return
+		}
 	}
 
-	offsetStr := r.URL.Query().Get("offset")
-	limitStr := r.URL.Query().Get("limit")
+	offsetStr := qs.Get("offset")
+	limitStr := qs.Get("limit")
 
 	offset := 0
 	limit := 20
 
 	if offsetStr != "" {
-		var err error
 		offset, err = strconv.Atoi(offsetStr)
 		if err != nil || offset < 0 {
 			app.errorResponse(w, r, http.StatusBadRequest, "invalid offset parameter")
@@ -79,7 +93,6 @@ func (app *application) listHotelsHandler(w http.ResponseWriter, r *http.Request
 	}
 
 	if limitStr != "" {
-		var err error
 		limit, err = strconv.Atoi(limitStr)
 		if err != nil || limit <= 0 || limit > 100 {
 			app.errorResponse(w, r, http.StatusBadRequest, "invalid limit parameter (must be between 1 and 100)")
@@ -87,92 +100,134 @@ func (app *application) listHotelsHandler(w http.ResponseWriter, r *http.Request
 		}
 	}
 
-	configuration := liteapi.NewConfiguration()
-	apiKey := r.Header.Get("X-API-KEY")
-	if apiKey == "" {
+	apiKey := app.liteAPIKey(r)
 
-		apiKey = r.Header.Get("Authorization")
-		if apiKey == "" {
-			app.errorResponse(w, r, http.StatusUnauthorized, "API key is required")
-			return
-		}
+	upstream := hotelSearchUpstreamQuery(countryCode, cityName, search)
+
+	candidates, truncated, err := app.searchHotelsFromAPI(r.Context(), upstream, apiKey)
+	if err != nil {
+		app.upstreamErrorResponse(w, r, err, "failed to fetch hotels from LiteAPI")
+		return
 	}
 
-	configuration.AddDefaultHeader("X-API-KEY", apiKey)
-	apiClient := liteapi.NewAPIClient(configuration)
+	matches := search.apply(candidates)
 
-	ctx := context.Background()
+	hotels := make([]Hotel, 0, limit)
+	if offset < len(matches) {
+		hotels = append(hotels, matches[offset:min(offset+limit, len(matches))]...)
+	}
 
-	result, res, err := apiClient.StaticDataApi.GetHotels(ctx).
-		CountryCode(countryCode).
-		CityName(cityName).
-		Offset(int32(offset)).
-		Limit(int32(limit)).
-		Execute()
+	response := HotelsResponse{
+		Hotels:    hotels,
+		Total:     len(matches),
+		Truncated: truncated,
+		Offset:    offset,
+		Limit:     limit,
+	}
 
+	err = app.writeJSON(w, http.StatusOK, envelope{"data": response}, nil)
 	if err != nil {
 		app.logError(r, err)
-		app.errorResponse(w, r, http.StatusInternalServerError, "failed to fetch hotels from LiteAPI")
+		app.errorResponse(w, r, http.StatusInternalServerError, "failed to encode response")
 		return
 	}
+}
 
-	if res.StatusCode != http.StatusOK {
-		app.errorResponse(w, r, res.StatusCode, "LiteAPI returned an error")
-		return
+func hotelSearchUpstreamQuery(countryCode, cityName string, search hotelSearch) url.Values {
+	query := url.Values{}
+
+	if countryCode != "" {
+		query.Set("countryCode", countryCode)
 	}
+	if cityName != "" {
+		query.Set("cityName", cityName)
+	}
+	if search.Center != nil {
+		radiusKm := search.RadiusKm
+		if radiusKm == 0 {
+			radiusKm = defaultSearchRadiusKm
+		}
+		query.Set("latitude", strconv.FormatFloat(search.Center.Latitude, 'f', -1, 64))
+		query.Set("longitude", strconv.FormatFloat(search.Center.Longitude, 'f', -1, 64))
+		query.Set("radius", strconv.Itoa(int(radiusKm*1000)))
+	}
+
+	return query
+}
+
+// searchHotelsFromAPI pages through the LiteAPI hotel list for the given
+// query until it is exhausted or maxHotelSearchCandidates hotels are loaded,
+// which takes at most maxHotelSearchCandidates/hotelSearchPageSize calls.
+// truncated reports that the list holds more hotels than were loaded: the
+// last page asks for one extra hotel to tell the two apart.
+func (app *application) searchHotelsFromAPI(ctx context.Context, query url.Values, apiKey string) (hotels []Hotel, truncated bool, err error) {
+	hotels = make([]Hotel, 0, hotelSearchPageSize)
+
+	for offset := 0; offset < maxHotelSearchCandidates; offset += hotelSearchPageSize {
+		pageSize := min(hotelSearchPageSize, maxHotelSearchCandidates-offset)
+		last := offset+pageSize >= maxHotelSearchCandidates
+
+		limit := pageSize
+		if last {
+			limit++
+		}
+
+		query.Set("offset", strconv.Itoa(offset))
+		query.Set("limit", strconv.Itoa(limit))
 
-	hotels := make([]Hotel, 0)
-
-	if data, ok := result["data"].([]interface{}); ok {
-		for _, item := range data {
-			if hotelData, ok := item.(map[string]interface{}); ok {
-				h := Hotel{}
-
-				if id, ok := hotelData["id"].(string); ok {
-					h.HotelID = id
-				}
-				if name, ok := hotelData["name"].(string); ok {
-					h.Name = name
-				}
-				if address, ok := hotelData["address"].(string); ok {
-					h.Address = address
-				}
-				if city, ok := hotelData["city"].(string); ok {
-					h.City = city
-				}
-				if country, ok := hotelData["country"].(string); ok {
-					h.Country = country
-				}
-
-				h.CountryCode = countryCode
-				if stars, ok := hotelData["stars"].(float64); ok {
-					h.Stars = stars
-				}
-				if lat, ok := hotelData["latitude"].(float64); ok {
-					h.Latitude = lat
-				}
-				if lng, ok := hotelData["longitude"].(float64); ok {
-					h.Longitude = lng
-				}
-
-				hotels = append(hotels, h)
-			}
+		var response liteAPIHotelsResponse
+		err := app.callLiteAPI(ctx, http.MethodGet, liteAPIEndpointHotels, query, nil, apiKey, &response)
+		if err != nil {
+			return nil, false, err
+		}
+
+		for _, item := range response.Data[:min(pageSize, len(response.Data))] {
+			hotels = append(hotels, hotelFromLiteAPI(item, query.Get("countryCode")))
+		}
+
+		if len(response.Data) < pageSize {
+			return hotels, false, nil
+		}
+		if last {
+			return hotels, len(response.Data) > pageSize, nil
 		}
 	}
 
-	response := HotelsResponse{
-		Hotels: hotels,
-		Total:  len(hotels),
-		Offset: offset,
-		Limit:  limit,
+	return hotels, false, nil
+}
+
+func hotelFromLiteAPI(item liteAPIHotel, countryCode string) Hotel {
+	h := Hotel{
+		HotelID:     item.ID,
+		Name:        item.Name,
+		Address:     item.Address,
+		City:        item.City,
+		Country:     item.Country,
+		CountryCode: countryCode,
+		Stars:       item.Stars,
+		Latitude:    item.Latitude,
+		Longitude:   item.Longitude,
 	}
 
-	err = app.writeJSON(w, http.StatusOK, envelope{"data": response}, nil)
-	if err != nil {
-		app.logError(r, err)
-		app.errorResponse(w, r, http.StatusInternalServerError, "failed to encode response")
+	if h.CountryCode == "" {
+		h.CountryCode = strings.ToUpper(h.Country)
+	}
+
+	return h
+}
+
+// showHotelHandler serves GET /v1/hotels/:hotel_id. httprouter does not allow
+// a static segment next to a named parameter, so /v1/hotels/nearby lands here
+// too and is dispatched by hand.
+func (app *application) showHotelHandler(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	if params.ByName("hotel_id") == "nearby" {
+		app.nearbyHotelsHandler(w, r)
 		return
 	}
+
+	app.getHotelPriceHandler(w, r)
 }
 
 func (app *application) getHotelPriceHandler(w http.ResponseWriter, r *http.Request) {
@@ -184,24 +239,29 @@ func (app *application) getHotelPriceHandler(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	apiKey := r.Header.Get("X-API-KEY")
-	if apiKey == "" {
-		apiKey = r.Header.Get("Authorization")
-		if apiKey == "" {
-			app.errorResponse(w, r, http.StatusUnauthorized, "API key is required")
-			return
-		}
+	currency, ok := app.readDisplayCurrency(w, r)
+	if !ok {
+		return
 	}
 
+	guestNationality, ok := readGuestNationalityParam(r.URL.Query())
+	if !ok {
+		app.errorResponse(w, r, http.StatusBadRequest, "invalid guest_nationality parameter (must be an ISO 3166-1 alpha-2 country code)")
+		return
+	}
+
+	apiKey := app.liteAPIKey(r)
+
 	hotelName := fmt.Sprintf("Hotel %s", hotelID)
 
 	checkIn := time.Now().AddDate(0, 0, 30).Format("2006-01-02")
 	checkOut := time.Now().AddDate(0, 0, 31).Format("2006-01-02")
 
-	minPrice, err := app.getMinPriceFromAPI(hotelID, checkIn, checkOut, apiKey)
+	occupancy := Occupancy{Adults: 1, Children: []int{}}
+
+	minPrice, err := app.getMinPriceFromAPI(r.Context(), hotelID, checkIn, checkOut, occupancy, guestNationality, apiKey)
 	if err != nil {
-		app.logError(r, err)
-		app.errorResponse(w, r, http.StatusInternalServerError, "failed to get hotel rates")
+		app.upstreamErrorResponse(w, r, err, "failed to get hotel rates")
 		return
 	}
 	if minPrice <= 0 {
@@ -209,17 +269,28 @@ func (app *application) getHotelPriceHandler(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
+	conversion, err := app.fx.Convert(minPrice, liteAPICurrency, currency)
+	if err != nil {
+		app.convertPriceResponse(w, r, err)
+		return
+	}
+
 	response := map[string]interface{}{
 		"hotel_id":   hotelID,
 		"hotel_name": hotelName,
-		"price":      minPrice,
-		"currency":   "USD",
+		"price":      conversion.Amount,
+		"currency":   conversion.Currency,
 		"check_in":   checkIn,
 		"check_out":  checkOut,
 		"adults":     1,
 		"updated_at": time.Now().Format("2006-01-02T15:04:05Z"),
 	}
 
+	if conversion.FetchedAt != nil {
+		response["fx_rate"] = conversion.Rate
+		response["fx_rates_updated_at"] = conversion.FetchedAt.Format(time.RFC3339)
+	}
+
 	err = app.writeJSON(w, http.StatusOK, envelope{"data": response}, nil)
 	if err != nil {
 		app.logError(r, err)
@@ -228,71 +299,64 @@ func (app *application) getHotelPriceHandler(w http.ResponseWriter, r *http.Requ
 	}
 }
 
-func (app *application) getMinPriceFromAPI(hotelID, checkIn, checkOut, apiKey string) (float64, error) {
+func (app *application) getMinPriceFromAPI(ctx context.Context, hotelID, checkIn, checkOut string, occupancy Occupancy, guestNationality, apiKey string) (float64, error) {
 
-	requestData := MinRateSearchRequest{
-		HotelIds:         []string{hotelID},
-		Checkin:          checkIn,
-		Checkout:         checkOut,
-		Occupancies:      []Occupancy{{Adults: 1, Children: []int{}}},
-		Currency:         "USD",
-		GuestNationality: "US",
-		Timeout:          30,
-	}
-
-	jsonData, err := json.Marshal(requestData)
+	response, err := app.postMinRates(ctx, []string{hotelID}, checkIn, checkOut, occupancy, guestNationality, apiKey)
 	if err != nil {
-		return 0, fmt.Errorf("failed to marshal request: %v", err)
+		return 0, err
 	}
 
-	url := LITE_API_URL + "/hotels/min-rates"
-	req, err := http.NewRequest("POST", url, bytes.NewReader(jsonData))
-	if err != nil {
-		return 0, fmt.Errorf("failed to create request: %v", err)
+	minPrice := 0.0
+	for _, rate := range response.Data {
+		if minPrice == 0 || *rate.Price < minPrice {
+			minPrice = *rate.Price
+		}
 	}
 
-	req.Header.Add("accept", "application/json")
-	req.Header.Add("content-type", "application/json")
-	req.Header.Add("X-API-Key", apiKey)
-
-	res, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return 0, fmt.Errorf("failed to send request: %v", err)
-	}
-	defer res.Body.Close()
+	return minPrice, nil
+}
 
-	if res.StatusCode != 200 {
-		return 0, fmt.Errorf("API returned status %d", res.StatusCode)
-	}
+// getMinPricesFromAPI fetches min rates for several hotels in one LiteAPI
+// call. Hotels without availability are absent from the returned map.
+func (app *application) getMinPricesFromAPI(ctx context.Context, hotelIDs []string, checkIn, checkOut, guestNationality, apiKey string) (map[string]float64, error) {
 
-	body, err := io.ReadAll(res.Body)
+	response, err := app.postMinRates(ctx, hotelIDs, checkIn, checkOut, Occupancy{Adults: 1, Children: []int{}}, guestNationality, apiKey)
 	if err != nil {
-		return 0, fmt.Errorf("failed to read response: %v", err)
+		return nil, err
 	}
 
-	var response map[string]interface{}
-	if err := json.Unmarshal(body, &response); err != nil {
-		return 0, fmt.Errorf("failed to parse response: %v", err)
+	prices := make(map[string]float64)
+	for _, rate := range response.Data {
+		if *rate.Price <= 0 {
+			continue
+		}
+		if current, found := prices[rate.HotelID]; !found || *rate.Price < current {
+			prices[rate.HotelID] = *rate.Price
+		}
 	}
 
-	minPrice := app.extractMinPriceFromAPIResponse(response)
-	return minPrice, nil
+	return prices, nil
 }
 
-func (app *application) extractMinPriceFromAPIResponse(response map[string]interface{}) float64 {
-	minPrice := 0.0
+// postMinRates requests min rates in liteAPICurrency; callers convert them
+// with our own exchange rates.
+func (app *application) postMinRates(ctx context.Context, hotelIDs []string, checkIn, checkOut string, occupancy Occupancy, guestNationality, apiKey string) (*liteAPIMinRatesResponse, error) {
 
-	if data, ok := response["data"].([]interface{}); ok {
-		for _, item := range data {
-			if itemData, ok := item.(map[string]interface{}); ok {
-				if price, ok := itemData["price"].(float64); ok {
-					if minPrice == 0 || price < minPrice {
-						minPrice = price
-					}
-				}
-			}
-		}
+	requestData := MinRateSearchRequest{
+		HotelIds:         hotelIDs,
+		Checkin:          checkIn,
+		Checkout:         checkOut,
+		Occupancies:      []Occupancy{occupancy},
+		Currency:         liteAPICurrency,
+		GuestNationality: guestNationality,
+		Timeout:          30,
+	}
+
+	var response liteAPIMinRatesResponse
+	err := app.callLiteAPI(ctx, http.MethodPost, liteAPIEndpointMinRates, nil, requestData, apiKey, &response)
+	if err != nil {
+		return nil, err
 	}
 
-	return minPrice
+	return &response, nil
 }
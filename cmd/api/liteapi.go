@@ -0,0 +1,260 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const (
+	liteAPIEndpointHotels       = "/data/hotels"
+	liteAPIEndpointHotelDetails = "/data/hotel"
+	liteAPIEndpointMinRates     = "/hotels/min-rates"
+	liteAPIEndpointRates        = "/hotels/rates"
+)
+
+// liteAPIDecodeErrors counts LiteAPI responses that could not be decoded into
+// their typed structs, keyed by endpoint. A spike here usually means the
+// upstream schema changed.
+var liteAPIDecodeErrors = expvar.NewMap("liteapi_decode_errors")
+
+type liteAPIDecodeError struct {
+	Endpoint string
+	Err      error
+}
+
+func (e *liteAPIDecodeError) Error() string {
+	return fmt.Sprintf("failed to decode %s response: %v", e.Endpoint, e.Err)
+}
+
+func (e *liteAPIDecodeError) Unwrap() error {
+	return e.Err
+}
+
+// liteAPIResponse is implemented by every typed LiteAPI response. validate
+// rejects payloads that decoded cleanly but lack fields we depend on.
+type liteAPIResponse interface {
+	validate() error
+}
+
+type liteAPIHotel struct {
+	ID        string  `json:"id"`
+	Name      string  `json:"name"`
+	Address   string  `json:"address"`
+	City      string  `json:"city"`
+	Country   string  `json:"country"`
+	Stars     float64 `json:"stars"`
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+type liteAPIHotelsResponse struct {
+	Data []liteAPIHotel `json:"data"`
+}
+
+func (r *liteAPIHotelsResponse) validate() error {
+	if r.Data == nil {
+		return errors.New("missing data")
+	}
+	for i, hotel := range r.Data {
+		if hotel.ID == "" {
+			return fmt.Errorf("data[%d]: missing id", i)
+		}
+	}
+	return nil
+}
+
+type liteAPIHotelDetailsResponse struct {
+	Data *struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"data"`
+}
+
+func (r *liteAPIHotelDetailsResponse) validate() error {
+	if r.Data == nil {
+		return errors.New("missing data")
+	}
+	if r.Data.Name == "" {
+		return errors.New("data: missing name")
+	}
+	return nil
+}
+
+type liteAPIMinRate struct {
+	HotelID string   `json:"hotelId"`
+	Price   *float64 `json:"price"`
+}
+
+type liteAPIMinRatesResponse struct {
+	Data []liteAPIMinRate `json:"data"`
+}
+
+func (r *liteAPIMinRatesResponse) validate() error {
+	if r.Data == nil {
+		return errors.New("missing data")
+	}
+	for i, rate := range r.Data {
+		if rate.HotelID == "" {
+			return fmt.Errorf("data[%d]: missing hotelId", i)
+		}
+		if rate.Price == nil {
+			return fmt.Errorf("data[%d]: missing price", i)
+		}
+	}
+	return nil
+}
+
+func (r *liteAPIRatesResponse) validate() error {
+	if r.Data == nil {
+		return errors.New("missing data")
+	}
+	for i, hotel := range r.Data {
+		for j, roomType := range hotel.RoomTypes {
+			for k, rate := range roomType.Rates {
+				if len(rate.RetailRate.Total) == 0 {
+					return fmt.Errorf("data[%d].roomTypes[%d].rates[%d]: missing retailRate.total", i, j, k)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// decodeLiteAPIResponse strictly decodes body into dst. Type mismatches and
+// missing required fields are reported as *liteAPIDecodeError instead of
+// silently yielding zero values.
+func decodeLiteAPIResponse(endpoint string, body []byte, dst liteAPIResponse) error {
+	err := json.Unmarshal(body, dst)
+	if err == nil {
+		err = dst.validate()
+	}
+
+	if err != nil {
+		liteAPIDecodeErrors.Add(endpoint, 1)
+		return &liteAPIDecodeError{Endpoint: endpoint, Err: err}
+	}
+
+	return nil
+}
+
+// callLiteAPI sends a request to a LiteAPI endpoint and decodes the response
+// into dst. A nil payload sends no body. Responses that decode cleanly are
+// cached for the TTL configured for the endpoint, and identical concurrent
+// calls share a single upstream request, bounded by liteAPILoadTimeout rather
+// than by the context of the caller that started it. dst must not be used
+// when an error is returned.
+func (app *application) callLiteAPI(ctx context.Context, method, endpoint string, query url.Values, payload interface{}, apiKey string, dst liteAPIResponse) error {
+	var jsonData []byte
+
+	if payload != nil {
+		var err error
+		jsonData, err = json.Marshal(payload)
+		if err != nil {
+			return fmt.Errorf("failed to marshal request: %v", err)
+		}
+	}
+
+	key := liteAPICacheKey(method, query, jsonData, apiKey)
+	ttl := app.config.cache.ttls[endpoint]
+
+	// decoded is set when this call's own load already decoded into dst.
+	decoded := false
+
+	data, err := app.cache.Fetch(ctx, endpoint, key, ttl, func(ctx context.Context) ([]byte, error) {
+		ctx, cancel := context.WithTimeout(ctx, app.liteAPILoadTimeout())
+		defer cancel()
+
+		data, err := app.sendLiteAPIRequest(ctx, method, endpoint, query, jsonData, apiKey)
+		if err != nil {
+			return nil, err
+		}
+
+		err = decodeLiteAPIResponse(endpoint, data, dst)
+		if err != nil {
+			app.logger.PrintError(err, map[string]string{
+				"endpoint": endpoint,
+			})
+			return nil, err
+		}
+
+		decoded = true
+
+		return data, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if decoded {
+		return nil
+	}
+
+	return decodeLiteAPIResponse(endpoint, data, dst)
+}
+
+// liteAPILoadTimeout is the longest a LiteAPI call may take with every retry:
+// each attempt may use the upstream timeout and wait up to the maximum
+// backoff before the next one.
+func (app *application) liteAPILoadTimeout() time.Duration {
+	retries := time.Duration(app.config.upstream.retries)
+	return (retries+1)*app.config.upstream.timeout + retries*upstreamMaxBackoff
+}
+
+func (app *application) sendLiteAPIRequest(ctx context.Context, method, endpoint string, query url.Values, payload []byte, apiKey string) ([]byte, error) {
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+
+	target := app.config.liteAPIURL + endpoint
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %v", err)
+	}
+
+	req.Header.Add("accept", "application/json")
+	if payload != nil {
+		req.Header.Add("content-type", "application/json")
+	}
+	req.Header.Add("X-API-Key", apiKey)
+
+	res, err := app.upstream.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("API returned status %d", res.StatusCode)
+	}
+
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %v", err)
+	}
+
+	return data, nil
+}
+
+// liteAPICacheKey identifies a LiteAPI call. The API key is part of it since
+// rates may differ between supplier accounts.
+func liteAPICacheKey(method string, query url.Values, payload []byte, apiKey string) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n%s\n%s\n%s", method, query.Encode(), payload, apiKey)
+
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestDecodeLiteAPIResponse(t *testing.T) {
+	tests := []struct {
+		name     string
+		endpoint string
+		body     string
+		dst      liteAPIResponse
+		expected bool
+	}{
+		{
+			name:     "valid min rates",
+			endpoint: liteAPIEndpointMinRates,
+			body:     `{"data": [{"hotelId": "lp1897", "price": 120.5}]}`,
+			dst:      &liteAPIMinRatesResponse{},
+			expected: true,
+		},
+		{
+			name:     "empty min rates",
+			endpoint: liteAPIEndpointMinRates,
+			body:     `{"data": []}`,
+			dst:      &liteAPIMinRatesResponse{},
+			expected: true,
+		},
+		{
+			name:     "price as string",
+			endpoint: liteAPIEndpointMinRates,
+			body:     `{"data": [{"hotelId": "lp1897", "price": "120.5"}]}`,
+			dst:      &liteAPIMinRatesResponse{},
+			expected: false,
+		},
+		{
+			name:     "price nested in offers",
+			endpoint: liteAPIEndpointMinRates,
+			body:     `{"data": [{"hotelId": "lp1897", "offers": [{"price": 120.5}]}]}`,
+			dst:      &liteAPIMinRatesResponse{},
+			expected: false,
+		},
+		{
+			name:     "missing data",
+			endpoint: liteAPIEndpointMinRates,
+			body:     `{"error": {"code": 4000, "message": "invalid request"}}`,
+			dst:      &liteAPIMinRatesResponse{},
+			expected: false,
+		},
+		{
+			name:     "valid hotels",
+			endpoint: liteAPIEndpointHotels,
+			body:     `{"data": [{"id": "lp1897", "name": "Hotel", "stars": 4, "latitude": 38.7, "longitude": -9.1}]}`,
+			dst:      &liteAPIHotelsResponse{},
+			expected: true,
+		},
+		{
+			name:     "hotel without id",
+			endpoint: liteAPIEndpointHotels,
+			body:     `{"data": [{"name": "Hotel"}]}`,
+			dst:      &liteAPIHotelsResponse{},
+			expected: false,
+		},
+		{
+			name:     "hotel stars as string",
+			endpoint: liteAPIEndpointHotels,
+			body:     `{"data": [{"id": "lp1897", "stars": "4"}]}`,
+			dst:      &liteAPIHotelsResponse{},
+			expected: false,
+		},
+		{
+			name:     "hotel details without name",
+			endpoint: liteAPIEndpointHotelDetails,
+			body:     `{"data": {"id": "lp1897"}}`,
+			dst:      &liteAPIHotelDetailsResponse{},
+			expected: false,
+		},
+		{
+			name:     "rate without total",
+			endpoint: liteAPIEndpointRates,
+			body:     `{"data": [{"hotelId": "lp1897", "roomTypes": [{"offerId": "o1", "rates": [{"rateId": "r1", "retailRate": {}}]}]}]}`,
+			dst:      &liteAPIRatesResponse{},
+			expected: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := decodeLiteAPIResponse(tt.endpoint, []byte(tt.body), tt.dst)
+
+			if (err == nil) != tt.expected {
+				t.Fatalf("decodeLiteAPIResponse() error = %v, expected valid = %v", err, tt.expected)
+			}
+
+			var decodeErr *liteAPIDecodeError
+			if err != nil && (!errors.As(err, &decodeErr) || decodeErr.Endpoint != tt.endpoint) {
+				t.Errorf("expected *liteAPIDecodeError for %s, got %T", tt.endpoint, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"time"
+
+	models "github.com/madfelps/challenge-nuitee/internal/data"
+)
+
+const (
+	// loginFreeFailures is how many failed logins an account gets before
+	// each further attempt must wait.
+	loginFreeFailures = 2
+	loginMaxDelay     = 30 * time.Second
+)
+
+// loginDelay returns how long an account must wait after its last failed
+// login, given how many failed in a row: nothing for the first
+// loginFreeFailures, then 1s doubling up to loginMaxDelay.
+func loginDelay(failures int) time.Duration {
+	if failures <= loginFreeFailures {
+		return 0
+	}
+
+	shift := failures - loginFreeFailures - 1
+	if shift >= 5 {
+		return loginMaxDelay
+	}
+
+	return min(time.Second<<shift, loginMaxDelay)
+}
+
+// loginRetryAfter returns how long a login attempt must wait given the
+// recent failures of its account and IP address, or zero when it may go
+// ahead. An account reaching -login-max-failures is locked for
+// -login-lockout after its last failure, and so is an IP address reaching
+// -login-ip-max-failures.
+func (app *application) loginRetryAfter(failures models.LoginFailures, now time.Time) time.Duration {
+	ipWait := time.Duration(0)
+	if failures.IP >= app.config.login.ipMaxFailures {
+		ipWait = failures.LastIP.Add(app.config.login.lockout).Sub(now)
+	}
+
+	wait := loginDelay(failures.Account)
+	if failures.Account >= app.config.login.maxFailures {
+		wait = app.config.login.lockout
+	}
+
+	return max(failures.LastAccount.Add(wait).Sub(now), ipWait, 0)
+}
+
+// loginEmail normalizes an email address for login throttling, so changing
+// its case does not reset the count.
+func loginEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// clientIP returns the address r came from. Behind a trusted proxy that is
+// the rightmost X-Forwarded-For entry that is not a trusted proxy itself:
+// entries further left were sent by the client and may be forged.
+func (app *application) clientIP(r *http.Request) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+
+	peer, err := netip.ParseAddr(ip)
+	if err != nil || !app.trustedProxy(peer) {
+		return ip
+	}
+
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			break
+		}
+		if !app.trustedProxy(hop) {
+			return hop.String()
+		}
+	}
+
+	return ip
+}
+
+func (app *application) trustedProxy(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, prefix := range app.config.trustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseTrustedProxies parses the -trusted-proxies flag: comma-separated
+// CIDRs or single addresses.
+func parseTrustedProxies(value string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+
+	for _, field := range strings.Split(value, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+
+		if !strings.Contains(field, "/") {
+			addr, err := netip.ParseAddr(field)
+			if err != nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q: %v", field, err)
+			}
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+
+		prefix, err := netip.ParsePrefix(field)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %v", field, err)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+
+	return prefixes, nil
+}
+
+// recordLoginAttempt writes the audit record of a login attempt. Failing to
+// write it is logged but does not fail the request.
+func (app *application) recordLoginAttempt(r *http.Request, email string, user *models.User, outcome string) {
+	attempt := &models.LoginAttempt{
+		Email:   loginEmail(email),
+		IP:      app.clientIP(r),
+		Outcome: outcome,
+	}
+	if user != nil {
+		attempt.UserID = &user.ID
+	}
+
+	err := app.models.LoginAttempts.Insert(attempt)
+	if err != nil {
+		app.logError(r, err)
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	models "github.com/madfelps/challenge-nuitee/internal/data"
+)
+
+func TestLoginDelay(t *testing.T) {
+	tests := []struct {
+		failures int
+		expected time.Duration
+	}{
+		{failures: 0, expected: 0},
+		{failures: 2, expected: 0},
+		{failures: 3, expected: time.Second},
+		{failures: 4, expected: 2 * time.Second},
+		{failures: 7, expected: 16 * time.Second},
+		{failures: 8, expected: 30 * time.Second},
+		{failures: 100, expected: 30 * time.Second},
+	}
+
+	for _, tt := range tests {
+		if got := loginDelay(tt.failures); got != tt.expected {
+			t.Errorf("loginDelay(%d) = %s, expected %s", tt.failures, got, tt.expected)
+		}
+	}
+}
+
+func TestLoginRetryAfter(t *testing.T) {
+	app := &application{}
+	app.config.login.maxFailures = 10
+	app.config.login.ipMaxFailures = 50
+	app.config.login.lockout = 15 * time.Minute
+
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		failures models.LoginFailures
+		expected time.Duration
+	}{
+		{name: "no failures", failures: models.LoginFailures{}, expected: 0},
+		{name: "few failures", failures: models.LoginFailures{Account: 2, LastAccount: now}, expected: 0},
+		{name: "delay pending", failures: models.LoginFailures{Account: 4, LastAccount: now.Add(-500 * time.Millisecond)}, expected: 1500 * time.Millisecond},
+		{name: "delay elapsed", failures: models.LoginFailures{Account: 4, LastAccount: now.Add(-time.Minute)}, expected: 0},
+		{name: "account locked", failures: models.LoginFailures{Account: 10, LastAccount: now.Add(-5 * time.Minute)}, expected: 10 * time.Minute},
+		{name: "ip blocked", failures: models.LoginFailures{IP: 50, LastIP: now.Add(-5 * time.Minute)}, expected: 10 * time.Minute},
+		{name: "ip lockout elapsed", failures: models.LoginFailures{IP: 50, LastIP: now.Add(-20 * time.Minute)}, expected: 0},
+		{name: "ip below limit", failures: models.LoginFailures{IP: 49, LastIP: now}, expected: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := app.loginRetryAfter(tt.failures, now); got != tt.expected {
+				t.Errorf("loginRetryAfter() = %s, expected %s", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	proxies, err := parseTrustedProxies("10.0.0.0/8, 192.168.1.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	app := &application{}
+	app.config.trustedProxies = proxies
+
+	tests := []struct {
+		name         string
+		remoteAddr   string
+		forwardedFor []string
+		expected     string
+	}{
+		{name: "direct client", remoteAddr: "203.0.113.7:5000", expected: "203.0.113.7"},
+		{name: "untrusted peer", remoteAddr: "203.0.113.7:5000", forwardedFor: []string{"198.51.100.1"}, expected: "203.0.113.7"},
+		{name: "trusted proxy", remoteAddr: "10.1.2.3:5000", forwardedFor: []string{"198.51.100.1"}, expected: "198.51.100.1"},
+		{name: "forged entries", remoteAddr: "10.1.2.3:5000", forwardedFor: []string{"1.2.3.4, 198.51.100.1"}, expected: "198.51.100.1"},
+		{name: "proxy chain", remoteAddr: "10.1.2.3:5000", forwardedFor: []string{"198.51.100.1", "192.168.1.1"}, expected: "198.51.100.1"},
+		{name: "no header", remoteAddr: "10.1.2.3:5000", expected: "10.1.2.3"},
+		{name: "malformed header", remoteAddr: "10.1.2.3:5000", forwardedFor: []string{"unknown"}, expected: "10.1.2.3"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/v1/tokens/authentication", nil)
+			r.RemoteAddr = tt.remoteAddr
+			for _, value := range tt.forwardedFor {
+				r.Header.Add("X-Forwarded-For", value)
+			}
+
+			if got := app.clientIP(r); got != tt.expected {
+				t.Errorf("clientIP() = %s, expected %s", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestParseTrustedProxies(t *testing.T) {
+	if _, err := parseTrustedProxies("10.0.0.0/8,not-an-ip"); err == nil {
+		t.Error("expected an error for an invalid proxy")
+	}
+
+	proxies, err := parseTrustedProxies("")
+	if err != nil || len(proxies) != 0 {
+		t.Errorf("parseTrustedProxies(\"\") = %v, %v, expected no proxies", proxies, err)
+	}
+}
@@ -3,15 +3,24 @@ package main
 import (
 	"context"
 	"database/sql"
+	"expvar"
 	"flag"
+	"fmt"
 	"log"
+	"net/netip"
 	"os"
 	"sync"
 	"time"
+	_ "time/tzdata"
 
-	liteapi "github.com/liteapi-travel/go-sdk/v3"
+	"github.com/madfelps/challenge-nuitee/internal/auth"
+	"github.com/madfelps/challenge-nuitee/internal/cache"
 	models "github.com/madfelps/challenge-nuitee/internal/data"
+	"github.com/madfelps/challenge-nuitee/internal/fx"
 	"github.com/madfelps/challenge-nuitee/internal/jsonlog"
+	"github.com/madfelps/challenge-nuitee/internal/mailer"
+	"github.com/madfelps/challenge-nuitee/internal/secrets"
+	"github.com/madfelps/challenge-nuitee/internal/upstream"
 
 	_ "github.com/lib/pq"
 )
@@ -19,6 +28,9 @@ import (
 const version = "1.0.0"
 const LITE_API_URL = "https://api.liteapi.travel/v3.0"
 
+// upstreamMaxBackoff caps the delay between two LiteAPI call attempts.
+const upstreamMaxBackoff = 5 * time.Second
+
 type config struct {
 	port int
 	env  string
@@ -32,15 +44,70 @@ type config struct {
 		enabled bool
 	}
 
-	apiKey string
+	upstream struct {
+		timeout          time.Duration
+		retries          int
+		breakerThreshold int
+		breakerCooldown  time.Duration
+		rps              float64
+		burst            int
+		dailyQuota       int
+		quotaSoftLimit   float64
+		quotaReserve     float64
+	}
+
+	cache struct {
+		maxEntries int
+		redisURL   string
+		ttls       map[string]time.Duration
+	}
+
+	jwt struct {
+		algorithm  string
+		key        string
+		issuer     string
+		ttl        time.Duration
+		refreshTTL time.Duration
+	}
+
+	fx struct {
+		source          string
+		refreshInterval time.Duration
+	}
+
+	login struct {
+		maxFailures   int
+		ipMaxFailures int
+		lockout       time.Duration
+	}
+
+	// trustedProxies are the networks of the reverse proxies in front of the
+	// API, whose X-Forwarded-For header is believed.
+	trustedProxies []netip.Prefix
+
+	smtp struct {
+		host     string
+		port     int
+		username string
+		password string
+		sender   string
+	}
+
+	apiKey     string
+	liteAPIURL string
 }
 
 type application struct {
-	config    config
-	logger    *jsonlog.Logger
-	apiClient *liteapi.APIClient
-	db        *sql.DB
-	models    models.Models
+	config   config
+	logger   *jsonlog.Logger
+	db       *sql.DB
+	models   models.Models
+	upstream *upstream.Client
+	cache    *cache.Cache
+	secrets  *secrets.Cipher
+	fx       *fx.Converter
+	tokens   *auth.Issuer
+	mailer   mailer.Mailer
 
 	wg sync.WaitGroup
 }
@@ -59,9 +126,65 @@ func main() {
 	flag.IntVar(&cfg.limiter.burst, "limiter-burst", 4, "Rate limiter maximum burst")
 	flag.BoolVar(&cfg.limiter.enabled, "limiter-enabled", true, "Enable rate limiter")
 
+	flag.DurationVar(&cfg.upstream.timeout, "upstream-timeout", 15*time.Second, "Timeout for each LiteAPI call attempt")
+	flag.IntVar(&cfg.upstream.retries, "upstream-retries", 2, "Retries for LiteAPI calls failing with 429, 5xx or network errors")
+	flag.IntVar(&cfg.upstream.breakerThreshold, "upstream-breaker-threshold", 5, "Consecutive LiteAPI failures before the circuit breaker opens")
+	flag.DurationVar(&cfg.upstream.breakerCooldown, "upstream-breaker-cooldown", 30*time.Second, "Time the circuit breaker stays open before probing LiteAPI again")
+	flag.Float64Var(&cfg.upstream.rps, "upstream-rps", 5, "Maximum LiteAPI calls per second (0 disables the limit)")
+	flag.IntVar(&cfg.upstream.burst, "upstream-burst", 10, "Maximum burst of LiteAPI calls")
+	flag.IntVar(&cfg.upstream.dailyQuota, "upstream-daily-quota", 0, "LiteAPI calls allowed per UTC day (0 disables quota tracking)")
+	flag.Float64Var(&cfg.upstream.quotaSoftLimit, "upstream-quota-soft-limit", 0.8, "Fraction of the daily quota after which the price monitor slows down")
+	flag.Float64Var(&cfg.upstream.quotaReserve, "upstream-quota-reserve", 0.1, "Fraction of the daily quota reserved for interactive requests")
+
+	flag.StringVar(&cfg.liteAPIURL, "liteapi-url", LITE_API_URL, "LiteAPI base URL")
+
+	var hotelsTTL, hotelDetailsTTL, minRatesTTL, ratesTTL time.Duration
+
+	flag.IntVar(&cfg.cache.maxEntries, "cache-max-entries", 10000, "Maximum LiteAPI responses kept in the in-process cache")
+	flag.StringVar(&cfg.cache.redisURL, "cache-redis-url", os.Getenv("CACHE_REDIS_URL"), "Redis-compatible server shared by replicas for the LiteAPI cache (in-process cache when empty)")
+	flag.DurationVar(&hotelsTTL, "cache-hotels-ttl", time.Hour, "Cache TTL for hotel lists (0 disables caching)")
+	flag.DurationVar(&hotelDetailsTTL, "cache-hotel-details-ttl", 24*time.Hour, "Cache TTL for hotel details (0 disables caching)")
+	flag.DurationVar(&minRatesTTL, "cache-min-rates-ttl", 5*time.Minute, "Cache TTL for min rates (0 disables caching)")
+	flag.DurationVar(&ratesTTL, "cache-rates-ttl", 2*time.Minute, "Cache TTL for full rates (0 disables caching)")
+
+	flag.StringVar(&cfg.fx.source, "fx-source", os.Getenv("FX_RATES_SOURCE"), "File path or http(s) URL exchange rates are loaded from (stored rates only when empty)")
+	flag.DurationVar(&cfg.fx.refreshInterval, "fx-refresh-interval", 6*time.Hour, "Interval between exchange rate refreshes")
+
+	flag.StringVar(&cfg.jwt.algorithm, "jwt-algorithm", auth.AlgorithmHS256, "Access token signing algorithm (HS256 or EdDSA)")
+	flag.StringVar(&cfg.jwt.issuer, "jwt-issuer", "challenge-nuitee", "Issuer and audience of access tokens")
+	flag.DurationVar(&cfg.jwt.ttl, "jwt-ttl", 15*time.Minute, "Access token lifetime")
+	flag.DurationVar(&cfg.jwt.refreshTTL, "refresh-token-ttl", 30*24*time.Hour, "Refresh token lifetime, renewed on every rotation")
+
+	flag.IntVar(&cfg.login.maxFailures, "login-max-failures", 10, "Failed logins after which an account is locked")
+	flag.IntVar(&cfg.login.ipMaxFailures, "login-ip-max-failures", 50, "Failed logins from one IP address after which it cannot log in")
+	flag.DurationVar(&cfg.login.lockout, "login-lockout", 15*time.Minute, "How long failed logins are counted and how long a lockout lasts")
+
+	var trustedProxies string
+	flag.StringVar(&trustedProxies, "trusted-proxies", os.Getenv("TRUSTED_PROXIES"), "Comma-separated CIDRs of reverse proxies whose X-Forwarded-For header identifies the client")
+
+	flag.StringVar(&cfg.smtp.host, "smtp-host", os.Getenv("SMTP_HOST"), "SMTP server host (emails are only logged when empty)")
+	flag.IntVar(&cfg.smtp.port, "smtp-port", 587, "SMTP server port")
+	flag.StringVar(&cfg.smtp.username, "smtp-username", os.Getenv("SMTP_USERNAME"), "SMTP username")
+	flag.StringVar(&cfg.smtp.sender, "smtp-sender", "Hotel Price Monitor <no-reply@challenge-nuitee.local>", "SMTP sender")
+
+	createTenant := flag.String("create-tenant", "", "Create an API tenant with this name, print its API key and exit (its own LiteAPI key is read from TENANT_LITE_API_KEY)")
+
+	grantAdmin := flag.String("grant-admin", "", "Grant the admin role to the user with this email and exit")
+
 	flag.Parse()
 
-	configuration := liteapi.NewConfiguration()
+	cfg.cache.ttls = map[string]time.Duration{
+		liteAPIEndpointHotels:       hotelsTTL,
+		liteAPIEndpointHotelDetails: hotelDetailsTTL,
+		liteAPIEndpointMinRates:     minRatesTTL,
+		liteAPIEndpointRates:        ratesTTL,
+	}
+
+	proxies, err := parseTrustedProxies(trustedProxies)
+	if err != nil {
+		log.Fatal(err)
+	}
+	cfg.trustedProxies = proxies
 
 	apiKey := os.Getenv("LITE_API_KEY")
 
@@ -71,8 +194,17 @@ func main() {
 
 	cfg.apiKey = apiKey
 
-	configuration.AddDefaultHeader("X-API-KEY", apiKey)
-	apiClient := liteapi.NewAPIClient(configuration)
+	cfg.smtp.password = os.Getenv("SMTP_PASSWORD")
+
+	cfg.jwt.key = os.Getenv("JWT_SIGNING_KEY")
+	if cfg.jwt.key == "" {
+		log.Fatal("Environment variable JWT_SIGNING_KEY is not set")
+	}
+
+	tokenIssuer, err := auth.NewIssuer(cfg.jwt.algorithm, cfg.jwt.key, cfg.jwt.issuer, cfg.jwt.ttl)
+	if err != nil {
+		log.Fatal(err)
+	}
 
 	logger := jsonlog.New(os.Stdout, jsonlog.LevelInfo)
 
@@ -84,14 +216,122 @@ func main() {
 
 	logger.PrintInfo("database connection pool established with success", nil)
 
+	var cipher *secrets.Cipher
+
+	if encryptionKey := os.Getenv("CREDENTIALS_ENCRYPTION_KEY"); encryptionKey != "" {
+		cipher, err = secrets.NewFromBase64(encryptionKey)
+		if err != nil {
+			logger.PrintFatal(err, nil)
+		}
+	}
+
+	upstreamClient, err := upstream.New(upstream.Config{
+		Timeout:          cfg.upstream.timeout,
+		MaxRetries:       cfg.upstream.retries,
+		BaseBackoff:      200 * time.Millisecond,
+		MaxBackoff:       upstreamMaxBackoff,
+		BreakerThreshold: cfg.upstream.breakerThreshold,
+		BreakerCooldown:  cfg.upstream.breakerCooldown,
+		Budget: upstream.BudgetConfig{
+			RPS:                cfg.upstream.rps,
+			Burst:              cfg.upstream.burst,
+			DailyQuota:         cfg.upstream.dailyQuota,
+			SoftLimit:          cfg.upstream.quotaSoftLimit,
+			InteractiveReserve: cfg.upstream.quotaReserve,
+		},
+	})
+	if err != nil {
+		logger.PrintFatal(err, nil)
+	}
+
+	upstreamClient.Breaker.OnStateChange(func(state upstream.State) {
+		logger.PrintInfo("liteapi circuit breaker state changed", map[string]string{
+			"state": state.String(),
+		})
+	})
+
+	expvar.Publish("liteapi_circuit_breaker", expvar.Func(func() interface{} {
+		return upstreamClient.Breaker.State().String()
+	}))
+
+	expvar.Publish("liteapi_daily_quota", expvar.Func(func() interface{} {
+		used, quota := upstreamClient.Budget.Usage()
+		return map[string]int{
+			"used":  used,
+			"quota": quota,
+		}
+	}))
+
+	var store cache.Store
+
+	if cfg.cache.redisURL != "" {
+		redisStore, err := cache.NewRedisStore(cfg.cache.redisURL, "liteapi:")
+		if err != nil {
+			logger.PrintFatal(err, nil)
+		}
+		defer redisStore.Close()
+
+		store = redisStore
+		logger.PrintInfo("using redis cache for LiteAPI responses", nil)
+	} else {
+		memoryStore := cache.NewMemoryStore(cfg.cache.maxEntries)
+
+		expvar.Publish("liteapi_cache_entries", expvar.Func(func() interface{} {
+			return memoryStore.Len()
+		}))
+
+		store = memoryStore
+	}
+
 	app := &application{
-		config:    cfg,
-		logger:    logger,
-		apiClient: apiClient,
-		db:        db,
-		models:    models.NewModels(db),
+		config:   cfg,
+		logger:   logger,
+		db:       db,
+		models:   models.NewModels(db),
+		upstream: upstreamClient,
+		cache:    cache.New(store, expvar.NewMap("liteapi_cache")),
+		secrets:  cipher,
+		fx:       fx.NewConverter(),
+		tokens:   tokenIssuer,
+		mailer:   newMailer(cfg, logger),
+	}
+
+	if *grantAdmin != "" {
+		user, err := app.models.Users.GetByEmail(*grantAdmin)
+		if err != nil {
+			logger.PrintFatal(err, map[string]string{"email": *grantAdmin})
+		}
+
+		err = app.models.Roles.AddForUser(user.ID, models.RoleAdmin)
+		if err != nil {
+			logger.PrintFatal(err, nil)
+		}
+
+		logger.PrintInfo("admin role granted", map[string]string{"email": *grantAdmin})
+		return
+	}
+
+	if *createTenant != "" {
+		key, err := app.createTenant(*createTenant, os.Getenv("TENANT_LITE_API_KEY"))
+		if err != nil {
+			logger.PrintFatal(err, nil)
+		}
+
+		fmt.Println(key)
+		return
+	}
+
+	err = app.loadStoredFXRates()
+	if err != nil {
+		logger.PrintError(err, nil)
 	}
 
+	app.wg.Add(1)
+	go func() {
+		defer app.wg.Done()
+		app.StartFXRefresher()
+	}()
+
 	app.wg.Add(1)
 	go func() {
 		defer app.wg.Done()
@@ -107,6 +347,21 @@ func main() {
 
 }
 
+// newMailer returns an SMTP mailer, or one that only logs the emails it
+// would send when no SMTP host is configured.
+func newMailer(cfg config, logger *jsonlog.Logger) mailer.Mailer {
+	if cfg.smtp.host != "" {
+		return mailer.NewSMTP(cfg.smtp.host, cfg.smtp.port, cfg.smtp.username, cfg.smtp.password, cfg.smtp.sender)
+	}
+
+	return mailer.NewLog(func(msg mailer.Message) {
+		logger.PrintInfo("email not sent, no SMTP host configured", map[string]string{
+			"recipient": msg.Recipient,
+			"subject":   msg.Subject,
+		})
+	})
+}
+
 func openDB(cfg config) (*sql.DB, error) {
 	db, err := sql.Open("postgres", cfg.db.dsn)
 	if err != nil {
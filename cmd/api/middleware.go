@@ -1,12 +1,18 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"net"
 	"net/http"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/julienschmidt/httprouter"
+	models "github.com/madfelps/challenge-nuitee/internal/data"
+
 	"golang.org/x/time/rate"
 )
 
@@ -82,3 +88,202 @@ func (app *application) recoverPanic(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r)
 	})
 }
+
+// authenticateTenant identifies the API client from the X-API-KEY header.
+// Requests without the header are served anonymously with the server's
+// LiteAPI key; an unknown key is rejected.
+func (app *application) authenticateTenant(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "X-API-KEY")
+
+		apiKey := r.Header.Get("X-API-KEY")
+		if apiKey == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		t, err := app.loadTenant(apiKey)
+		if err != nil {
+			switch {
+			case errors.Is(err, models.ErrRecordNotFound):
+				app.invalidAPIKeyResponse(w, r)
+			default:
+				app.serverErrorResponse(w, r, err)
+			}
+			return
+		}
+
+		next.ServeHTTP(w, app.contextSetTenant(r, t))
+	})
+}
+
+// authenticate identifies the user from an "Authorization: Bearer <token>"
+// access token. Requests without the header continue as the anonymous user.
+func (app *application) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Authorization")
+
+		authorizationHeader := r.Header.Get("Authorization")
+		if authorizationHeader == "" {
+			next.ServeHTTP(w, app.contextSetUser(r, models.AnonymousUser))
+			return
+		}
+
+		headerParts := strings.Split(authorizationHeader, " ")
+		if len(headerParts) != 2 || headerParts[0] != "Bearer" {
+			app.invalidAuthenticationTokenResponse(w, r)
+			return
+		}
+
+		if strings.HasPrefix(headerParts[1], models.APIKeyPrefix) {
+			app.authenticateAPIKey(w, r, headerParts[1], next)
+			return
+		}
+
+		userID, err := app.tokens.Verify(headerParts[1])
+		if err != nil {
+			app.invalidAuthenticationTokenResponse(w, r)
+			return
+		}
+
+		user, err := app.models.Users.Get(userID)
+		if err != nil {
+			switch {
+			case errors.Is(err, models.ErrRecordNotFound):
+				app.invalidAuthenticationTokenResponse(w, r)
+			default:
+				app.serverErrorResponse(w, r, err)
+			}
+			return
+		}
+
+		next.ServeHTTP(w, app.contextSetUser(r, user))
+	})
+}
+
+// authenticateAPIKey authenticates r as the owner of a personal API key,
+// provided the key's scopes allow the request method.
+func (app *application) authenticateAPIKey(w http.ResponseWriter, r *http.Request, plaintext string, next http.Handler) {
+	key, err := app.models.APIKeys.GetForPlaintext(plaintext)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrRecordNotFound):
+			app.invalidAuthenticationTokenResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if !key.Allows(r.Method) {
+		app.notPermittedResponse(w, r)
+		return
+	}
+
+	user, err := app.models.Users.Get(key.UserID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.models.APIKeys.Touch(key.ID)
+	if err != nil {
+		app.logError(r, err)
+	}
+
+	r = app.contextSetAPIKey(r, key)
+	next.ServeHTTP(w, app.contextSetUser(r, user))
+}
+
+// requireSession only lets users who authenticated with an access token,
+// not a personal API key, reach next. Keys cannot manage keys or roles.
+func (app *application) requireSession(next http.HandlerFunc) http.HandlerFunc {
+	return app.requireAuthenticatedUser(func(w http.ResponseWriter, r *http.Request) {
+		if app.contextGetAPIKey(r) != nil {
+			app.notPermittedResponse(w, r)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (app *application) requireAuthenticatedUser(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if app.contextGetUser(r).IsAnonymous() {
+			app.authenticationRequiredResponse(w, r)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	}
+}
+
+// requireOwner only lets the authenticated user reach routes naming a user
+// in the param URL parameter, such as /v1/users/:id/preferences, when that
+// user is themselves.
+func (app *application) requireOwner(param string, next http.HandlerFunc) http.HandlerFunc {
+	return app.requireAuthenticatedUser(func(w http.ResponseWriter, r *http.Request) {
+		params := httprouter.ParamsFromContext(r.Context())
+
+		userID, err := strconv.Atoi(params.ByName(param))
+		if err != nil || userID <= 0 {
+			app.errorResponse(w, r, http.StatusBadRequest, fmt.Sprintf("invalid %s parameter", param))
+			return
+		}
+
+		if userID != app.contextGetUser(r).ID {
+			app.notPermittedResponse(w, r)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requireMember only lets members of the organization in the :org_id URL
+// parameter reach next, and stores their membership in the request context.
+// Organizations the user is not a member of are reported as not found.
+func (app *application) requireMember(next http.HandlerFunc) http.HandlerFunc {
+	return app.requireAuthenticatedUser(func(w http.ResponseWriter, r *http.Request) {
+		params := httprouter.ParamsFromContext(r.Context())
+
+		organizationID, err := strconv.Atoi(params.ByName("org_id"))
+		if err != nil || organizationID <= 0 {
+			app.errorResponse(w, r, http.StatusBadRequest, "invalid org_id parameter")
+			return
+		}
+
+		member, err := app.models.Organizations.GetMember(organizationID, app.contextGetUser(r).ID)
+		if err != nil {
+			switch {
+			case errors.Is(err, models.ErrRecordNotFound):
+				app.notFoundResponse(w, r)
+			default:
+				app.serverErrorResponse(w, r, err)
+			}
+			return
+		}
+
+		next.ServeHTTP(w, app.contextSetMember(r, member))
+	})
+}
+
+// requirePermission only lets authenticated users holding code, through any
+// of their roles, reach next.
+func (app *application) requirePermission(code string, next http.HandlerFunc) http.HandlerFunc {
+	return app.requireAuthenticatedUser(func(w http.ResponseWriter, r *http.Request) {
+		permissions, err := app.models.Permissions.GetAllForUser(app.contextGetUser(r).ID)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		if !permissions.Include(code) {
+			app.notPermittedResponse(w, r)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,303 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/madfelps/challenge-nuitee/internal/auth"
+	models "github.com/madfelps/challenge-nuitee/internal/data"
+)
+
+func TestAuthenticate(t *testing.T) {
+	issuer, err := auth.NewIssuer(auth.AlgorithmHS256, strings.Repeat("k", 32), "test", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	app := &application{tokens: issuer}
+
+	foreign, _ := auth.NewIssuer(auth.AlgorithmHS256, strings.Repeat("x", 32), "test", time.Minute)
+	foreignToken, _, _ := foreign.Issue(1)
+
+	tests := []struct {
+		name          string
+		authorization string
+		expected      int
+	}{
+		{name: "no header is anonymous", authorization: "", expected: http.StatusOK},
+		{name: "not a bearer token", authorization: "Basic dXNlcjpwYXNz", expected: http.StatusUnauthorized},
+		{name: "malformed header", authorization: "Bearer", expected: http.StatusUnauthorized},
+		{name: "garbage token", authorization: "Bearer abc.def.ghi", expected: http.StatusUnauthorized},
+		{name: "token signed with another key", authorization: "Bearer " + foreignToken, expected: http.StatusUnauthorized},
+		{name: "malformed api key", authorization: "Bearer hpm_abc", expected: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var anonymous bool
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				anonymous = app.contextGetUser(r) == models.AnonymousUser
+			})
+
+			r := httptest.NewRequest(http.MethodGet, "/v1/healthcheck", nil)
+			if tt.authorization != "" {
+				r.Header.Set("Authorization", tt.authorization)
+			}
+			w := httptest.NewRecorder()
+
+			app.authenticate(next).ServeHTTP(w, r)
+
+			if w.Code != tt.expected {
+				t.Errorf("status = %d, expected %d", w.Code, tt.expected)
+			}
+			if tt.expected == http.StatusOK && !anonymous {
+				t.Error("expected the anonymous user in the context")
+			}
+			if tt.expected == http.StatusUnauthorized && w.Header().Get("WWW-Authenticate") != "Bearer" {
+				t.Error("missing WWW-Authenticate header")
+			}
+		})
+	}
+}
+
+func TestAuthenticateTenant(t *testing.T) {
+	db := sql.OpenDB(tenantConnector{hash: hashTenantAPIKey("known-key")})
+	defer db.Close()
+
+	app := &application{models: models.NewModels(db)}
+
+	tests := []struct {
+		name     string
+		apiKey   string
+		expected int
+		tenant   string
+	}{
+		{name: "no header is anonymous", apiKey: "", expected: http.StatusOK},
+		{name: "known key", apiKey: "known-key", expected: http.StatusOK, tenant: "acme"},
+		{name: "unknown key", apiKey: "unknown-key", expected: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got *tenant
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = app.contextGetTenant(r)
+			})
+
+			r := httptest.NewRequest(http.MethodGet, "/v1/healthcheck", nil)
+			if tt.apiKey != "" {
+				r.Header.Set("X-API-KEY", tt.apiKey)
+			}
+			w := httptest.NewRecorder()
+
+			app.authenticateTenant(next).ServeHTTP(w, r)
+
+			if w.Code != tt.expected {
+				t.Errorf("status = %d, expected %d", w.Code, tt.expected)
+			}
+			if w.Header().Get("Vary") != "X-API-KEY" {
+				t.Errorf("Vary = %q, expected X-API-KEY", w.Header().Get("Vary"))
+			}
+			switch {
+			case tt.tenant == "" && got != nil:
+				t.Errorf("expected no tenant in the context, got %q", got.Name)
+			case tt.tenant != "" && (got == nil || got.Name != tt.tenant):
+				t.Errorf("expected tenant %q in the context, got %v", tt.tenant, got)
+			}
+		})
+	}
+}
+
+// tenantConnector is a database/sql connector whose tenants table holds a
+// single tenant, named acme, with the given API key hash.
+type tenantConnector struct {
+	hash []byte
+}
+
+func (c tenantConnector) Connect(context.Context) (driver.Conn, error) { return tenantConn(c), nil }
+func (c tenantConnector) Driver() driver.Driver                        { return nil }
+
+type tenantConn tenantConnector
+
+func (c tenantConn) Prepare(string) (driver.Stmt, error) { return tenantStmt(c), nil }
+func (c tenantConn) Close() error                        { return nil }
+func (c tenantConn) Begin() (driver.Tx, error)           { return nil, driver.ErrSkip }
+
+type tenantStmt tenantConn
+
+func (s tenantStmt) Close() error                               { return nil }
+func (s tenantStmt) NumInput() int                              { return -1 }
+func (s tenantStmt) Exec([]driver.Value) (driver.Result, error) { return nil, driver.ErrSkip }
+
+func (s tenantStmt) Query(args []driver.Value) (driver.Rows, error) {
+	hash, _ := args[0].([]byte)
+	return &tenantRows{found: bytes.Equal(hash, s.hash), hash: s.hash}, nil
+}
+
+type tenantRows struct {
+	found bool
+	hash  []byte
+}
+
+func (r *tenantRows) Columns() []string {
+	return []string{"id", "name", "api_key_hash", "liteapi_key_encrypted", "created_at"}
+}
+
+func (r *tenantRows) Close() error { return nil }
+
+func (r *tenantRows) Next(dest []driver.Value) error {
+	if !r.found {
+		return io.EOF
+	}
+	r.found = false
+
+	dest[0], dest[1], dest[2], dest[3], dest[4] = int64(1), "acme", r.hash, nil, time.Now()
+	return nil
+}
+
+func TestRequireAuthenticatedUser(t *testing.T) {
+	app := &application{}
+
+	next := func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}
+
+	tests := []struct {
+		name     string
+		user     *models.User
+		expected int
+	}{
+		{name: "anonymous", user: models.AnonymousUser, expected: http.StatusUnauthorized},
+		{name: "authenticated", user: &models.User{ID: 1}, expected: http.StatusNoContent},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := app.contextSetUser(httptest.NewRequest(http.MethodPost, "/v1/tokens/logout-all", nil), tt.user)
+			w := httptest.NewRecorder()
+
+			app.requireAuthenticatedUser(next).ServeHTTP(w, r)
+
+			if w.Code != tt.expected {
+				t.Errorf("status = %d, expected %d", w.Code, tt.expected)
+			}
+		})
+	}
+}
+
+func TestRequireOwner(t *testing.T) {
+	app := &application{}
+
+	next := func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}
+
+	alice := &models.User{ID: 1}
+
+	tests := []struct {
+		name     string
+		user     *models.User
+		param    string
+		expected int
+	}{
+		{name: "own resource", user: alice, param: "1", expected: http.StatusNoContent},
+		{name: "other user's resource", user: alice, param: "2", expected: http.StatusForbidden},
+		{name: "anonymous", user: models.AnonymousUser, param: "1", expected: http.StatusUnauthorized},
+		{name: "invalid id", user: alice, param: "abc", expected: http.StatusBadRequest},
+		{name: "zero id", user: alice, param: "0", expected: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/v1/favorites/"+tt.param, nil)
+			ctx := context.WithValue(r.Context(), httprouter.ParamsKey, httprouter.Params{{Key: "user_id", Value: tt.param}})
+			r = app.contextSetUser(r.WithContext(ctx), tt.user)
+			w := httptest.NewRecorder()
+
+			app.requireOwner("user_id", next).ServeHTTP(w, r)
+
+			if w.Code != tt.expected {
+				t.Errorf("status = %d, expected %d", w.Code, tt.expected)
+			}
+		})
+	}
+}
+
+func TestRequireSession(t *testing.T) {
+	app := &application{}
+
+	next := func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}
+
+	tests := []struct {
+		name     string
+		user     *models.User
+		key      *models.APIKey
+		expected int
+	}{
+		{name: "access token", user: &models.User{ID: 1}, expected: http.StatusNoContent},
+		{name: "api key", user: &models.User{ID: 1}, key: &models.APIKey{ID: 1, UserID: 1}, expected: http.StatusForbidden},
+		{name: "anonymous", user: models.AnonymousUser, expected: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := app.contextSetUser(httptest.NewRequest(http.MethodPost, "/v1/me/api-keys", nil), tt.user)
+			if tt.key != nil {
+				r = app.contextSetAPIKey(r, tt.key)
+			}
+			w := httptest.NewRecorder()
+
+			app.requireSession(next).ServeHTTP(w, r)
+
+			if w.Code != tt.expected {
+				t.Errorf("status = %d, expected %d", w.Code, tt.expected)
+			}
+		})
+	}
+}
+
+// TestSessionOnlyRoutes checks that routes which could take over an account
+// or escalate privileges refuse personal API keys.
+func TestSessionOnlyRoutes(t *testing.T) {
+	app := &application{}
+	router := app.router()
+
+	user := &models.User{ID: 1, Activated: true}
+	key := &models.APIKey{ID: 1, UserID: 1, Scopes: []string{models.APIKeyScopeRead, models.APIKeyScopeWrite}}
+
+	routes := []struct {
+		method string
+		path   string
+	}{
+		{method: http.MethodPatch, path: "/v1/users/1"},
+		{method: http.MethodDelete, path: "/v1/users/1"},
+		{method: http.MethodPut, path: "/v1/users/1/roles"},
+		{method: http.MethodGet, path: "/v1/me/api-keys"},
+		{method: http.MethodPost, path: "/v1/me/api-keys"},
+		{method: http.MethodDelete, path: "/v1/me/api-keys/1"},
+	}
+
+	for _, route := range routes {
+		t.Run(route.method+" "+route.path, func(t *testing.T) {
+			r := app.contextSetUser(httptest.NewRequest(route.method, route.path, nil), user)
+			r = app.contextSetAPIKey(r, key)
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, r)
+
+			if w.Code != http.StatusForbidden {
+				t.Errorf("status = %d, expected %d", w.Code, http.StatusForbidden)
+			}
+		})
+	}
+}
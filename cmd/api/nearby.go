@@ -0,0 +1,166 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const defaultNearbyRadiusKm = 5.0
+
+type NearbyHotel struct {
+	Hotel
+	MinPrice *float64 `json:"min_price,omitempty"`
+	Currency string   `json:"currency,omitempty"`
+}
+
+type NearbyHotelsResponse struct {
+	Hotels    []NearbyHotel `json:"hotels"`
+	Latitude  float64       `json:"latitude"`
+	Longitude float64       `json:"longitude"`
+	RadiusKm  float64       `json:"radius_km"`
+	CheckIn   string        `json:"check_in,omitempty"`
+	CheckOut  string        `json:"check_out,omitempty"`
+	Total     int           `json:"total"`
+	Truncated bool          `json:"truncated"`
+	Offset    int           `json:"offset"`
+	Limit     int           `json:"limit"`
+}
+
+func (app *application) nearbyHotelsHandler(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+
+	if !qs.Has("lat") || !qs.Has("lng") {
+		app.errorResponse(w, r, http.StatusBadRequest, "lat and lng parameters are required")
+		return
+	}
+
+	search, err := readHotelSearch(qs)
+	if err != nil {
+		app.errorResponse(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if search.RadiusKm == 0 {
+		search.RadiusKm = defaultNearbyRadiusKm
+	}
+	if !qs.Has("sort") {
+		search.Sort = sortDistance
+	}
+
+	offset := 0
+	limit := 20
+
+	if offsetStr := qs.Get("offset"); offsetStr != "" {
+		offset, err = strconv.Atoi(offsetStr)
+		if err != nil || offset < 0 {
+			app.errorResponse(w, r, http.StatusBadRequest, "invalid offset parameter")
+			return
+		}
+	}
+
+	if limitStr := qs.Get("limit"); limitStr != "" {
+		limit, err = strconv.Atoi(limitStr)
+		if err != nil || limit <= 0 || limit > 100 {
+			app.errorResponse(w, r, http.StatusBadRequest, "invalid limit parameter (must be between 1 and 100)")
+			return
+		}
+	}
+
+	withPrices := false
+	if withPricesStr := qs.Get("with_prices"); withPricesStr != "" {
+		withPrices, err = strconv.ParseBool(withPricesStr)
+		if err != nil {
+			app.errorResponse(w, r, http.StatusBadRequest, "invalid with_prices parameter")
+			return
+		}
+	}
+
+	currency, ok := app.readDisplayCurrency(w, r)
+	if !ok {
+		return
+	}
+
+	guestNationality, ok := readGuestNationalityParam(qs)
+	if !ok {
+		app.errorResponse(w, r, http.StatusBadRequest, "invalid guest_nationality parameter (must be an ISO 3166-1 alpha-2 country code)")
+		return
+	}
+
+	checkIn := time.Now().AddDate(0, 0, 30).Format("2006-01-02")
+	checkOut := time.Now().AddDate(0, 0, 31).Format("2006-01-02")
+
+	if qs.Has("check_in") || qs.Has("check_out") {
+		in, errIn := time.Parse("2006-01-02", qs.Get("check_in"))
+		out, errOut := time.Parse("2006-01-02", qs.Get("check_out"))
+		if errIn != nil || errOut != nil || !out.After(in) {
+			app.errorResponse(w, r, http.StatusBadRequest, "check_in and check_out must be YYYY-MM-DD dates with check_out after check_in")
+			return
+		}
+		checkIn, checkOut = qs.Get("check_in"), qs.Get("check_out")
+	}
+
+	apiKey := app.liteAPIKey(r)
+
+	candidates, truncated, err := app.searchHotelsFromAPI(r.Context(), hotelSearchUpstreamQuery("", "", search), apiKey)
+	if err != nil {
+		app.upstreamErrorResponse(w, r, err, "failed to fetch hotels from LiteAPI")
+		return
+	}
+
+	matches := search.apply(candidates)
+
+	hotels := make([]NearbyHotel, 0, limit)
+	if offset < len(matches) {
+		for _, h := range matches[offset:min(offset+limit, len(matches))] {
+			hotels = append(hotels, NearbyHotel{Hotel: h})
+		}
+	}
+
+	response := NearbyHotelsResponse{
+		Hotels:    hotels,
+		Latitude:  search.Center.Latitude,
+		Longitude: search.Center.Longitude,
+		RadiusKm:  search.RadiusKm,
+		Total:     len(matches),
+		Truncated: truncated,
+		Offset:    offset,
+		Limit:     limit,
+	}
+
+	if withPrices && len(hotels) > 0 {
+		hotelIDs := make([]string, len(hotels))
+		for i, h := range hotels {
+			hotelIDs[i] = h.HotelID
+		}
+
+		prices, err := app.getMinPricesFromAPI(r.Context(), hotelIDs, checkIn, checkOut, guestNationality, apiKey)
+		if err != nil {
+			app.upstreamErrorResponse(w, r, err, "failed to get hotel rates")
+			return
+		}
+
+		for i := range hotels {
+			if price, ok := prices[hotels[i].HotelID]; ok {
+				conversion, err := app.fx.Convert(price, liteAPICurrency, currency)
+				if err != nil {
+					app.convertPriceResponse(w, r, err)
+					return
+				}
+
+				hotels[i].MinPrice = &conversion.Amount
+				hotels[i].Currency = conversion.Currency
+			}
+		}
+
+		response.CheckIn = checkIn
+		response.CheckOut = checkOut
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"data": response}, nil)
+	if err != nil {
+		app.logError(r, err)
+		app.errorResponse(w, r, http.StatusInternalServerError, "failed to encode response")
+		return
+	}
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"encoding/json"
+	"expvar"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/madfelps/challenge-nuitee/internal/cache"
+	models "github.com/madfelps/challenge-nuitee/internal/data"
+	"github.com/madfelps/challenge-nuitee/internal/fx"
+	"github.com/madfelps/challenge-nuitee/internal/jsonlog"
+	"github.com/madfelps/challenge-nuitee/internal/upstream"
+)
+
+// newTestApplication returns an application whose LiteAPI calls are served
+// by liteAPI, without caching, with a 1 USD = 0.9 EUR exchange rate.
+func newTestApplication(t *testing.T, liteAPI http.Handler) *application {
+	t.Helper()
+
+	server := httptest.NewServer(liteAPI)
+	t.Cleanup(server.Close)
+
+	client, err := upstream.New(upstream.Config{Timeout: 5 * time.Second, BreakerThreshold: 5, BreakerCooldown: time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	app := &application{
+		logger:   jsonlog.New(io.Discard, jsonlog.LevelInfo),
+		upstream: client,
+		cache:    cache.New(cache.NewMemoryStore(10), new(expvar.Map)),
+		fx:       fx.NewConverter(),
+	}
+	app.config.liteAPIURL = server.URL
+	app.config.upstream.timeout = 5 * time.Second
+	app.fx.Set(&fx.Rates{Base: "USD", Rates: map[string]float64{"EUR": 0.9}, FetchedAt: time.Now()})
+
+	return app
+}
+
+// nearbyLiteAPI serves three hotels north of central Lisbon, about 0.1, 3.3
+// and 8.9 km away, and a min rate for the closest one only.
+func nearbyLiteAPI() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/data/hotels", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"data": [
+			{"id": "far", "name": "Far", "latitude": 38.80, "longitude": -9.14},
+			{"id": "close", "name": "Close", "latitude": 38.721, "longitude": -9.141},
+			{"id": "mid", "name": "Mid", "latitude": 38.75, "longitude": -9.14}
+		]}`)
+	})
+	mux.HandleFunc("/hotels/min-rates", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"data": [{"hotelId": "close", "price": 100}]}`)
+	})
+
+	return mux
+}
+
+func TestNearbyHotelsHandler(t *testing.T) {
+	app := newTestApplication(t, nearbyLiteAPI())
+
+	tests := []struct {
+		name           string
+		query          string
+		expectedStatus int
+		expectedIDs    []string
+		expectedPrices map[string]float64
+	}{
+		{
+			name:           "default radius sorted by distance",
+			query:          "lat=38.72&lng=-9.14",
+			expectedStatus: http.StatusOK,
+			expectedIDs:    []string{"close", "mid"},
+		},
+		{
+			name:           "wider radius",
+			query:          "lat=38.72&lng=-9.14&radius_km=10",
+			expectedStatus: http.StatusOK,
+			expectedIDs:    []string{"close", "mid", "far"},
+		},
+		{
+			name:           "narrow radius",
+			query:          "lat=38.72&lng=-9.14&radius_km=1",
+			expectedStatus: http.StatusOK,
+			expectedIDs:    []string{"close"},
+		},
+		{
+			name:           "reverse distance",
+			query:          "lat=38.72&lng=-9.14&radius_km=10&sort=-distance",
+			expectedStatus: http.StatusOK,
+			expectedIDs:    []string{"far", "mid", "close"},
+		},
+		{
+			name:           "with prices",
+			query:          "lat=38.72&lng=-9.14&with_prices=true&currency=EUR",
+			expectedStatus: http.StatusOK,
+			expectedIDs:    []string{"close", "mid"},
+			expectedPrices: map[string]float64{"close": 90},
+		},
+		{
+			name:           "missing lng",
+			query:          "lat=38.72",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "invalid with_prices",
+			query:          "lat=38.72&lng=-9.14&with_prices=maybe",
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := app.contextSetUser(httptest.NewRequest(http.MethodGet, "/v1/hotels/nearby?"+tt.query, nil), models.AnonymousUser)
+			w := httptest.NewRecorder()
+
+			app.nearbyHotelsHandler(w, r)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("status = %d, expected %d: %s", w.Code, tt.expectedStatus, w.Body)
+			}
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+
+			var body struct {
+				Data NearbyHotelsResponse `json:"data"`
+			}
+			if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+				t.Fatal(err)
+			}
+
+			ids := make([]string, len(body.Data.Hotels))
+			for i, h := range body.Data.Hotels {
+				ids[i] = h.HotelID
+
+				if h.DistanceKm == nil {
+					t.Errorf("hotel %s has no distance", h.HotelID)
+				}
+
+				expected, priced := tt.expectedPrices[h.HotelID]
+				switch {
+				case priced && (h.MinPrice == nil || *h.MinPrice != expected || h.Currency != "EUR"):
+					t.Errorf("hotel %s price = %v %s, expected %v EUR", h.HotelID, h.MinPrice, h.Currency, expected)
+				case !priced && h.MinPrice != nil:
+					t.Errorf("hotel %s has unexpected price %v", h.HotelID, *h.MinPrice)
+				}
+			}
+
+			if !slices.Equal(ids, tt.expectedIDs) {
+				t.Errorf("hotels = %v, expected %v", ids, tt.expectedIDs)
+			}
+			if body.Data.Total != len(tt.expectedIDs) {
+				t.Errorf("total = %d, expected %d", body.Data.Total, len(tt.expectedIDs))
+			}
+		})
+	}
+}
+
+func TestShowHotelHandler(t *testing.T) {
+	app := newTestApplication(t, nearbyLiteAPI())
+
+	router := httprouter.New()
+	router.HandlerFunc(http.MethodGet, "/v1/hotels/:hotel_id", app.showHotelHandler)
+
+	tests := []struct {
+		name     string
+		path     string
+		expected string
+	}{
+		{name: "nearby", path: "/v1/hotels/nearby?lat=38.72&lng=-9.14", expected: "hotels"},
+		{name: "hotel price", path: "/v1/hotels/close", expected: "price"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := app.contextSetUser(httptest.NewRequest(http.MethodGet, tt.path, nil), models.AnonymousUser)
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, r)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d, expected %d: %s", w.Code, http.StatusOK, w.Body)
+			}
+
+			var body struct {
+				Data map[string]json.RawMessage `json:"data"`
+			}
+			if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+				t.Fatal(err)
+			}
+			if _, found := body.Data[tt.expected]; !found {
+				t.Errorf("response has no %q field: %v", tt.expected, body.Data)
+			}
+		})
+	}
+}
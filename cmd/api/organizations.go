@@ -0,0 +1,361 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/julienschmidt/httprouter"
+	models "github.com/madfelps/challenge-nuitee/internal/data"
+	"github.com/madfelps/challenge-nuitee/internal/validator"
+)
+
+type CreateOrganizationRequest struct {
+	Name string `json:"name"`
+}
+
+type AddMemberRequest struct {
+	Email string `json:"email"`
+	Role  string `json:"role"`
+}
+
+type UpdateMemberRequest struct {
+	Role       *string `json:"role"`
+	Subscribed *bool   `json:"subscribed"`
+}
+
+// createOrganizationHandler creates an organization owned by the
+// authenticated user.
+func (app *application) createOrganizationHandler(w http.ResponseWriter, r *http.Request) {
+	var req CreateOrganizationRequest
+
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		app.errorResponse(w, r, http.StatusBadRequest, "invalid JSON")
+		return
+	}
+
+	org := &models.Organization{Name: strings.TrimSpace(req.Name)}
+
+	v := validator.New()
+	ValidateOrganization(v, org)
+
+	if !v.Valid() {
+		app.errorResponse(w, r, http.StatusUnprocessableEntity, v.Errors)
+		return
+	}
+
+	err = app.models.Organizations.Insert(org, app.contextGetUser(r).ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	response := map[string]interface{}{
+		"organization": org,
+		"role":         models.OrganizationRoleOwner,
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"data": response}, nil)
+	if err != nil {
+		app.logError(r, err)
+		app.errorResponse(w, r, http.StatusInternalServerError, "failed to encode response")
+		return
+	}
+}
+
+// listOrganizationsHandler lists the organizations of the authenticated
+// user.
+func (app *application) listOrganizationsHandler(w http.ResponseWriter, r *http.Request) {
+	organizations, err := app.models.Organizations.ListForUser(app.contextGetUser(r).ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"data": map[string]interface{}{"organizations": organizations}}, nil)
+	if err != nil {
+		app.logError(r, err)
+		app.errorResponse(w, r, http.StatusInternalServerError, "failed to encode response")
+		return
+	}
+}
+
+func (app *application) showOrganizationHandler(w http.ResponseWriter, r *http.Request) {
+	member := app.contextGetMember(r)
+
+	org, err := app.models.Organizations.Get(member.OrganizationID)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	response := map[string]interface{}{
+		"organization": org,
+		"membership":   member,
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"data": response}, nil)
+	if err != nil {
+		app.logError(r, err)
+		app.errorResponse(w, r, http.StatusInternalServerError, "failed to encode response")
+		return
+	}
+}
+
+// deleteOrganizationHandler deletes an organization with its favorites. Only
+// owners can delete it.
+func (app *application) deleteOrganizationHandler(w http.ResponseWriter, r *http.Request) {
+	member := app.contextGetMember(r)
+
+	if member.Role != models.OrganizationRoleOwner {
+		app.notPermittedResponse(w, r)
+		return
+	}
+
+	err := app.models.Organizations.Delete(member.OrganizationID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "organization successfully deleted"}, nil)
+	if err != nil {
+		app.logError(r, err)
+		app.errorResponse(w, r, http.StatusInternalServerError, "failed to encode response")
+		return
+	}
+}
+
+func (app *application) listMembersHandler(w http.ResponseWriter, r *http.Request) {
+	members, err := app.models.Organizations.ListMembers(app.contextGetMember(r).OrganizationID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"data": map[string]interface{}{"members": members}}, nil)
+	if err != nil {
+		app.logError(r, err)
+		app.errorResponse(w, r, http.StatusInternalServerError, "failed to encode response")
+		return
+	}
+}
+
+// addMemberHandler adds the user registered with an email address to the
+// organization, unsubscribed from its alerts until they opt in. Owners and
+// admins can add members; only owners can add owners. The response is the
+// same whether or not the address is registered, so it cannot be used to
+// find out who has an account.
+func (app *application) addMemberHandler(w http.ResponseWriter, r *http.Request) {
+	member := app.contextGetMember(r)
+
+	if !member.CanManageMembers() {
+		app.notPermittedResponse(w, r)
+		return
+	}
+
+	var req AddMemberRequest
+
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		app.errorResponse(w, r, http.StatusBadRequest, "invalid JSON")
+		return
+	}
+
+	if req.Role == "" {
+		req.Role = models.OrganizationRoleMember
+	}
+
+	v := validator.New()
+	ValidateEmail(v, req.Email)
+	ValidateOrganizationRole(v, req.Role)
+
+	if !v.Valid() {
+		app.errorResponse(w, r, http.StatusUnprocessableEntity, v.Errors)
+		return
+	}
+
+	if !canGrantOrganizationRole(member, req.Role) {
+		app.notPermittedResponse(w, r)
+		return
+	}
+
+	user, err := app.models.Users.GetByEmail(req.Email)
+	switch {
+	case errors.Is(err, models.ErrRecordNotFound):
+		// Answered like an added member below.
+	case err != nil:
+		app.serverErrorResponse(w, r, err)
+		return
+	default:
+		// Adding an existing member is not an error either, so the
+		// answer never depends on the account.
+		err = app.models.Organizations.AddMember(member.OrganizationID, user.ID, req.Role)
+		if err != nil && !errors.Is(err, models.ErrDuplicateMember) {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	message := "if the address belongs to an account, it is now a member of the organization and can subscribe to its alerts"
+
+	err = app.writeJSON(w, http.StatusAccepted, envelope{"message": message}, nil)
+	if err != nil {
+		app.logError(r, err)
+		app.errorResponse(w, r, http.StatusInternalServerError, "failed to encode response")
+		return
+	}
+}
+
+// updateMemberHandler changes the role and alert subscription of a member.
+// Members can change their own subscription; changing roles follows the
+// same rules as adding members.
+func (app *application) updateMemberHandler(w http.ResponseWriter, r *http.Request) {
+	member := app.contextGetMember(r)
+
+	target, ok := app.readMemberParam(w, r, member)
+	if !ok {
+		return
+	}
+
+	var req UpdateMemberRequest
+
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		app.errorResponse(w, r, http.StatusBadRequest, "invalid JSON")
+		return
+	}
+
+	if req.Subscribed != nil {
+		if target.UserID != member.UserID && !member.CanManageMembers() {
+			app.notPermittedResponse(w, r)
+			return
+		}
+		target.Subscribed = *req.Subscribed
+	}
+
+	if req.Role != nil && *req.Role != target.Role {
+		v := validator.New()
+		ValidateOrganizationRole(v, *req.Role)
+
+		if !v.Valid() {
+			app.errorResponse(w, r, http.StatusUnprocessableEntity, v.Errors)
+			return
+		}
+
+		if !canGrantOrganizationRole(member, *req.Role) || !canGrantOrganizationRole(member, target.Role) {
+			app.notPermittedResponse(w, r)
+			return
+		}
+		target.Role = *req.Role
+	}
+
+	err = app.models.Organizations.UpdateMember(target)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrLastOwner):
+			app.errorResponse(w, r, http.StatusConflict, "an organization must keep at least one owner")
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"data": map[string]interface{}{"member": target}}, nil)
+	if err != nil {
+		app.logError(r, err)
+		app.errorResponse(w, r, http.StatusInternalServerError, "failed to encode response")
+		return
+	}
+}
+
+// removeMemberHandler removes a member. Members can leave on their own;
+// owners and admins can remove others, but only owners can remove owners.
+func (app *application) removeMemberHandler(w http.ResponseWriter, r *http.Request) {
+	member := app.contextGetMember(r)
+
+	target, ok := app.readMemberParam(w, r, member)
+	if !ok {
+		return
+	}
+
+	if target.UserID != member.UserID && !canGrantOrganizationRole(member, target.Role) {
+		app.notPermittedResponse(w, r)
+		return
+	}
+
+	err := app.models.Organizations.RemoveMember(target.OrganizationID, target.UserID)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrLastOwner):
+			app.errorResponse(w, r, http.StatusConflict, "an organization must keep at least one owner")
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "member successfully removed"}, nil)
+	if err != nil {
+		app.logError(r, err)
+		app.errorResponse(w, r, http.StatusInternalServerError, "failed to encode response")
+		return
+	}
+}
+
+// readMemberParam loads the member named by the :user_id URL parameter in
+// the organization of member.
+func (app *application) readMemberParam(w http.ResponseWriter, r *http.Request, member *models.Member) (*models.Member, bool) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	userID, err := strconv.Atoi(params.ByName("user_id"))
+	if err != nil || userID <= 0 {
+		app.errorResponse(w, r, http.StatusBadRequest, "invalid user_id parameter")
+		return nil, false
+	}
+
+	target, err := app.models.Organizations.GetMember(member.OrganizationID, userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return nil, false
+	}
+
+	return target, true
+}
+
+// canGrantOrganizationRole reports whether member may give role to someone,
+// or take it away: owners can manage every role, admins every role but
+// owner, and members none.
+func canGrantOrganizationRole(member *models.Member, role string) bool {
+	switch member.Role {
+	case models.OrganizationRoleOwner:
+		return true
+	case models.OrganizationRoleAdmin:
+		return role != models.OrganizationRoleOwner
+	default:
+		return false
+	}
+}
+
+func ValidateOrganization(v *validator.Validator, org *models.Organization) {
+	v.Check(org.Name != "", "name", "must be provided")
+	v.Check(len(org.Name) <= 100, "name", "must not be more than 100 characters long")
+}
+
+func ValidateOrganizationRole(v *validator.Validator, role string) {
+	v.Check(slices.Contains(models.OrganizationRoles, role), "role", "must be owner, admin or member")
+}
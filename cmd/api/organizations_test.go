@@ -0,0 +1,75 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	models "github.com/madfelps/challenge-nuitee/internal/data"
+	"github.com/madfelps/challenge-nuitee/internal/validator"
+)
+
+func TestCanGrantOrganizationRole(t *testing.T) {
+	tests := []struct {
+		memberRole string
+		role       string
+		expected   bool
+	}{
+		{memberRole: models.OrganizationRoleOwner, role: models.OrganizationRoleOwner, expected: true},
+		{memberRole: models.OrganizationRoleOwner, role: models.OrganizationRoleMember, expected: true},
+		{memberRole: models.OrganizationRoleAdmin, role: models.OrganizationRoleOwner, expected: false},
+		{memberRole: models.OrganizationRoleAdmin, role: models.OrganizationRoleAdmin, expected: true},
+		{memberRole: models.OrganizationRoleAdmin, role: models.OrganizationRoleMember, expected: true},
+		{memberRole: models.OrganizationRoleMember, role: models.OrganizationRoleMember, expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.memberRole+" grants "+tt.role, func(t *testing.T) {
+			member := &models.Member{Role: tt.memberRole}
+
+			if got := canGrantOrganizationRole(member, tt.role); got != tt.expected {
+				t.Errorf("canGrantOrganizationRole() = %v, expected %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestValidateOrganizationRole(t *testing.T) {
+	tests := []struct {
+		role     string
+		expected bool
+	}{
+		{role: "owner", expected: true},
+		{role: "admin", expected: true},
+		{role: "member", expected: true},
+		{role: "", expected: false},
+		{role: "superuser", expected: false},
+	}
+
+	for _, tt := range tests {
+		v := validator.New()
+		ValidateOrganizationRole(v, tt.role)
+
+		if v.Valid() != tt.expected {
+			t.Errorf("ValidateOrganizationRole(%q) = %v, expected %v", tt.role, v.Valid(), tt.expected)
+		}
+	}
+}
+
+func TestFavoriteOwner(t *testing.T) {
+	app := &application{}
+
+	r := app.contextSetUser(httptest.NewRequest(http.MethodGet, "/v1/me/favorites", nil), &models.User{ID: 7})
+
+	owner := app.favoriteOwner(r)
+	if owner.UserID != 7 || owner.OrganizationID != nil {
+		t.Errorf("personal route: got %+v", owner)
+	}
+
+	r = app.contextSetMember(r, &models.Member{OrganizationID: 3, UserID: 7})
+
+	owner = app.favoriteOwner(r)
+	if owner.UserID != 7 || owner.OrganizationID == nil || *owner.OrganizationID != 3 {
+		t.Errorf("organization route: got %+v", owner)
+	}
+}
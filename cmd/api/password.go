@@ -0,0 +1,151 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// argon2Params are the argon2id parameters new hashes are created with. They
+// follow the OWASP recommendation of 19 MiB of memory and two iterations.
+// Hashes created with other parameters still verify and are upgraded on the
+// next successful login.
+type argon2Params struct {
+	memory      uint32
+	iterations  uint32
+	parallelism uint8
+	saltLength  uint32
+	keyLength   uint32
+}
+
+var currentArgon2Params = argon2Params{
+	memory:      19 * 1024,
+	iterations:  2,
+	parallelism: 1,
+	saltLength:  16,
+	keyLength:   32,
+}
+
+var errInvalidPasswordHash = errors.New("invalid password hash")
+
+// Bounds on the argon2id parameters accepted from a stored hash, so a
+// corrupted or tampered row cannot make a login panic or exhaust memory and
+// CPU. They leave ample room above currentArgon2Params.
+const (
+	maxArgon2Memory      = 256 * 1024 // KiB
+	maxArgon2Iterations  = 10
+	maxArgon2Parallelism = 16
+)
+
+// dummyPasswordHash is verified against when no user matches a login, so
+// unknown and known emails take the same time to reject.
+var dummyPasswordHash, _ = hashPassword("dummy password used to equalize timing")
+
+// hashPassword returns an argon2id hash of password in the PHC string format:
+// $argon2id$v=19$m=19456,t=2,p=1$<salt>$<key>.
+func hashPassword(password string) (string, error) {
+	p := currentArgon2Params
+
+	salt := make([]byte, p.saltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, p.iterations, p.memory, p.parallelism, p.keyLength)
+
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, p.memory, p.iterations, p.parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+// verifyPassword reports whether password matches storedHash, and whether
+// storedHash should be replaced by a fresh hashPassword result because it
+// uses the legacy salt:sha256 format or outdated argon2id parameters.
+func verifyPassword(password, storedHash string) (match, needsRehash bool) {
+	if strings.HasPrefix(storedHash, "$argon2id$") {
+		p, salt, key, err := decodeArgon2Hash(storedHash)
+		if err != nil {
+			return false, false
+		}
+
+		computed := argon2.IDKey([]byte(password), salt, p.iterations, p.memory, p.parallelism, p.keyLength)
+		if subtle.ConstantTimeCompare(computed, key) != 1 {
+			return false, false
+		}
+
+		return true, p != currentArgon2Params
+	}
+
+	return verifyLegacyPassword(password, storedHash), true
+}
+
+func decodeArgon2Hash(encoded string) (argon2Params, []byte, []byte, error) {
+	var p argon2Params
+
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 {
+		return p, nil, nil, errInvalidPasswordHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return p, nil, nil, errInvalidPasswordHash
+	}
+
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.memory, &p.iterations, &p.parallelism); err != nil {
+		return p, nil, nil, errInvalidPasswordHash
+	}
+
+	if p.memory < 1 || p.memory > maxArgon2Memory ||
+		p.iterations < 1 || p.iterations > maxArgon2Iterations ||
+		p.parallelism < 1 || p.parallelism > maxArgon2Parallelism {
+		return p, nil, nil, errInvalidPasswordHash
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return p, nil, nil, errInvalidPasswordHash
+	}
+
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return p, nil, nil, errInvalidPasswordHash
+	}
+
+	p.saltLength = uint32(len(salt))
+	p.keyLength = uint32(len(key))
+
+	return p, salt, key, nil
+}
+
+// verifyLegacyPassword checks hashes created before argon2id was introduced,
+// stored as hex(salt):hex(sha256(password || salt)).
+func verifyLegacyPassword(password, storedHash string) bool {
+	parts := strings.Split(storedHash, ":")
+	if len(parts) != 2 {
+		return false
+	}
+
+	salt, err := hex.DecodeString(parts[0])
+	if err != nil {
+		return false
+	}
+
+	expected, err := hex.DecodeString(parts[1])
+	if err != nil {
+		return false
+	}
+
+	hash := sha256.Sum256(append([]byte(password), salt...))
+
+	return subtle.ConstantTimeCompare(hash[:], expected) == 1
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	models "github.com/madfelps/challenge-nuitee/internal/data"
+	"github.com/madfelps/challenge-nuitee/internal/validator"
+)
+
+const passwordResetTokenTTL = 45 * time.Minute
+
+type CreatePasswordResetTokenRequest struct {
+	Email string `json:"email"`
+}
+
+type ResetPasswordRequest struct {
+	Password string `json:"password"`
+	Token    string `json:"token"`
+}
+
+// createPasswordResetTokenHandler emails a password reset token. It answers
+// the same way whether or not the address is registered, so it cannot be
+// used to discover accounts.
+func (app *application) createPasswordResetTokenHandler(w http.ResponseWriter, r *http.Request) {
+	var req CreatePasswordResetTokenRequest
+
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		app.errorResponse(w, r, http.StatusBadRequest, "invalid JSON")
+		return
+	}
+
+	v := validator.New()
+	ValidateEmail(v, req.Email)
+
+	if !v.Valid() {
+		app.errorResponse(w, r, http.StatusUnprocessableEntity, v.Errors)
+		return
+	}
+
+	user, err := app.models.Users.GetByEmail(req.Email)
+	switch {
+	case errors.Is(err, models.ErrRecordNotFound):
+	case err != nil:
+		app.serverErrorResponse(w, r, err)
+		return
+	default:
+		token, err := app.models.Tokens.New(user.ID, passwordResetTokenTTL, models.ScopePasswordReset)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		app.background(func() {
+			data := map[string]interface{}{
+				"name":               user.Name,
+				"passwordResetToken": token.Plaintext,
+				"expiresIn":          "45 minutes",
+			}
+
+			err := app.mailer.Send(user.Email, "token_password_reset.tmpl", data)
+			if err != nil {
+				app.logger.PrintError(err, map[string]string{"template": "token_password_reset.tmpl"})
+			}
+		})
+	}
+
+	message := "if the address belongs to an account, an email will be sent to it containing password reset instructions"
+
+	err = app.writeJSON(w, http.StatusAccepted, envelope{"message": message}, nil)
+	if err != nil {
+		app.logError(r, err)
+		app.errorResponse(w, r, http.StatusInternalServerError, "failed to encode response")
+		return
+	}
+}
+
+// resetPasswordHandler sets a new password from a password reset token and
+// ends every session of the user.
+func (app *application) resetPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	var req ResetPasswordRequest
+
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		app.errorResponse(w, r, http.StatusBadRequest, "invalid JSON")
+		return
+	}
+
+	v := validator.New()
+	ValidatePasswordPlaintext(v, req.Password)
+	ValidateTokenPlaintext(v, "token", req.Token)
+
+	if !v.Valid() {
+		app.errorResponse(w, r, http.StatusUnprocessableEntity, v.Errors)
+		return
+	}
+
+	user, err := app.models.Users.GetForToken(models.ScopePasswordReset, req.Token)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrRecordNotFound):
+			app.errorResponse(w, r, http.StatusUnprocessableEntity, map[string]string{"token": "invalid or expired password reset token"})
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	// Consume the tokens first so a token can never be used twice, even if
+	// a later step fails.
+	err = app.models.Tokens.DeleteAllForUser(models.ScopePasswordReset, user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	passwordHash, err := hashPassword(req.Password)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.models.Users.UpdatePasswordHash(user.ID, passwordHash)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.models.Tokens.RevokeAllForUser(user.ID, models.ScopeRefresh)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	// A key leaked together with the old password must stop working too.
+	err = app.models.APIKeys.DeleteAllForUser(user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	// A password reset proves control of the address, so it also unlocks an
+	// account locked by failed logins.
+	app.recordLoginAttempt(r, user.Email, user, models.LoginOutcomePasswordReset)
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "your password was successfully reset"}, nil)
+	if err != nil {
+		app.logError(r, err)
+		app.errorResponse(w, r, http.StatusInternalServerError, "failed to encode response")
+		return
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/argon2"
+)
+
+func TestHashPassword(t *testing.T) {
+	first, err := hashPassword("password123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, _ := hashPassword("password123")
+
+	if !strings.HasPrefix(first, "$argon2id$v=19$m=19456,t=2,p=1$") {
+		t.Errorf("unexpected hash format: %s", first)
+	}
+	if first == second {
+		t.Error("hashing twice produced the same hash")
+	}
+}
+
+func TestVerifyPassword(t *testing.T) {
+	current, _ := hashPassword("password123")
+
+	salt := []byte("0123456789abcdef")
+	legacyHash := sha256.Sum256(append([]byte("password123"), salt...))
+	legacy := hex.EncodeToString(salt) + ":" + hex.EncodeToString(legacyHash[:])
+
+	weakKey := argon2.IDKey([]byte("password123"), salt, 1, 8*1024, 1, 32)
+	outdated := "$argon2id$v=19$m=8192,t=1,p=1$" +
+		base64.RawStdEncoding.EncodeToString(salt) + "$" +
+		base64.RawStdEncoding.EncodeToString(weakKey)
+
+	withParams := func(params string) string {
+		return "$argon2id$v=19$" + params + "$" +
+			base64.RawStdEncoding.EncodeToString(salt) + "$" +
+			base64.RawStdEncoding.EncodeToString(weakKey)
+	}
+
+	tests := []struct {
+		name        string
+		password    string
+		hash        string
+		match       bool
+		needsRehash bool
+	}{
+		{name: "current hash", password: "password123", hash: current, match: true},
+		{name: "current hash, wrong password", password: "password124", hash: current},
+		{name: "legacy hash", password: "password123", hash: legacy, match: true, needsRehash: true},
+		{name: "legacy hash, wrong password", password: "wrong", hash: legacy, needsRehash: true},
+		{name: "outdated parameters", password: "password123", hash: outdated, match: true, needsRehash: true},
+		{name: "malformed argon2 hash", password: "password123", hash: "$argon2id$v=19$garbage"},
+		{name: "zero parallelism", password: "password123", hash: withParams("m=8192,t=1,p=0")},
+		{name: "zero iterations", password: "password123", hash: withParams("m=8192,t=0,p=1")},
+		{name: "huge memory", password: "password123", hash: withParams("m=4194304,t=1,p=1")},
+		{name: "huge iterations", password: "password123", hash: withParams("m=8192,t=1000000,p=1")},
+		{name: "malformed legacy hash", password: "password123", hash: "not-a-hash", needsRehash: true},
+		{name: "empty hash", password: "", hash: "", needsRehash: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			match, needsRehash := verifyPassword(tt.password, tt.hash)
+
+			if match != tt.match {
+				t.Errorf("match = %v, expected %v", match, tt.match)
+			}
+			if match && needsRehash != tt.needsRehash {
+				t.Errorf("needsRehash = %v, expected %v", needsRehash, tt.needsRehash)
+			}
+		})
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	models "github.com/madfelps/challenge-nuitee/internal/data"
+	"github.com/madfelps/challenge-nuitee/internal/fx"
+	"github.com/madfelps/challenge-nuitee/internal/validator"
+)
+
+var (
+	nationalityRX = regexp.MustCompile("^[A-Z]{2}$")
+	localeRX      = regexp.MustCompile("^[a-z]{2,3}(-[A-Z]{2})?$")
+)
+
+type UpdatePreferencesRequest struct {
+	Currency      *string  `json:"currency"`
+	Nationality   *string  `json:"nationality"`
+	Timezone      *string  `json:"timezone"`
+	Locale        *string  `json:"locale"`
+	AlertChannels []string `json:"alert_channels"`
+}
+
+// readGuestNationalityParam returns the guest nationality requested in the
+// query string, defaulting to the default user nationality.
+func readGuestNationalityParam(qs url.Values) (string, bool) {
+	nationality := strings.ToUpper(qs.Get("guest_nationality"))
+	if nationality == "" {
+		return models.DefaultNationality, true
+	}
+
+	return nationality, nationalityRX.MatchString(nationality)
+}
+
+// showPreferencesHandler returns the preferences of the authenticated user.
+// On /v1/users/:id/preferences requireOwner has already checked that :id is
+// that user.
+func (app *application) showPreferencesHandler(w http.ResponseWriter, r *http.Request) {
+	userID := app.contextGetUser(r).ID
+
+	prefs, err := app.models.Preferences.Get(userID)
+	if err != nil {
+		app.logError(r, err)
+		app.errorResponse(w, r, http.StatusInternalServerError, "database error")
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"data": map[string]interface{}{"preferences": prefs}}, nil)
+	if err != nil {
+		app.logError(r, err)
+		app.errorResponse(w, r, http.StatusInternalServerError, "failed to encode response")
+		return
+	}
+}
+
+// updatePreferencesHandler replaces the preferences of the authenticated
+// user. Fields left out of the request keep their current value.
+func (app *application) updatePreferencesHandler(w http.ResponseWriter, r *http.Request) {
+	userID := app.contextGetUser(r).ID
+
+	var req UpdatePreferencesRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		app.errorResponse(w, r, http.StatusBadRequest, "invalid JSON")
+		return
+	}
+
+	prefs, err := app.models.Preferences.Get(userID)
+	if err != nil {
+		app.logError(r, err)
+		app.errorResponse(w, r, http.StatusInternalServerError, "database error")
+		return
+	}
+
+	if req.Currency != nil {
+		prefs.Currency = strings.ToUpper(strings.TrimSpace(*req.Currency))
+	}
+	if req.Nationality != nil {
+		prefs.Nationality = strings.ToUpper(strings.TrimSpace(*req.Nationality))
+	}
+	if req.Timezone != nil {
+		prefs.Timezone = strings.TrimSpace(*req.Timezone)
+	}
+	if req.Locale != nil {
+		prefs.Locale = strings.TrimSpace(*req.Locale)
+	}
+	if req.AlertChannels != nil {
+		prefs.AlertChannels = make([]string, len(req.AlertChannels))
+		for i, channel := range req.AlertChannels {
+			prefs.AlertChannels[i] = strings.ToLower(strings.TrimSpace(channel))
+		}
+	}
+
+	v := validator.New()
+	ValidatePreferences(v, prefs, app.fx.Current())
+
+	if !v.Valid() {
+		app.errorResponse(w, r, http.StatusUnprocessableEntity, v.Errors)
+		return
+	}
+
+	err = app.models.Preferences.Upsert(prefs)
+	if err != nil {
+		app.logError(r, err)
+		app.errorResponse(w, r, http.StatusInternalServerError, "failed to update preferences")
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"data": map[string]interface{}{"preferences": prefs}}, nil)
+	if err != nil {
+		app.logError(r, err)
+		app.errorResponse(w, r, http.StatusInternalServerError, "failed to encode response")
+		return
+	}
+}
+
+// ValidatePreferences checks user preferences. When rates is not nil the
+// currency must also be one prices can be converted into.
+func ValidatePreferences(v *validator.Validator, prefs *models.Preferences, rates *fx.Rates) {
+	if !validator.Matches(prefs.Currency, fx.CurrencyRX) {
+		v.AddError("currency", "must be an ISO 4217 code such as USD or EUR")
+	} else if rates != nil {
+		_, err := rates.Rate(liteAPICurrency, prefs.Currency)
+		v.Check(err == nil, "currency", "is not supported")
+	}
+
+	v.Check(validator.Matches(prefs.Nationality, nationalityRX), "nationality", "must be an ISO 3166-1 alpha-2 country code such as US or FR")
+
+	_, err := time.LoadLocation(prefs.Timezone)
+	v.Check(prefs.Timezone != "" && err == nil, "timezone", "must be an IANA time zone such as Europe/Paris")
+
+	v.Check(validator.Matches(prefs.Locale, localeRX), "locale", "must be a language tag such as en or pt-BR")
+
+	for i, channel := range prefs.AlertChannels {
+		if !slices.Contains(models.AlertChannels, channel) {
+			v.AddError("alert_channels", "must only contain "+strings.Join(models.AlertChannels, ", "))
+			break
+		}
+		if slices.Contains(prefs.AlertChannels[:i], channel) {
+			v.AddError("alert_channels", "must not contain duplicate values")
+			break
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"testing"
+
+	models "github.com/madfelps/challenge-nuitee/internal/data"
+	"github.com/madfelps/challenge-nuitee/internal/fx"
+	"github.com/madfelps/challenge-nuitee/internal/validator"
+)
+
+func TestValidatePreferences(t *testing.T) {
+	rates := &fx.Rates{Base: "USD", Rates: map[string]float64{"EUR": 0.9}}
+
+	tests := []struct {
+		name     string
+		modify   func(p *models.Preferences)
+		rates    *fx.Rates
+		expected string
+	}{
+		{name: "defaults", modify: func(p *models.Preferences) {}},
+		{name: "supported currency", modify: func(p *models.Preferences) { p.Currency = "EUR" }, rates: rates},
+		{name: "any currency before rates are loaded", modify: func(p *models.Preferences) { p.Currency = "JPY" }},
+		{name: "unsupported currency", modify: func(p *models.Preferences) { p.Currency = "JPY" }, rates: rates, expected: "currency"},
+		{name: "invalid currency", modify: func(p *models.Preferences) { p.Currency = "dollars" }, expected: "currency"},
+		{name: "invalid nationality", modify: func(p *models.Preferences) { p.Nationality = "USA" }, expected: "nationality"},
+		{name: "valid timezone", modify: func(p *models.Preferences) { p.Timezone = "America/Sao_Paulo" }},
+		{name: "invalid timezone", modify: func(p *models.Preferences) { p.Timezone = "Mars/Olympus" }, expected: "timezone"},
+		{name: "empty timezone", modify: func(p *models.Preferences) { p.Timezone = "" }, expected: "timezone"},
+		{name: "regional locale", modify: func(p *models.Preferences) { p.Locale = "pt-BR" }},
+		{name: "invalid locale", modify: func(p *models.Preferences) { p.Locale = "Portuguese" }, expected: "locale"},
+		{name: "no alert channels", modify: func(p *models.Preferences) { p.AlertChannels = []string{} }},
+		{name: "unknown alert channel", modify: func(p *models.Preferences) { p.AlertChannels = []string{"sms"} }, expected: "alert_channels"},
+		{name: "duplicate alert channel", modify: func(p *models.Preferences) { p.AlertChannels = []string{"log", "log"} }, expected: "alert_channels"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prefs := models.DefaultPreferences(1)
+			tt.modify(prefs)
+
+			v := validator.New()
+			ValidatePreferences(v, prefs, tt.rates)
+
+			if tt.expected == "" {
+				if !v.Valid() {
+					t.Errorf("unexpected errors: %v", v.Errors)
+				}
+				return
+			}
+
+			if _, found := v.Errors[tt.expected]; !found {
+				t.Errorf("expected error on %q, got %v", tt.expected, v.Errors)
+			}
+		})
+	}
+}
@@ -4,7 +4,12 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"net/http"
+	"net/url"
 	"time"
+
+	models "github.com/madfelps/challenge-nuitee/internal/data"
+	"github.com/madfelps/challenge-nuitee/internal/upstream"
 )
 
 func (app *application) StartPriceMonitor() {
@@ -19,54 +24,375 @@ func (app *application) StartPriceMonitor() {
 }
 
 func (app *application) checkPrices() {
+	if app.upstream.Breaker.State() == upstream.StateOpen {
+		log.Printf("skipping price check: LiteAPI circuit breaker is open")
+		return
+	}
+
+	if app.upstream.Budget.BackgroundExhausted() {
+		log.Printf("skipping price check: LiteAPI daily quota reserved for interactive requests")
+		return
+	}
+
+	// Monitor calls yield to user traffic in the LiteAPI budget.
+	ctx := upstream.WithPriority(context.Background(), upstream.PriorityBackground)
+
+	expired, err := app.models.Favorites.ExpireDue(time.Now().UTC())
+	if err != nil {
+		log.Printf("error expiring favorites: %v", err)
+		return
+	}
+	if expired > 0 {
+		log.Printf("expired %d favorites", expired)
+	}
+
 	favorites, err := app.models.Favorites.ListAllFavorites()
 	if err != nil {
 		log.Printf("error getting favorites: %v", err)
 		return
 	}
 
+	cache := newMonitorCache(app)
+
 	for _, favorite := range favorites {
-		log.Printf("checking price for hotel %s (User: %d, Target: $%.2f)",
-			favorite.HotelID, favorite.UserID, favorite.TargetPrice)
+		if app.upstream.Breaker.State() == upstream.StateOpen {
+			log.Printf("stopping price check: LiteAPI circuit breaker opened")
+			return
+		}
+
+		if app.upstream.Budget.BackgroundExhausted() {
+			log.Printf("stopping price check: LiteAPI daily quota reserved for interactive requests")
+			return
+		}
+
+		log.Printf("checking price for hotel %s (User: %d, Target: %.2f %s)",
+			favorite.HotelID, favorite.UserID, favorite.TargetPrice, favorite.Currency)
 
-		currentPrice, hotelName, err := app.getCurrentHotelPrice(favorite.HotelID)
+		// Organization favorites are priced with the preferences of the
+		// member who created them.
+		prefs, err := cache.preferences(favorite.UserID)
+		if err != nil {
+			log.Printf("error getting preferences for user %d: %v", favorite.UserID, err)
+			continue
+		}
+
+		// A price nobody can be alerted of is not worth the LiteAPI call.
+		// The favorite is checked again once a recipient can be reached.
+		deliverable, err := cache.deliverable(favorite)
+		if err != nil {
+			log.Printf("error getting alert recipients for favorite %d: %v", favorite.ID, err)
+			continue
+		}
+		if !deliverable {
+			log.Printf("skipping favorite %d: no recipient has a deliverable alert channel", favorite.ID)
+			continue
+		}
+
+		if !favorite.Criteria.IsZero() {
+			app.checkOfferPrice(ctx, cache, favorite, prefs)
+			continue
+		}
+
+		currentPrice, hotelName, err := app.getCurrentHotelPrice(ctx, favorite, prefs)
 		if err != nil {
 			log.Printf("error getting price for hotel %s: %v", favorite.HotelID, err)
 			continue
 		}
 
-		log.Printf("found price for %s: $%.2f", hotelName, currentPrice)
+		price, err := app.observePrice(favorite, currentPrice, liteAPICurrency)
+		if err != nil {
+			log.Printf("error converting price for hotel %s to %s: %v", favorite.HotelID, favorite.Currency, err)
+			continue
+		}
+
+		log.Printf("found price for %s: %.2f %s", hotelName, price, favorite.Currency)
+
+		if price <= favorite.TargetPrice {
+			app.sendAlert(cache, favorite, hotelName, fmt.Sprintf("Hotel %s - Current price %.2f %s is lower than target %.2f %s",
+				hotelName, price, favorite.Currency, favorite.TargetPrice, favorite.Currency))
+		}
+	}
+}
+
+// checkOfferPrice evaluates a favorite with watch criteria against the full
+// rates of its hotel, so only offers the user would actually book can alert.
+func (app *application) checkOfferPrice(ctx context.Context, cache *monitorCache, favorite models.Favorite, prefs *models.Preferences) {
+	offer, hotelName, err := app.getBestMatchingOffer(ctx, favorite, prefs)
+	if err != nil {
+		log.Printf("error getting offers for hotel %s: %v", favorite.HotelID, err)
+		return
+	}
+
+	currency := offer.Currency
+	if currency == "" {
+		currency = liteAPICurrency
+	}
+
+	price, err := app.observePrice(favorite, offer.Price, currency)
+	if err != nil {
+		log.Printf("error converting offer price for hotel %s to %s: %v", favorite.HotelID, favorite.Currency, err)
+		return
+	}
+
+	log.Printf("found matching offer for %s: %s (%s, refundable: %t) %.2f %s",
+		hotelName, offer.RoomName, offer.BoardType, offer.Refundable, price, favorite.Currency)
+
+	if price <= favorite.TargetPrice {
+		app.sendAlert(cache, favorite, hotelName, fmt.Sprintf("Hotel %s - Offer %s (%s) at %.2f %s is lower than target %.2f %s",
+			hotelName, offer.RoomName, offer.BoardType, price, favorite.Currency, favorite.TargetPrice, favorite.Currency))
+	}
+}
+
+// monitorCache holds what one monitor run loads for several favorites.
+type monitorCache struct {
+	app         *application
+	users       map[int]*models.User
+	prefs       map[int]*models.Preferences
+	subscribers map[int][]int
+}
+
+func newMonitorCache(app *application) *monitorCache {
+	return &monitorCache{
+		app:         app,
+		users:       make(map[int]*models.User),
+		prefs:       make(map[int]*models.Preferences),
+		subscribers: make(map[int][]int),
+	}
+}
+
+func (c *monitorCache) user(id int) (*models.User, error) {
+	if user, found := c.users[id]; found {
+		return user, nil
+	}
+
+	user, err := c.app.models.Users.Get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	c.users[id] = user
+	return user, nil
+}
+
+func (c *monitorCache) preferences(userID int) (*models.Preferences, error) {
+	if prefs, found := c.prefs[userID]; found {
+		return prefs, nil
+	}
+
+	prefs, err := c.app.models.Preferences.Get(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	c.prefs[userID] = prefs
+	return prefs, nil
+}
+
+// recipients returns the IDs of the users alerted for favorite: its owner,
+// or the subscribed members of its organization.
+func (c *monitorCache) recipients(favorite models.Favorite) ([]int, error) {
+	if favorite.OrganizationID == nil {
+		return []int{favorite.UserID}, nil
+	}
+
+	if userIDs, found := c.subscribers[*favorite.OrganizationID]; found {
+		return userIDs, nil
+	}
+
+	userIDs, err := c.app.models.Organizations.ListSubscribers(*favorite.OrganizationID)
+	if err != nil {
+		return nil, err
+	}
+
+	c.subscribers[*favorite.OrganizationID] = userIDs
+	return userIDs, nil
+}
+
+// deliverable reports whether at least one recipient of favorite has an
+// alert channel deliverAlert would use.
+func (c *monitorCache) deliverable(favorite models.Favorite) (bool, error) {
+	userIDs, err := c.recipients(favorite)
+	if err != nil {
+		return false, err
+	}
+
+	for _, userID := range userIDs {
+		user, err := c.user(userID)
+		if err != nil {
+			return false, err
+		}
+
+		prefs, err := c.preferences(userID)
+		if err != nil {
+			return false, err
+		}
 
-		if currentPrice <= favorite.TargetPrice {
-			fmt.Printf("ALERT: User %d - Hotel %s - Current price $%.2f is lower than target $%.2f\n",
-				favorite.UserID, hotelName, currentPrice, favorite.TargetPrice)
+		if len(deliverableChannels(user, prefs)) > 0 {
+			return true, nil
 		}
 	}
+
+	return false, nil
 }
 
-func (app *application) getCurrentHotelPrice(hotelID string) (float64, string, error) {
-	ctx := context.Background()
+// deliverableChannels returns the alert channels of prefs user can be
+// reached through: email is left out until the address is verified.
+func deliverableChannels(user *models.User, prefs *models.Preferences) []string {
+	var channels []string
 
-	hotelDetails, res, err := app.apiClient.StaticDataApi.GetHotelDetails(ctx).HotelId(hotelID).Execute()
+	for _, channel := range prefs.AlertChannels {
+		if channel == models.AlertChannelEmail && (!user.Activated || user.Email == "") {
+			continue
+		}
+		channels = append(channels, channel)
+	}
+
+	return channels
+}
+
+// sendAlert alerts every recipient of favorite through each channel they
+// enabled, then marks the favorite fulfilled so it alerts only once until it
+// is resumed.
+func (app *application) sendAlert(cache *monitorCache, favorite models.Favorite, hotelName, message string) {
+	userIDs, err := cache.recipients(favorite)
 	if err != nil {
-		return 0, "", fmt.Errorf("failed to get hotel details: %v", err)
+		log.Printf("error getting alert recipients for favorite %d: %v", favorite.ID, err)
+		return
 	}
 
-	if res.StatusCode != 200 {
-		return 0, "", fmt.Errorf("API returned status %d", res.StatusCode)
+	alerted := false
+
+	for _, userID := range userIDs {
+		user, err := cache.user(userID)
+		if err != nil {
+			log.Printf("error getting user %d: %v", userID, err)
+			continue
+		}
+
+		prefs, err := cache.preferences(userID)
+		if err != nil {
+			log.Printf("error getting preferences for user %d: %v", userID, err)
+			continue
+		}
+
+		if app.deliverAlert(favorite, user, prefs, hotelName, message) {
+			alerted = true
+		}
+	}
+
+	// A favorite nobody was alerted for keeps being monitored, so the alert
+	// is retried on the next run instead of being lost.
+	if !alerted {
+		log.Printf("no alert recorded for favorite %d, keeping it active", favorite.ID)
+		return
+	}
+
+	if err := app.models.Favorites.MarkFulfilled(favorite.ID); err != nil {
+		log.Printf("error marking favorite %d fulfilled: %v", favorite.ID, err)
 	}
+}
+
+// deliverAlert records an alert for every channel the user enabled. Log
+// alerts are printed right away; emails are sent in the background and
+// marked sent once delivered. Users who have not verified their address get
+// no email. It reports whether at least one alert was recorded.
+func (app *application) deliverAlert(favorite models.Favorite, user *models.User, prefs *models.Preferences, hotelName, message string) bool {
+	recorded := false
+
+	for _, channel := range deliverableChannels(user, prefs) {
+		notification := &models.Notification{
+			UserID:     user.ID,
+			FavoriteID: &favorite.ID,
+			Channel:    channel,
+			Message:    message,
+		}
+
+		if channel == models.AlertChannelLog {
+			fmt.Printf("ALERT: User %d - %s\n", user.ID, message)
+
+			now := time.Now()
+			notification.SentAt = &now
+		}
+
+		if err := app.models.Notifications.Insert(notification); err != nil {
+			log.Printf("error recording %s notification for user %d: %v", channel, user.ID, err)
+			continue
+		}
 
-	hotelName := "not identified hotel"
-	if data, ok := hotelDetails["data"].(map[string]interface{}); ok {
-		if name, ok := data["name"].(string); ok {
-			hotelName = name
+		recorded = true
+
+		if channel == models.AlertChannelEmail {
+			app.background(func() {
+				data := map[string]interface{}{
+					"name":      user.Name,
+					"hotelName": hotelName,
+					"message":   message,
+				}
+
+				if err := app.mailer.Send(user.Email, "price_alert.tmpl", data); err != nil {
+					log.Printf("error sending email alert to user %d: %v", user.ID, err)
+					return
+				}
+
+				if err := app.models.Notifications.MarkSent(notification.ID); err != nil {
+					log.Printf("error marking notification %d sent: %v", notification.ID, err)
+				}
+			})
 		}
 	}
 
-	checkIn := time.Now().AddDate(0, 0, 30).Format("2006-01-02")
-	checkOut := time.Now().AddDate(0, 0, 31).Format("2006-01-02")
+	return recorded
+}
+
+// watchDates returns the default stay the monitor prices: one night, 30
+// days from today in the user's time zone.
+func watchDates(prefs *models.Preferences, now time.Time) (checkIn, checkOut string) {
+	today := now.In(prefs.Location())
+	return today.AddDate(0, 0, 30).Format("2006-01-02"), today.AddDate(0, 0, 31).Format("2006-01-02")
+}
 
-	minPrice, err := app.getMinPriceFromAPI(hotelID, checkIn, checkOut, app.config.apiKey)
+// favoriteStay returns the dates and occupancy favorite is priced for: its
+// own stay, or the default dates when it has none.
+func favoriteStay(favorite models.Favorite, prefs *models.Preferences, now time.Time) (checkIn, checkOut string, occupancy Occupancy) {
+	if favorite.Stay.CheckIn != nil && favorite.Stay.CheckOut != nil {
+		checkIn = favorite.Stay.CheckIn.Format("2006-01-02")
+		checkOut = favorite.Stay.CheckOut.Format("2006-01-02")
+	} else {
+		checkIn, checkOut = watchDates(prefs, now)
+	}
+
+	occupancy = Occupancy{Adults: max(1, favorite.Stay.Adults), Children: make([]int, len(favorite.Stay.Children))}
+	for i, age := range favorite.Stay.Children {
+		occupancy.Children[i] = int(age)
+	}
+
+	return checkIn, checkOut, occupancy
+}
+
+func (app *application) getHotelName(ctx context.Context, hotelID string) (string, error) {
+	query := url.Values{}
+	query.Set("hotelId", hotelID)
+
+	var hotelDetails liteAPIHotelDetailsResponse
+	err := app.callLiteAPI(ctx, http.MethodGet, liteAPIEndpointHotelDetails, query, nil, app.config.apiKey, &hotelDetails)
+	if err != nil {
+		return "", fmt.Errorf("failed to get hotel details: %v", err)
+	}
+
+	return hotelDetails.Data.Name, nil
+}
+
+func (app *application) getCurrentHotelPrice(ctx context.Context, favorite models.Favorite, prefs *models.Preferences) (float64, string, error) {
+	hotelID := favorite.HotelID
+
+	hotelName, err := app.getHotelName(ctx, hotelID)
+	if err != nil {
+		return 0, "", err
+	}
+
+	checkIn, checkOut, occupancy := favoriteStay(favorite, prefs, time.Now())
+
+	minPrice, err := app.getMinPriceFromAPI(ctx, hotelID, checkIn, checkOut, occupancy, prefs.Nationality, app.config.apiKey)
 	if err != nil {
 		log.Printf("error getting min rates for hotel %s: %v", hotelID, err)
 		return 0, hotelName, fmt.Errorf("failed to get rates: %v", err)
@@ -78,3 +404,48 @@ func (app *application) getCurrentHotelPrice(hotelID string) (float64, string, e
 	log.Printf("no price data found for hotel %s", hotelID)
 	return 0, hotelName, fmt.Errorf("no price data found")
 }
+
+func (app *application) getBestMatchingOffer(ctx context.Context, favorite models.Favorite, prefs *models.Preferences) (RateOffer, string, error) {
+	hotelID, criteria := favorite.HotelID, favorite.Criteria
+
+	hotelName, err := app.getHotelName(ctx, hotelID)
+	if err != nil {
+		return RateOffer{}, "", err
+	}
+
+	checkIn, checkOut, occupancy := favoriteStay(favorite, prefs, time.Now())
+
+	requestData := MinRateSearchRequest{
+		HotelIds:         []string{hotelID},
+		Checkin:          checkIn,
+		Checkout:         checkOut,
+		Occupancies:      []Occupancy{occupancy},
+		Currency:         liteAPICurrency,
+		GuestNationality: prefs.Nationality,
+		Timeout:          30,
+	}
+
+	offers, err := app.getRatesFromAPI(ctx, requestData, app.config.apiKey)
+	if err != nil {
+		return RateOffer{}, hotelName, fmt.Errorf("failed to get rates: %v", err)
+	}
+
+	// Criteria amounts are in the favorite's currency, so offers are matched
+	// in it. The original offer is returned, and observed in the currency
+	// LiteAPI quoted it in.
+	converted := make([]RateOffer, len(offers))
+	for i, offer := range offers {
+		converted[i], err = app.offerInCurrency(offer, favorite.Currency)
+		if err != nil {
+			return RateOffer{}, hotelName, fmt.Errorf("failed to convert offers to %s: %v", favorite.Currency, err)
+		}
+	}
+
+	i, ok := cheapestMatchingOffer(converted, criteria)
+	if !ok {
+		log.Printf("no offer matching criteria found for hotel %s", hotelID)
+		return RateOffer{}, hotelName, fmt.Errorf("no matching offer found")
+	}
+
+	return offers[i], hotelName, nil
+}
@@ -0,0 +1,355 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	models "github.com/madfelps/challenge-nuitee/internal/data"
+)
+
+// liteAPICancelTimeLayout is the format LiteAPI uses for cancellation
+// deadlines. Times are expressed in the timezone of the policy, GMT in
+// practice.
+const liteAPICancelTimeLayout = "2006-01-02 15:04:05"
+
+// cancelPolicyLocation returns the location a cancellation deadline is
+// expressed in, UTC when the policy names none or one we do not know.
+func cancelPolicyLocation(timezone string) *time.Location {
+	if timezone == "" {
+		return time.UTC
+	}
+
+	location, err := time.LoadLocation(timezone)
+	if err != nil {
+		return time.UTC
+	}
+
+	return location
+}
+
+type liteAPIAmount struct {
+	Amount   float64 `json:"amount"`
+	Currency string  `json:"currency"`
+}
+
+type liteAPITaxOrFee struct {
+	Included    bool    `json:"included"`
+	Description string  `json:"description"`
+	Amount      float64 `json:"amount"`
+	Currency    string  `json:"currency"`
+}
+
+type liteAPICancelPolicyInfo struct {
+	CancelTime string  `json:"cancelTime"`
+	Amount     float64 `json:"amount"`
+	Currency   string  `json:"currency"`
+	Type       string  `json:"type"`
+	Timezone   string  `json:"timezone"`
+}
+
+type liteAPIRate struct {
+	RateID          string `json:"rateId"`
+	OccupancyNumber int    `json:"occupancyNumber"`
+	Name            string `json:"name"`
+	MaxOccupancy    int    `json:"maxOccupancy"`
+	AdultCount      int    `json:"adultCount"`
+	ChildCount      int    `json:"childCount"`
+	BoardType       string `json:"boardType"`
+	BoardName       string `json:"boardName"`
+	RetailRate      struct {
+		Total        []liteAPIAmount   `json:"total"`
+		TaxesAndFees []liteAPITaxOrFee `json:"taxesAndFees"`
+	} `json:"retailRate"`
+	CancellationPolicies struct {
+		CancelPolicyInfos []liteAPICancelPolicyInfo `json:"cancelPolicyInfos"`
+		RefundableTag     string                    `json:"refundableTag"`
+	} `json:"cancellationPolicies"`
+}
+
+type liteAPIRoomType struct {
+	RoomTypeID string        `json:"roomTypeId"`
+	OfferID    string        `json:"offerId"`
+	Rates      []liteAPIRate `json:"rates"`
+}
+
+type liteAPIRatesResponse struct {
+	Data []struct {
+		HotelID   string            `json:"hotelId"`
+		RoomTypes []liteAPIRoomType `json:"roomTypes"`
+	} `json:"data"`
+}
+
+// RateOffer is a single bookable rate for a hotel, flattened from the
+// LiteAPI full-rates response.
+type RateOffer struct {
+	OfferID              string     `json:"offer_id"`
+	RateID               string     `json:"rate_id"`
+	RoomName             string     `json:"room_name"`
+	BoardType            string     `json:"board_type"`
+	BoardName            string     `json:"board_name"`
+	MaxOccupancy         int        `json:"max_occupancy"`
+	Refundable           bool       `json:"refundable"`
+	CancellationDeadline *time.Time `json:"cancellation_deadline,omitempty"`
+	Price                float64    `json:"price"`
+	TaxesIncluded        float64    `json:"taxes_included"`
+	TaxesExcluded        float64    `json:"taxes_excluded"`
+	Currency             string     `json:"currency"`
+}
+
+type HotelRatesResponse struct {
+	HotelID  string      `json:"hotel_id"`
+	CheckIn  string      `json:"check_in"`
+	CheckOut string      `json:"check_out"`
+	Adults   int         `json:"adults"`
+	Children []int       `json:"children"`
+	Currency string      `json:"currency"`
+	Offers   []RateOffer `json:"offers"`
+}
+
+func (app *application) listHotelRatesHandler(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+	hotelID := params.ByName("hotel_id")
+
+	if hotelID == "" {
+		app.errorResponse(w, r, http.StatusBadRequest, "hotel_id parameter is required")
+		return
+	}
+
+	qs := r.URL.Query()
+
+	checkIn := time.Now().AddDate(0, 0, 30).Format("2006-01-02")
+	checkOut := time.Now().AddDate(0, 0, 31).Format("2006-01-02")
+
+	if qs.Has("check_in") || qs.Has("check_out") {
+		in, errIn := time.Parse("2006-01-02", qs.Get("check_in"))
+		out, errOut := time.Parse("2006-01-02", qs.Get("check_out"))
+		if errIn != nil || errOut != nil || !out.After(in) {
+			app.errorResponse(w, r, http.StatusBadRequest, "check_in and check_out must be YYYY-MM-DD dates with check_out after check_in")
+			return
+		}
+		checkIn, checkOut = qs.Get("check_in"), qs.Get("check_out")
+	}
+
+	occupancy := Occupancy{Adults: 1, Children: []int{}}
+
+	if adultsStr := qs.Get("adults"); adultsStr != "" {
+		adults, err := strconv.Atoi(adultsStr)
+		if err != nil || adults < 1 || adults > 8 {
+			app.errorResponse(w, r, http.StatusBadRequest, "invalid adults parameter (must be between 1 and 8)")
+			return
+		}
+		occupancy.Adults = adults
+	}
+
+	if childrenStr := qs.Get("children"); childrenStr != "" {
+		for _, ageStr := range strings.Split(childrenStr, ",") {
+			age, err := strconv.Atoi(strings.TrimSpace(ageStr))
+			if err != nil || age < 0 || age > 17 {
+				app.errorResponse(w, r, http.StatusBadRequest, "invalid children parameter (must be a comma-separated list of ages between 0 and 17)")
+				return
+			}
+			occupancy.Children = append(occupancy.Children, age)
+		}
+	}
+
+	currency, ok := app.readDisplayCurrency(w, r)
+	if !ok {
+		return
+	}
+
+	guestNationality, ok := readGuestNationalityParam(qs)
+	if !ok {
+		app.errorResponse(w, r, http.StatusBadRequest, "invalid guest_nationality parameter (must be an ISO 3166-1 alpha-2 country code)")
+		return
+	}
+
+	apiKey := app.liteAPIKey(r)
+
+	requestData := MinRateSearchRequest{
+		HotelIds:         []string{hotelID},
+		Checkin:          checkIn,
+		Checkout:         checkOut,
+		Occupancies:      []Occupancy{occupancy},
+		Currency:         liteAPICurrency,
+		GuestNationality: guestNationality,
+		Timeout:          30,
+	}
+
+	offers, err := app.getRatesFromAPI(r.Context(), requestData, apiKey)
+	if err != nil {
+		app.upstreamErrorResponse(w, r, err, "failed to get hotel rates")
+		return
+	}
+
+	// Offers are requested in liteAPICurrency and converted with our own
+	// exchange rates, like min prices.
+	for i := range offers {
+		offers[i], err = app.offerInCurrency(offers[i], currency)
+		if err != nil {
+			app.convertPriceResponse(w, r, err)
+			return
+		}
+	}
+
+	response := HotelRatesResponse{
+		HotelID:  hotelID,
+		CheckIn:  checkIn,
+		CheckOut: checkOut,
+		Adults:   occupancy.Adults,
+		Children: occupancy.Children,
+		Currency: currency,
+		Offers:   offers,
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"data": response}, nil)
+	if err != nil {
+		app.logError(r, err)
+		app.errorResponse(w, r, http.StatusInternalServerError, "failed to encode response")
+		return
+	}
+}
+
+// getRatesFromAPI calls the LiteAPI full-rates search and returns every offer
+// for the requested hotels, cheapest first.
+func (app *application) getRatesFromAPI(ctx context.Context, requestData MinRateSearchRequest, apiKey string) ([]RateOffer, error) {
+
+	var response liteAPIRatesResponse
+	err := app.callLiteAPI(ctx, http.MethodPost, liteAPIEndpointRates, nil, requestData, apiKey, &response)
+	if err != nil {
+		return nil, err
+	}
+
+	return offersFromRatesResponse(response), nil
+}
+
+func offersFromRatesResponse(response liteAPIRatesResponse) []RateOffer {
+	offers := make([]RateOffer, 0)
+
+	for _, hotel := range response.Data {
+		for _, roomType := range hotel.RoomTypes {
+			for _, rate := range roomType.Rates {
+				offers = append(offers, offerFromRate(roomType.OfferID, rate))
+			}
+		}
+	}
+
+	sort.SliceStable(offers, func(i, j int) bool {
+		return offers[i].Price < offers[j].Price
+	})
+
+	return offers
+}
+
+// offerInCurrency converts the price and taxes of offer into currency.
+func (app *application) offerInCurrency(offer RateOffer, currency string) (RateOffer, error) {
+	from := offer.Currency
+	if from == "" {
+		from = liteAPICurrency
+	}
+
+	for _, amount := range []*float64{&offer.Price, &offer.TaxesIncluded, &offer.TaxesExcluded} {
+		conversion, err := app.fx.Convert(*amount, from, currency)
+		if err != nil {
+			return RateOffer{}, err
+		}
+		*amount = conversion.Amount
+	}
+
+	offer.Currency = currency
+	return offer, nil
+}
+
+func offerFromRate(offerID string, rate liteAPIRate) RateOffer {
+	offer := RateOffer{
+		OfferID:      offerID,
+		RateID:       rate.RateID,
+		RoomName:     rate.Name,
+		BoardType:    rate.BoardType,
+		BoardName:    rate.BoardName,
+		MaxOccupancy: rate.MaxOccupancy,
+		Refundable:   rate.CancellationPolicies.RefundableTag == "RFN",
+	}
+
+	if len(rate.RetailRate.Total) > 0 {
+		offer.Price = rate.RetailRate.Total[0].Amount
+		offer.Currency = rate.RetailRate.Total[0].Currency
+	}
+
+	for _, tax := range rate.RetailRate.TaxesAndFees {
+		if tax.Included {
+			offer.TaxesIncluded += tax.Amount
+		} else {
+			offer.TaxesExcluded += tax.Amount
+		}
+	}
+
+	// Free cancellation ends at the earliest point a penalty kicks in.
+	if offer.Refundable {
+		for _, info := range rate.CancellationPolicies.CancelPolicyInfos {
+			deadline, err := time.ParseInLocation(liteAPICancelTimeLayout, info.CancelTime, cancelPolicyLocation(info.Timezone))
+			if err != nil {
+				continue
+			}
+			deadline = deadline.UTC()
+			if offer.CancellationDeadline == nil || deadline.Before(*offer.CancellationDeadline) {
+				offer.CancellationDeadline = &deadline
+			}
+		}
+	}
+
+	return offer
+}
+
+// matchesCriteria reports whether an offer satisfies every constraint of a
+// favorite's watch criteria. The offer must be priced in the favorite's
+// currency.
+func matchesCriteria(offer RateOffer, criteria models.WatchCriteria) bool {
+	if criteria.RefundableOnly && !offer.Refundable {
+		return false
+	}
+
+	if len(criteria.BoardTypes) > 0 {
+		found := false
+		for _, boardType := range criteria.BoardTypes {
+			if strings.EqualFold(boardType, offer.BoardType) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if criteria.MinCapacity > 0 && offer.MaxOccupancy < criteria.MinCapacity {
+		return false
+	}
+
+	if criteria.MaxTaxes != nil && offer.TaxesIncluded+offer.TaxesExcluded > *criteria.MaxTaxes {
+		return false
+	}
+
+	return true
+}
+
+// cheapestMatchingOffer returns the index of the lowest priced offer
+// satisfying criteria.
+func cheapestMatchingOffer(offers []RateOffer, criteria models.WatchCriteria) (int, bool) {
+	best := -1
+
+	for i, offer := range offers {
+		if offer.Price <= 0 || !matchesCriteria(offer, criteria) {
+			continue
+		}
+		if best < 0 || offer.Price < offers[best].Price {
+			best = i
+		}
+	}
+
+	return best, best >= 0
+}
@@ -0,0 +1,396 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	models "github.com/madfelps/challenge-nuitee/internal/data"
+)
+
+const sampleRatesResponse = `{
+	"data": [{
+		"hotelId": "lp1897",
+		"roomTypes": [{
+			"roomTypeId": "rt1",
+			"offerId": "offer-1",
+			"rates": [{
+				"rateId": "rate-ro",
+				"occupancyNumber": 1,
+				"name": "Standard Double Room",
+				"maxOccupancy": 2,
+				"adultCount": 1,
+				"childCount": 0,
+				"boardType": "RO",
+				"boardName": "Room Only",
+				"retailRate": {
+					"total": [{"amount": 98.5, "currency": "USD"}],
+					"taxesAndFees": [{"included": true, "description": "VAT", "amount": 8.5, "currency": "USD"}]
+				},
+				"cancellationPolicies": {
+					"cancelPolicyInfos": [],
+					"refundableTag": "NRFN"
+				}
+			}]
+		}, {
+			"roomTypeId": "rt2",
+			"offerId": "offer-2",
+			"rates": [{
+				"rateId": "rate-bb",
+				"occupancyNumber": 1,
+				"name": "Family Room",
+				"maxOccupancy": 4,
+				"adultCount": 1,
+				"childCount": 0,
+				"boardType": "BB",
+				"boardName": "Bed and Breakfast",
+				"retailRate": {
+					"total": [{"amount": 140, "currency": "USD"}],
+					"taxesAndFees": [
+						{"included": true, "description": "VAT", "amount": 10, "currency": "USD"},
+						{"included": false, "description": "City tax", "amount": 4, "currency": "USD"}
+					]
+				},
+				"cancellationPolicies": {
+					"cancelPolicyInfos": [
+						{"cancelTime": "2030-06-10 12:00:00", "amount": 140, "currency": "USD", "type": "amount", "timezone": "GMT"},
+						{"cancelTime": "2030-06-05 12:00:00", "amount": 70, "currency": "USD", "type": "amount", "timezone": "GMT"}
+					],
+					"refundableTag": "RFN"
+				}
+			}]
+		}]
+	}]
+}`
+
+func TestOffersFromRatesResponse(t *testing.T) {
+	var response liteAPIRatesResponse
+	if err := decodeLiteAPIResponse(liteAPIEndpointRates, []byte(sampleRatesResponse), &response); err != nil {
+		t.Fatal(err)
+	}
+
+	offers := offersFromRatesResponse(response)
+
+	if len(offers) != 2 {
+		t.Fatalf("offersFromRatesResponse() returned %d offers, expected 2", len(offers))
+	}
+
+	if offers[0].RateID != "rate-ro" || offers[1].RateID != "rate-bb" {
+		t.Errorf("offers not sorted by price: %s, %s", offers[0].RateID, offers[1].RateID)
+	}
+
+	bb := offers[1]
+
+	if !bb.Refundable {
+		t.Errorf("expected %s to be refundable", bb.RateID)
+	}
+
+	expectedDeadline := time.Date(2030, 6, 5, 12, 0, 0, 0, time.UTC)
+	if bb.CancellationDeadline == nil || !bb.CancellationDeadline.Equal(expectedDeadline) {
+		t.Errorf("CancellationDeadline = %v, expected %v", bb.CancellationDeadline, expectedDeadline)
+	}
+
+	if bb.TaxesIncluded != 10 || bb.TaxesExcluded != 4 {
+		t.Errorf("taxes = %.2f included / %.2f excluded, expected 10 / 4", bb.TaxesIncluded, bb.TaxesExcluded)
+	}
+
+	if offers[0].Refundable || offers[0].CancellationDeadline != nil {
+		t.Errorf("expected %s to be non-refundable without deadline", offers[0].RateID)
+	}
+}
+
+func TestCheapestMatchingOffer(t *testing.T) {
+	maxTaxes := 12.0
+
+	offers := []RateOffer{
+		{RateID: "ro", BoardType: "RO", MaxOccupancy: 2, Price: 98.5, TaxesIncluded: 8.5},
+		{RateID: "bb", BoardType: "BB", MaxOccupancy: 4, Refundable: true, Price: 140, TaxesIncluded: 10, TaxesExcluded: 4},
+		{RateID: "hb", BoardType: "HB", MaxOccupancy: 2, Refundable: true, Price: 180, TaxesIncluded: 11},
+	}
+
+	tests := []struct {
+		name     string
+		criteria models.WatchCriteria
+		expected string
+	}{
+		{
+			name:     "no criteria",
+			criteria: models.WatchCriteria{},
+			expected: "ro",
+		},
+		{
+			name:     "refundable only",
+			criteria: models.WatchCriteria{RefundableOnly: true},
+			expected: "bb",
+		},
+		{
+			name:     "board type",
+			criteria: models.WatchCriteria{BoardTypes: []string{"hb", "FB"}},
+			expected: "hb",
+		},
+		{
+			name:     "minimum capacity",
+			criteria: models.WatchCriteria{MinCapacity: 3},
+			expected: "bb",
+		},
+		{
+			name:     "max taxes",
+			criteria: models.WatchCriteria{RefundableOnly: true, MaxTaxes: &maxTaxes},
+			expected: "hb",
+		},
+		{
+			name:     "nothing matches",
+			criteria: models.WatchCriteria{BoardTypes: []string{"AI"}},
+			expected: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rateID := ""
+			if i, ok := cheapestMatchingOffer(offers, tt.criteria); ok {
+				rateID = offers[i].RateID
+			}
+
+			if rateID != tt.expected {
+				t.Errorf("cheapestMatchingOffer() = %q, expected %q", rateID, tt.expected)
+			}
+		})
+	}
+}
+
+func TestOfferFromRate(t *testing.T) {
+	tests := []struct {
+		name     string
+		rate     liteAPIRate
+		expected RateOffer
+	}{
+		{
+			name: "empty rate",
+			rate: liteAPIRate{RateID: "r1"},
+			expected: RateOffer{
+				OfferID: "offer",
+				RateID:  "r1",
+			},
+		},
+		{
+			name: "non-refundable ignores cancellation deadlines",
+			rate: func() liteAPIRate {
+				rate := liteAPIRate{RateID: "r2", Name: "Double", BoardType: "RO", MaxOccupancy: 2}
+				rate.RetailRate.Total = []liteAPIAmount{{Amount: 80, Currency: "USD"}, {Amount: 75, Currency: "EUR"}}
+				rate.CancellationPolicies.RefundableTag = "NRFN"
+				rate.CancellationPolicies.CancelPolicyInfos = []liteAPICancelPolicyInfo{{CancelTime: "2030-06-05 12:00:00"}}
+				return rate
+			}(),
+			expected: RateOffer{
+				OfferID:      "offer",
+				RateID:       "r2",
+				RoomName:     "Double",
+				BoardType:    "RO",
+				MaxOccupancy: 2,
+				Price:        80,
+				Currency:     "USD",
+			},
+		},
+		{
+			name: "refundable with invalid deadline and taxes",
+			rate: func() liteAPIRate {
+				rate := liteAPIRate{RateID: "r3"}
+				rate.RetailRate.Total = []liteAPIAmount{{Amount: 100, Currency: "USD"}}
+				rate.RetailRate.TaxesAndFees = []liteAPITaxOrFee{{Included: true, Amount: 5}, {Included: true, Amount: 2}, {Amount: 3}}
+				rate.CancellationPolicies.RefundableTag = "RFN"
+				rate.CancellationPolicies.CancelPolicyInfos = []liteAPICancelPolicyInfo{{CancelTime: "soon"}}
+				return rate
+			}(),
+			expected: RateOffer{
+				OfferID:       "offer",
+				RateID:        "r3",
+				Refundable:    true,
+				Price:         100,
+				TaxesIncluded: 7,
+				TaxesExcluded: 3,
+				Currency:      "USD",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			offer := offerFromRate("offer", tt.rate)
+
+			if offer != tt.expected {
+				t.Errorf("offerFromRate() = %+v, expected %+v", offer, tt.expected)
+			}
+		})
+	}
+}
+
+func TestOfferFromRateCancellationTimezone(t *testing.T) {
+	tests := []struct {
+		name     string
+		policies []liteAPICancelPolicyInfo
+		expected time.Time
+	}{
+		{
+			name:     "GMT",
+			policies: []liteAPICancelPolicyInfo{{CancelTime: "2030-06-05 12:00:00", Timezone: "GMT"}},
+			expected: time.Date(2030, 6, 5, 12, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "policy timezone",
+			policies: []liteAPICancelPolicyInfo{{CancelTime: "2030-06-05 12:00:00", Timezone: "America/New_York"}},
+			expected: time.Date(2030, 6, 5, 16, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "no timezone is UTC",
+			policies: []liteAPICancelPolicyInfo{{CancelTime: "2030-06-05 12:00:00"}},
+			expected: time.Date(2030, 6, 5, 12, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "unknown timezone is UTC",
+			policies: []liteAPICancelPolicyInfo{{CancelTime: "2030-06-05 12:00:00", Timezone: "Mars/Olympus"}},
+			expected: time.Date(2030, 6, 5, 12, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "earliest across timezones",
+			policies: []liteAPICancelPolicyInfo{
+				{CancelTime: "2030-06-05 10:00:00", Timezone: "America/New_York"},
+				{CancelTime: "2030-06-05 13:00:00", Timezone: "GMT"},
+			},
+			expected: time.Date(2030, 6, 5, 13, 0, 0, 0, time.UTC),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rate := liteAPIRate{RateID: "r1"}
+			rate.CancellationPolicies.RefundableTag = "RFN"
+			rate.CancellationPolicies.CancelPolicyInfos = tt.policies
+
+			offer := offerFromRate("offer", rate)
+
+			if offer.CancellationDeadline == nil || !offer.CancellationDeadline.Equal(tt.expected) {
+				t.Errorf("CancellationDeadline = %v, expected %v", offer.CancellationDeadline, tt.expected)
+			}
+		})
+	}
+}
+
+func TestListHotelRatesHandler(t *testing.T) {
+	var upstreamRequest MinRateSearchRequest
+
+	app := newTestApplication(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&upstreamRequest); err != nil {
+			t.Error(err)
+		}
+		io.WriteString(w, sampleRatesResponse)
+	}))
+
+	router := httprouter.New()
+	router.HandlerFunc(http.MethodGet, "/v1/hotels/:hotel_id/rates", app.listHotelRatesHandler)
+
+	tests := []struct {
+		name             string
+		query            string
+		expectedStatus   int
+		expectedCurrency string
+		expectedPrice    float64
+		expectedTaxes    float64
+	}{
+		{name: "default currency", query: "", expectedStatus: http.StatusOK, expectedCurrency: "USD", expectedPrice: 98.5, expectedTaxes: 8.5},
+		{name: "converted", query: "?currency=eur", expectedStatus: http.StatusOK, expectedCurrency: "EUR", expectedPrice: 88.65, expectedTaxes: 7.65},
+		{name: "invalid currency", query: "?currency=euro", expectedStatus: http.StatusBadRequest},
+		{name: "unsupported currency", query: "?currency=JPY", expectedStatus: http.StatusUnprocessableEntity},
+		{name: "invalid adults", query: "?adults=9", expectedStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := app.contextSetUser(httptest.NewRequest(http.MethodGet, "/v1/hotels/lp1897/rates"+tt.query, nil), models.AnonymousUser)
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, r)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("status = %d, expected %d: %s", w.Code, tt.expectedStatus, w.Body)
+			}
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+
+			if upstreamRequest.Currency != liteAPICurrency {
+				t.Errorf("upstream currency = %s, expected %s", upstreamRequest.Currency, liteAPICurrency)
+			}
+
+			var body struct {
+				Data HotelRatesResponse `json:"data"`
+			}
+			if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+				t.Fatal(err)
+			}
+
+			if body.Data.Currency != tt.expectedCurrency || len(body.Data.Offers) != 2 {
+				t.Fatalf("response = %+v, expected 2 offers in %s", body.Data, tt.expectedCurrency)
+			}
+
+			cheapest := body.Data.Offers[0]
+			if cheapest.Currency != tt.expectedCurrency || cheapest.Price != tt.expectedPrice || cheapest.TaxesIncluded != tt.expectedTaxes {
+				t.Errorf("cheapest offer = %.2f %s with %.2f taxes, expected %.2f %s with %.2f taxes",
+					cheapest.Price, cheapest.Currency, cheapest.TaxesIncluded, tt.expectedPrice, tt.expectedCurrency, tt.expectedTaxes)
+			}
+		})
+	}
+}
+
+func TestGetBestMatchingOffer(t *testing.T) {
+	app := newTestApplication(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case liteAPIEndpointHotelDetails:
+			io.WriteString(w, `{"data": {"id": "lp1897", "name": "Grand Hotel"}}`)
+		case liteAPIEndpointRates:
+			io.WriteString(w, sampleRatesResponse)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+
+	// rate-ro has 8.50 USD of taxes, which is 7.65 EUR.
+	tests := []struct {
+		name     string
+		maxTaxes float64
+		expected string
+	}{
+		{name: "taxes compared in the favorite currency", maxTaxes: 8, expected: "rate-ro"},
+		{name: "taxes above the limit", maxTaxes: 7, expected: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			favorite := models.Favorite{
+				HotelID:  "lp1897",
+				Currency: "EUR",
+				Criteria: models.WatchCriteria{BoardTypes: []string{"RO"}, MaxTaxes: &tt.maxTaxes},
+			}
+
+			offer, _, err := app.getBestMatchingOffer(context.Background(), favorite, models.DefaultPreferences(1))
+			if tt.expected == "" {
+				if err == nil {
+					t.Errorf("matched %s, expected no offer", offer.RateID)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if offer.RateID != tt.expected || offer.Price != 98.5 || offer.Currency != "USD" {
+				t.Errorf("offer = %s at %.2f %s, expected %s at 98.50 USD", offer.RateID, offer.Price, offer.Currency, tt.expected)
+			}
+		})
+	}
+}
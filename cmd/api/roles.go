@@ -0,0 +1,141 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/julienschmidt/httprouter"
+	models "github.com/madfelps/challenge-nuitee/internal/data"
+	"github.com/madfelps/challenge-nuitee/internal/validator"
+)
+
+type UpdateUserRolesRequest struct {
+	Roles []string `json:"roles"`
+}
+
+func (app *application) listRolesHandler(w http.ResponseWriter, r *http.Request) {
+	roles, err := app.models.Roles.List()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"data": map[string]interface{}{"roles": roles}}, nil)
+	if err != nil {
+		app.logError(r, err)
+		app.errorResponse(w, r, http.StatusInternalServerError, "failed to encode response")
+		return
+	}
+}
+
+func (app *application) showUserRolesHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := app.readRoleTargetParam(w, r)
+	if !ok {
+		return
+	}
+
+	roles, err := app.models.Roles.GetAllForUser(userID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.writeUserRoles(w, r, userID, roles)
+}
+
+// updateUserRolesHandler replaces the roles of a user. Admins cannot remove
+// their own admin role, so there is always someone left to assign roles.
+func (app *application) updateUserRolesHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := app.readRoleTargetParam(w, r)
+	if !ok {
+		return
+	}
+
+	var req UpdateUserRolesRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		app.errorResponse(w, r, http.StatusBadRequest, "invalid JSON")
+		return
+	}
+
+	v := validator.New()
+	ValidateRoles(v, req.Roles)
+
+	if userID == app.contextGetUser(r).ID {
+		v.Check(slices.Contains(req.Roles, models.RoleAdmin), "roles", "must keep the admin role on your own account")
+	}
+
+	if !v.Valid() {
+		app.errorResponse(w, r, http.StatusUnprocessableEntity, v.Errors)
+		return
+	}
+
+	err = app.models.Roles.SetForUser(userID, req.Roles)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrUnknownRole):
+			app.errorResponse(w, r, http.StatusUnprocessableEntity, map[string]string{"roles": "must only contain existing roles"})
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.logger.PrintInfo("user roles updated", map[string]string{
+		"user_id":    strconv.Itoa(userID),
+		"updated_by": strconv.Itoa(app.contextGetUser(r).ID),
+	})
+
+	app.writeUserRoles(w, r, userID, req.Roles)
+}
+
+func (app *application) readRoleTargetParam(w http.ResponseWriter, r *http.Request) (int, bool) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	userID, err := strconv.Atoi(params.ByName("id"))
+	if err != nil || userID <= 0 {
+		app.errorResponse(w, r, http.StatusBadRequest, "invalid id parameter")
+		return 0, false
+	}
+
+	exists, err := app.models.Users.Exists(userID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return 0, false
+	}
+
+	if !exists {
+		app.notFoundResponse(w, r)
+		return 0, false
+	}
+
+	return userID, true
+}
+
+func (app *application) writeUserRoles(w http.ResponseWriter, r *http.Request, userID int, roles []string) {
+	response := map[string]interface{}{
+		"user_id": userID,
+		"roles":   roles,
+	}
+
+	err := app.writeJSON(w, http.StatusOK, envelope{"data": response}, nil)
+	if err != nil {
+		app.logError(r, err)
+		app.errorResponse(w, r, http.StatusInternalServerError, "failed to encode response")
+		return
+	}
+}
+
+func ValidateRoles(v *validator.Validator, roles []string) {
+	v.Check(roles != nil, "roles", "must be provided")
+
+	for i, role := range roles {
+		if slices.Contains(roles[:i], role) {
+			v.AddError("roles", "must not contain duplicate values")
+			break
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/madfelps/challenge-nuitee/internal/validator"
+)
+
+func TestValidateRoles(t *testing.T) {
+	tests := []struct {
+		name     string
+		roles    []string
+		expected bool
+	}{
+		{name: "single role", roles: []string{"admin"}, expected: true},
+		{name: "several roles", roles: []string{"user", "support"}, expected: true},
+		{name: "no roles", roles: []string{}, expected: true},
+		{name: "missing roles", roles: nil, expected: false},
+		{name: "duplicate roles", roles: []string{"user", "user"}, expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := validator.New()
+			ValidateRoles(v, tt.roles)
+
+			if v.Valid() != tt.expected {
+				t.Errorf("ValidateRoles() = %v, expected %v. Errors: %v", v.Valid(), tt.expected, v.Errors)
+			}
+		})
+	}
+}
@@ -1,25 +1,99 @@
 package main
 
 import (
+	"expvar"
 	"net/http"
 
 	"github.com/julienschmidt/httprouter"
+	models "github.com/madfelps/challenge-nuitee/internal/data"
 )
 
 func (app *application) routes() http.Handler {
+	return app.recoverPanic(app.rateLimit(app.authenticateTenant(app.authenticate(app.router()))))
+}
+
+// router returns the routes without the middleware every request goes
+// through.
+func (app *application) router() *httprouter.Router {
 	router := httprouter.New()
 
 	router.NotFound = http.HandlerFunc(app.notFoundResponse)
 	router.MethodNotAllowed = http.HandlerFunc(app.methodNotAllowedResponse)
 
 	router.HandlerFunc(http.MethodGet, "/v1/healthcheck", app.healthcheckHandler)
+	router.HandlerFunc(http.MethodGet, "/debug/vars", app.requirePermission(models.PermissionMetricsRead, expvar.Handler().ServeHTTP))
 	router.HandlerFunc(http.MethodGet, "/v1/hotels", app.listHotelsHandler)
-	router.HandlerFunc(http.MethodGet, "/v1/hotels/:hotel_id", app.getHotelPriceHandler)
+	router.HandlerFunc(http.MethodGet, "/v1/hotels/:hotel_id", app.showHotelHandler)
+	router.HandlerFunc(http.MethodGet, "/v1/hotels/:hotel_id/rates", app.listHotelRatesHandler)
+
+	router.HandlerFunc(http.MethodGet, "/v1/fx/rates", app.showFXRatesHandler)
 
 	router.HandlerFunc(http.MethodPost, "/v1/users", app.createUserHandler)
-	router.HandlerFunc(http.MethodGet, "/v1/users", app.listUsersHandler)
+	router.HandlerFunc(http.MethodGet, "/v1/users", app.requirePermission(models.PermissionUsersRead, app.listUsersHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/users/:id/preferences", app.requireOwner("id", app.showPreferencesHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/users/:id", app.requireOwner("id", app.showUserHandler))
+	router.HandlerFunc(http.MethodPut, "/v1/users/:id", app.updateUserHandler)
+	router.HandlerFunc(http.MethodPatch, "/v1/users/:id", app.requireSession(app.requireOwner("id", app.patchUserHandler)))
+	router.HandlerFunc(http.MethodDelete, "/v1/users/:id", app.requireSession(app.requireOwner("id", app.deleteUserHandler)))
+	router.HandlerFunc(http.MethodGet, "/v1/users/:id/export", app.requireOwner("id", app.exportUserHandler))
+	router.HandlerFunc(http.MethodPut, "/v1/users/:id/preferences", app.requireOwner("id", app.updatePreferencesHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/users/:id/roles", app.requirePermission(models.PermissionUsersRead, app.showUserRolesHandler))
+	router.HandlerFunc(http.MethodPut, "/v1/users/:id/roles", app.requireSession(app.requirePermission(models.PermissionRolesWrite, app.updateUserRolesHandler)))
+
+	router.HandlerFunc(http.MethodGet, "/v1/roles", app.requirePermission(models.PermissionRolesWrite, app.listRolesHandler))
+
+	router.HandlerFunc(http.MethodPost, "/v1/favorites/:user_id", app.requireOwner("user_id", app.createFavoriteHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/favorites/:user_id", app.requireOwner("user_id", app.listFavoritesHandler))
+
+	router.HandlerFunc(http.MethodGet, "/v1/me", app.requireAuthenticatedUser(app.showCurrentUserHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/me/preferences", app.requireAuthenticatedUser(app.showPreferencesHandler))
+	router.HandlerFunc(http.MethodPut, "/v1/me/preferences", app.requireAuthenticatedUser(app.updatePreferencesHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/me/favorites", app.requireAuthenticatedUser(app.listFavoritesHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/me/favorites", app.requireAuthenticatedUser(app.createFavoriteHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/me/favorites/:favorite_id", app.requireAuthenticatedUser(app.showFavoriteHandler))
+	router.HandlerFunc(http.MethodPatch, "/v1/me/favorites/:favorite_id", app.requireAuthenticatedUser(app.updateFavoriteHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/me/favorites/:favorite_id", app.requireAuthenticatedUser(app.deleteFavoriteHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/me/favorites/:favorite_id/pause", app.requireAuthenticatedUser(app.pauseFavoriteHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/me/favorites/:favorite_id/resume", app.requireAuthenticatedUser(app.resumeFavoriteHandler))
+
+	router.HandlerFunc(http.MethodGet, "/v1/me/watchlists", app.requireAuthenticatedUser(app.listWatchlistsHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/me/watchlists", app.requireAuthenticatedUser(app.createWatchlistHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/me/watchlists/:watchlist_id", app.requireAuthenticatedUser(app.showWatchlistHandler))
+	router.HandlerFunc(http.MethodPatch, "/v1/me/watchlists/:watchlist_id", app.requireAuthenticatedUser(app.updateWatchlistHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/me/watchlists/:watchlist_id", app.requireAuthenticatedUser(app.deleteWatchlistHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/me/watchlists/:watchlist_id/actions", app.requireAuthenticatedUser(app.watchlistActionHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/me/api-keys", app.requireSession(app.listAPIKeysHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/me/api-keys", app.requireSession(app.createAPIKeyHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/me/api-keys/:api_key_id", app.requireSession(app.deleteAPIKeyHandler))
+
+	router.HandlerFunc(http.MethodGet, "/v1/organizations", app.requireAuthenticatedUser(app.listOrganizationsHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/organizations", app.requireAuthenticatedUser(app.createOrganizationHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/organizations/:org_id", app.requireMember(app.showOrganizationHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/organizations/:org_id", app.requireMember(app.deleteOrganizationHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/organizations/:org_id/members", app.requireMember(app.listMembersHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/organizations/:org_id/members", app.requireMember(app.addMemberHandler))
+	router.HandlerFunc(http.MethodPatch, "/v1/organizations/:org_id/members/:user_id", app.requireMember(app.updateMemberHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/organizations/:org_id/members/:user_id", app.requireMember(app.removeMemberHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/organizations/:org_id/favorites", app.requireMember(app.listFavoritesHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/organizations/:org_id/favorites", app.requireMember(app.createFavoriteHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/organizations/:org_id/favorites/:favorite_id", app.requireMember(app.showFavoriteHandler))
+	router.HandlerFunc(http.MethodPatch, "/v1/organizations/:org_id/favorites/:favorite_id", app.requireMember(app.updateFavoriteHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/organizations/:org_id/favorites/:favorite_id", app.requireMember(app.deleteFavoriteHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/organizations/:org_id/favorites/:favorite_id/pause", app.requireMember(app.pauseFavoriteHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/organizations/:org_id/favorites/:favorite_id/resume", app.requireMember(app.resumeFavoriteHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/organizations/:org_id/watchlists", app.requireMember(app.listWatchlistsHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/organizations/:org_id/watchlists", app.requireMember(app.createWatchlistHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/organizations/:org_id/watchlists/:watchlist_id", app.requireMember(app.showWatchlistHandler))
+	router.HandlerFunc(http.MethodPatch, "/v1/organizations/:org_id/watchlists/:watchlist_id", app.requireMember(app.updateWatchlistHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/organizations/:org_id/watchlists/:watchlist_id", app.requireMember(app.deleteWatchlistHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/organizations/:org_id/watchlists/:watchlist_id/actions", app.requireMember(app.watchlistActionHandler))
 
-	router.HandlerFunc(http.MethodPost, "/v1/favorites/:user_id", app.createFavoriteHandler)
+	router.HandlerFunc(http.MethodPost, "/v1/tokens/authentication", app.createAuthenticationTokenHandler)
+	router.HandlerFunc(http.MethodPost, "/v1/tokens/activation", app.createActivationTokenHandler)
+	router.HandlerFunc(http.MethodPost, "/v1/tokens/password-reset", app.createPasswordResetTokenHandler)
+	router.HandlerFunc(http.MethodPost, "/v1/tokens/refresh", app.refreshTokenHandler)
+	router.HandlerFunc(http.MethodPost, "/v1/tokens/logout", app.logoutHandler)
+	router.HandlerFunc(http.MethodPost, "/v1/tokens/logout-all", app.requireAuthenticatedUser(app.logoutAllHandler))
 
-	return app.recoverPanic(app.rateLimit(router))
+	return router
 }
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"net/http"
+
+	models "github.com/madfelps/challenge-nuitee/internal/data"
+)
+
+// liteAPIKey returns the LiteAPI key to use for r: the authenticated
+// tenant's own key when it has one, the server's key otherwise. Supplier
+// credentials never come from the client.
+func (app *application) liteAPIKey(r *http.Request) string {
+	if t := app.contextGetTenant(r); t != nil && t.liteAPIKey != "" {
+		return t.liteAPIKey
+	}
+	return app.config.apiKey
+}
+
+// generateTenantAPIKey returns a new random API key and the hash stored for
+// it. Only the hash is persisted.
+func generateTenantAPIKey() (string, []byte, error) {
+	randomBytes := make([]byte, 16)
+	if _, err := rand.Read(randomBytes); err != nil {
+		return "", nil, err
+	}
+
+	plaintext := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(randomBytes)
+
+	return plaintext, hashTenantAPIKey(plaintext), nil
+}
+
+func hashTenantAPIKey(plaintext string) []byte {
+	hash := sha256.Sum256([]byte(plaintext))
+	return hash[:]
+}
+
+// createTenant registers a tenant, encrypting its optional LiteAPI key, and
+// returns the generated API key.
+func (app *application) createTenant(name, liteAPIKey string) (string, error) {
+	plaintext, hash, err := generateTenantAPIKey()
+	if err != nil {
+		return "", err
+	}
+
+	t := &models.Tenant{
+		Name:       name,
+		APIKeyHash: hash,
+	}
+
+	if liteAPIKey != "" {
+		if app.secrets == nil {
+			return "", errors.New("CREDENTIALS_ENCRYPTION_KEY must be set to store a tenant LiteAPI key")
+		}
+
+		t.LiteAPIKeyEncrypted, err = app.secrets.Encrypt([]byte(liteAPIKey))
+		if err != nil {
+			return "", err
+		}
+	}
+
+	if err := app.models.Tenants.Insert(t); err != nil {
+		return "", err
+	}
+
+	return plaintext, nil
+}
+
+// loadTenant looks up the tenant owning apiKey and decrypts its LiteAPI key.
+func (app *application) loadTenant(apiKey string) (*tenant, error) {
+	record, err := app.models.Tenants.GetByKeyHash(hashTenantAPIKey(apiKey))
+	if err != nil {
+		return nil, err
+	}
+
+	t := &tenant{
+		ID:   record.ID,
+		Name: record.Name,
+	}
+
+	if record.LiteAPIKeyEncrypted != nil {
+		if app.secrets == nil {
+			return nil, fmt.Errorf("tenant %d has an encrypted LiteAPI key but CREDENTIALS_ENCRYPTION_KEY is not set", record.ID)
+		}
+
+		key, err := app.secrets.Decrypt(record.LiteAPIKeyEncrypted)
+		if err != nil {
+			return nil, fmt.Errorf("decrypting LiteAPI key of tenant %d: %w", record.ID, err)
+		}
+		t.liteAPIKey = string(key)
+	}
+
+	return t, nil
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	models "github.com/madfelps/challenge-nuitee/internal/data"
+	"github.com/madfelps/challenge-nuitee/internal/validator"
+)
+
+type CreateAuthenticationTokenRequest struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+type AuthenticationToken struct {
+	Token  string    `json:"token"`
+	Type   string    `json:"token_type"`
+	Expiry time.Time `json:"expiry"`
+}
+
+type RefreshToken struct {
+	Token  string    `json:"token"`
+	Expiry time.Time `json:"expiry"`
+}
+
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+func (app *application) createAuthenticationTokenHandler(w http.ResponseWriter, r *http.Request) {
+	var req CreateAuthenticationTokenRequest
+
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		app.errorResponse(w, r, http.StatusBadRequest, "invalid JSON")
+		return
+	}
+
+	v := validator.New()
+	ValidateEmail(v, req.Email)
+	ValidatePasswordPlaintext(v, req.Password)
+
+	if !v.Valid() {
+		app.errorResponse(w, r, http.StatusUnprocessableEntity, v.Errors)
+		return
+	}
+
+	now := time.Now()
+
+	failures, err := app.models.LoginAttempts.Failures(loginEmail(req.Email), app.clientIP(r), now.Add(-app.config.login.lockout))
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if retryAfter := app.loginRetryAfter(failures, now); retryAfter > 0 {
+		app.recordLoginAttempt(r, req.Email, nil, models.LoginOutcomeThrottled)
+		app.loginThrottledResponse(w, r, retryAfter)
+		return
+	}
+
+	user, err := app.models.Users.GetByEmail(req.Email)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrRecordNotFound):
+			verifyPassword(req.Password, dummyPasswordHash)
+			app.recordLoginAttempt(r, req.Email, nil, models.LoginOutcomeInvalidCredentials)
+			app.invalidCredentialsResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	match, needsRehash := verifyPassword(req.Password, user.PasswordHash)
+	if !match {
+		app.recordLoginAttempt(r, req.Email, user, models.LoginOutcomeInvalidCredentials)
+		app.invalidCredentialsResponse(w, r)
+		return
+	}
+
+	app.recordLoginAttempt(r, req.Email, user, models.LoginOutcomeSuccess)
+
+	if needsRehash {
+		app.upgradePasswordHash(r, user.ID, req.Password)
+	}
+
+	refreshToken, err := app.models.Tokens.New(user.ID, app.config.jwt.refreshTTL, models.ScopeRefresh)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.writeSessionTokens(w, r, user.ID, refreshToken)
+}
+
+// refreshTokenHandler rotates a refresh token: the presented token is spent
+// and a new access token and refresh token are returned. Replaying a spent
+// refresh token revokes every session descending from the same login.
+func (app *application) refreshTokenHandler(w http.ResponseWriter, r *http.Request) {
+	var req RefreshTokenRequest
+
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		app.errorResponse(w, r, http.StatusBadRequest, "invalid JSON")
+		return
+	}
+
+	v := validator.New()
+	ValidateTokenPlaintext(v, "refresh_token", req.RefreshToken)
+
+	if !v.Valid() {
+		app.errorResponse(w, r, http.StatusUnprocessableEntity, v.Errors)
+		return
+	}
+
+	refreshToken, err := app.models.Tokens.Rotate(req.RefreshToken, app.config.jwt.refreshTTL, models.ScopeRefresh)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrTokenReused):
+			app.logger.PrintInfo("refresh token reuse detected, session revoked", map[string]string{
+				"remote_addr": r.RemoteAddr,
+			})
+			app.errorResponse(w, r, http.StatusUnauthorized, "refresh token was already used, the session has been revoked")
+		case errors.Is(err, models.ErrRecordNotFound):
+			app.errorResponse(w, r, http.StatusUnauthorized, "invalid or expired refresh token")
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.writeSessionTokens(w, r, refreshToken.UserID, refreshToken)
+}
+
+// logoutHandler ends the session a refresh token belongs to. It succeeds for
+// unknown tokens too, so it can be retried safely.
+func (app *application) logoutHandler(w http.ResponseWriter, r *http.Request) {
+	var req RefreshTokenRequest
+
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		app.errorResponse(w, r, http.StatusBadRequest, "invalid JSON")
+		return
+	}
+
+	v := validator.New()
+	ValidateTokenPlaintext(v, "refresh_token", req.RefreshToken)
+
+	if !v.Valid() {
+		app.errorResponse(w, r, http.StatusUnprocessableEntity, v.Errors)
+		return
+	}
+
+	err = app.models.Tokens.RevokeFamily(req.RefreshToken, models.ScopeRefresh)
+	if err != nil && !errors.Is(err, models.ErrRecordNotFound) {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "session ended"}, nil)
+	if err != nil {
+		app.logError(r, err)
+		app.errorResponse(w, r, http.StatusInternalServerError, "failed to encode response")
+		return
+	}
+}
+
+// logoutAllHandler revokes every refresh token of the authenticated user.
+// Access tokens already issued stay valid until they expire.
+func (app *application) logoutAllHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.contextGetUser(r)
+
+	err := app.models.Tokens.RevokeAllForUser(user.ID, models.ScopeRefresh)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "all sessions ended"}, nil)
+	if err != nil {
+		app.logError(r, err)
+		app.errorResponse(w, r, http.StatusInternalServerError, "failed to encode response")
+		return
+	}
+}
+
+func (app *application) writeSessionTokens(w http.ResponseWriter, r *http.Request, userID int, refreshToken *models.Token) {
+	token, expiry, err := app.tokens.Issue(userID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	response := map[string]interface{}{
+		"authentication_token": AuthenticationToken{
+			Token:  token,
+			Type:   "Bearer",
+			Expiry: expiry,
+		},
+		"refresh_token": RefreshToken{
+			Token:  refreshToken.Plaintext,
+			Expiry: refreshToken.Expiry,
+		},
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"data": response}, nil)
+	if err != nil {
+		app.logError(r, err)
+		app.errorResponse(w, r, http.StatusInternalServerError, "failed to encode response")
+		return
+	}
+}
+
+func ValidateTokenPlaintext(v *validator.Validator, key, tokenPlaintext string) {
+	v.Check(tokenPlaintext != "", key, "must be provided")
+	v.Check(len(tokenPlaintext) == 52, key, "must be 52 bytes long")
+}
+
+// upgradePasswordHash replaces a legacy or outdated password hash after a
+// successful login. Failures are logged only: the login itself succeeded.
+func (app *application) upgradePasswordHash(r *http.Request, userID int, password string) {
+	hash, err := hashPassword(password)
+	if err == nil {
+		err = app.models.Users.UpdatePasswordHash(userID, hash)
+	}
+
+	if err != nil {
+		app.logError(r, fmt.Errorf("upgrading password hash of user %d: %w", userID, err))
+	}
+}
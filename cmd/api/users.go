@@ -1,9 +1,6 @@
 package main
 
 import (
-	"crypto/rand"
-	"crypto/sha256"
-	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"net/http"
@@ -11,6 +8,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/julienschmidt/httprouter"
 	models "github.com/madfelps/challenge-nuitee/internal/data"
 	"github.com/madfelps/challenge-nuitee/internal/validator"
 )
@@ -18,7 +16,8 @@ import (
 type User struct {
 	ID        int       `json:"id"`
 	Name      string    `json:"name"`
-	Email     string    `json:"email"`
+	Email     string    `json:"email,omitempty"`
+	Activated bool      `json:"activated"`
 	CreatedAt time.Time `json:"created_at"`
 }
 
@@ -32,6 +31,11 @@ type CreateUserResponse struct {
 	User User `json:"user"`
 }
 
+type UpdateUserRequest struct {
+	Name  *string `json:"name"`
+	Email *string `json:"email"`
+}
+
 func (app *application) createUserHandler(w http.ResponseWriter, r *http.Request) {
 	var req CreateUserRequest
 
@@ -51,7 +55,11 @@ func (app *application) createUserHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	passwordHash := hashPassword(req.Password)
+	passwordHash, err := hashPassword(req.Password)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
 
 	userID, err := app.models.Users.Insert(req.Name, req.Email, passwordHash)
 	if err != nil {
@@ -72,6 +80,14 @@ func (app *application) createUserHandler(w http.ResponseWriter, r *http.Request
 		CreatedAt: time.Now(),
 	}
 
+	// The account exists by now, so failing to send the activation email
+	// must not fail the request: the user can ask for a new one with
+	// POST /v1/tokens/activation.
+	err = app.sendActivationEmail(&models.User{ID: user.ID, Name: user.Name, Email: user.Email})
+	if err != nil {
+		app.logError(r, err)
+	}
+
 	response := CreateUserResponse{
 		User: user,
 	}
@@ -109,13 +125,18 @@ func (app *application) listUsersHandler(w http.ResponseWriter, r *http.Request)
 		}
 	}
 
-	users, total, err := app.models.Users.List(limit, offset)
+	records, total, err := app.models.Users.List(limit, offset)
 	if err != nil {
 		app.logError(r, err)
 		app.errorResponse(w, r, http.StatusInternalServerError, "database error")
 		return
 	}
 
+	users := make([]User, len(records))
+	for i, record := range records {
+		users[i] = userFromModel(record)
+	}
+
 	response := map[string]interface{}{
 		"users":  users,
 		"total":  total,
@@ -131,36 +152,174 @@ func (app *application) listUsersHandler(w http.ResponseWriter, r *http.Request)
 	}
 }
 
-func hashPassword(password string) string {
+func userFromModel(user models.User) User {
+	return User{
+		ID:        user.ID,
+		Name:      user.Name,
+		Email:     user.Email,
+		Activated: user.Activated,
+		CreatedAt: user.CreatedAt,
+	}
+}
 
-	salt := make([]byte, 16)
-	rand.Read(salt)
+func (app *application) showCurrentUserHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.contextGetUser(r)
 
-	passwordWithSalt := append([]byte(password), salt...)
+	roles, err := app.models.Roles.GetAllForUser(user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	permissions, err := app.models.Permissions.GetAllForUser(user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
 
-	hash := sha256.Sum256(passwordWithSalt)
+	response := map[string]interface{}{
+		"user":        userFromModel(*user),
+		"roles":       roles,
+		"permissions": append(models.Permissions{}, permissions...),
+	}
 
-	return hex.EncodeToString(salt) + ":" + hex.EncodeToString(hash[:])
+	err = app.writeJSON(w, http.StatusOK, envelope{"data": response}, nil)
+	if err != nil {
+		app.logError(r, err)
+		app.errorResponse(w, r, http.StatusInternalServerError, "failed to encode response")
+		return
+	}
 }
 
-func verifyPassword(password, storedHash string) bool {
+// readUserIDParam returns the :id route parameter. Routes using it are
+// guarded by requireOwner, which already rejected invalid values.
+func readUserIDParam(r *http.Request) int {
+	id, _ := strconv.Atoi(httprouter.ParamsFromContext(r.Context()).ByName("id"))
+	return id
+}
+
+func (app *application) showUserHandler(w http.ResponseWriter, r *http.Request) {
+	user, err := app.models.Users.Get(readUserIDParam(r))
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
 
-	parts := strings.Split(storedHash, ":")
-	if len(parts) != 2 {
-		return false
+	err = app.writeJSON(w, http.StatusOK, envelope{"data": map[string]interface{}{"user": userFromModel(*user)}}, nil)
+	if err != nil {
+		app.logError(r, err)
+		app.errorResponse(w, r, http.StatusInternalServerError, "failed to encode response")
+		return
 	}
+}
 
-	salt, err := hex.DecodeString(parts[0])
+// patchUserHandler changes the name and email of a user. A new email address
+// must be verified again before alerts are emailed to it.
+func (app *application) patchUserHandler(w http.ResponseWriter, r *http.Request) {
+	user, err := app.models.Users.Get(readUserIDParam(r))
 	if err != nil {
-		return false
+		switch {
+		case errors.Is(err, models.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
 	}
 
-	passwordWithSalt := append([]byte(password), salt...)
+	var req UpdateUserRequest
 
-	hash := sha256.Sum256(passwordWithSalt)
-	hashStr := hex.EncodeToString(hash[:])
+	err = json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		app.errorResponse(w, r, http.StatusBadRequest, "invalid JSON")
+		return
+	}
 
-	return hashStr == parts[1]
+	if req.Name != nil {
+		user.Name = strings.TrimSpace(*req.Name)
+	}
+
+	emailChanged := false
+	if req.Email != nil {
+		email := strings.TrimSpace(*req.Email)
+		emailChanged = !strings.EqualFold(email, user.Email)
+		user.Email = email
+	}
+
+	v := validator.New()
+	validateUser(v, &CreateUserRequest{Name: user.Name})
+	ValidateEmail(v, user.Email)
+
+	if !v.Valid() {
+		app.errorResponse(w, r, http.StatusUnprocessableEntity, v.Errors)
+		return
+	}
+
+	if emailChanged {
+		user.Activated = false
+	}
+
+	err = app.models.Users.Update(user)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrDuplicateEmail):
+			app.errorResponse(w, r, http.StatusConflict, "email already exists")
+		case errors.Is(err, models.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if emailChanged {
+		// Tokens sent to the previous address must not verify the new one.
+		err = app.models.Tokens.DeleteAllForUser(models.ScopeActivation, user.ID)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		// As on sign up, the change is saved and a new token can be
+		// requested with POST /v1/tokens/activation.
+		err = app.sendActivationEmail(user)
+		if err != nil {
+			app.logError(r, err)
+		}
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"data": map[string]interface{}{"user": userFromModel(*user)}}, nil)
+	if err != nil {
+		app.logError(r, err)
+		app.errorResponse(w, r, http.StatusInternalServerError, "failed to encode response")
+		return
+	}
+}
+
+// deleteUserHandler deletes a user together with all their data.
+func (app *application) deleteUserHandler(w http.ResponseWriter, r *http.Request) {
+	err := app.models.Users.Delete(readUserIDParam(r))
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "user successfully deleted"}, nil)
+	if err != nil {
+		app.logError(r, err)
+		app.errorResponse(w, r, http.StatusInternalServerError, "failed to encode response")
+		return
+	}
 }
 
 func validateUser(v *validator.Validator, user *CreateUserRequest) {
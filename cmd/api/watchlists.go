@@ -0,0 +1,366 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/julienschmidt/httprouter"
+	models "github.com/madfelps/challenge-nuitee/internal/data"
+	"github.com/madfelps/challenge-nuitee/internal/validator"
+)
+
+// Actions applied to every favorite of a watchlist at once.
+const (
+	watchlistActionPause    = "pause"
+	watchlistActionResume   = "resume"
+	watchlistActionCurrency = "change_currency"
+	watchlistActionMove     = "move"
+	watchlistActionDelete   = "delete"
+)
+
+var watchlistActions = []string{
+	watchlistActionPause,
+	watchlistActionResume,
+	watchlistActionCurrency,
+	watchlistActionMove,
+	watchlistActionDelete,
+}
+
+type WatchlistRequest struct {
+	Name string `json:"name"`
+}
+
+// WatchlistActionRequest describes a bulk action. Currency is required by
+// change_currency; WatchlistID is the destination of move, null to take the
+// favorites out of any watchlist.
+type WatchlistActionRequest struct {
+	Action      string `json:"action"`
+	Currency    string `json:"currency"`
+	WatchlistID *int   `json:"watchlist_id"`
+}
+
+func (app *application) createWatchlistHandler(w http.ResponseWriter, r *http.Request) {
+	var req WatchlistRequest
+
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		app.errorResponse(w, r, http.StatusBadRequest, "invalid JSON")
+		return
+	}
+
+	watchlist := &models.Watchlist{Name: strings.TrimSpace(req.Name)}
+
+	v := validator.New()
+	ValidateWatchlist(v, watchlist)
+
+	if !v.Valid() {
+		app.errorResponse(w, r, http.StatusUnprocessableEntity, v.Errors)
+		return
+	}
+
+	err = app.models.Watchlists.Insert(watchlist, app.favoriteOwner(r))
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrDuplicateWatchlist):
+			app.errorResponse(w, r, http.StatusConflict, "a watchlist with this name already exists")
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"data": map[string]interface{}{"watchlist": watchlist}}, nil)
+	if err != nil {
+		app.logError(r, err)
+		app.errorResponse(w, r, http.StatusInternalServerError, "failed to encode response")
+		return
+	}
+}
+
+func (app *application) listWatchlistsHandler(w http.ResponseWriter, r *http.Request) {
+	watchlists, err := app.models.Watchlists.List(app.favoriteOwner(r))
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"data": map[string]interface{}{"watchlists": watchlists}}, nil)
+	if err != nil {
+		app.logError(r, err)
+		app.errorResponse(w, r, http.StatusInternalServerError, "failed to encode response")
+		return
+	}
+}
+
+// showWatchlistHandler returns a watchlist with its favorites.
+func (app *application) showWatchlistHandler(w http.ResponseWriter, r *http.Request) {
+	watchlist, ok := app.readWatchlist(w, r)
+	if !ok {
+		return
+	}
+
+	records, err := app.models.Favorites.ListForWatchlist(watchlist.ID, app.favoriteOwner(r))
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	favorites := make([]HotelFavorite, len(records))
+	for i, record := range records {
+		favorites[i] = hotelFavoriteFromModel(record)
+	}
+
+	response := map[string]interface{}{
+		"watchlist": watchlist,
+		"favorites": favorites,
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"data": response}, nil)
+	if err != nil {
+		app.logError(r, err)
+		app.errorResponse(w, r, http.StatusInternalServerError, "failed to encode response")
+		return
+	}
+}
+
+func (app *application) updateWatchlistHandler(w http.ResponseWriter, r *http.Request) {
+	watchlist, ok := app.readWatchlist(w, r)
+	if !ok {
+		return
+	}
+
+	var req WatchlistRequest
+
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		app.errorResponse(w, r, http.StatusBadRequest, "invalid JSON")
+		return
+	}
+
+	watchlist.Name = strings.TrimSpace(req.Name)
+
+	v := validator.New()
+	ValidateWatchlist(v, watchlist)
+
+	if !v.Valid() {
+		app.errorResponse(w, r, http.StatusUnprocessableEntity, v.Errors)
+		return
+	}
+
+	err = app.models.Watchlists.Rename(watchlist)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrDuplicateWatchlist):
+			app.errorResponse(w, r, http.StatusConflict, "a watchlist with this name already exists")
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"data": map[string]interface{}{"watchlist": watchlist}}, nil)
+	if err != nil {
+		app.logError(r, err)
+		app.errorResponse(w, r, http.StatusInternalServerError, "failed to encode response")
+		return
+	}
+}
+
+// deleteWatchlistHandler deletes a watchlist but keeps its favorites, which
+// no longer belong to any watchlist. Use the delete action to remove them
+// first.
+func (app *application) deleteWatchlistHandler(w http.ResponseWriter, r *http.Request) {
+	watchlistID, ok := app.readWatchlistIDParam(w, r)
+	if !ok {
+		return
+	}
+
+	err := app.models.Watchlists.Delete(watchlistID, app.favoriteOwner(r))
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		case errors.Is(err, models.ErrDuplicateFavorite):
+			app.errorResponse(w, r, http.StatusConflict, "some hotels of this watchlist are also watched outside of any watchlist")
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "watchlist successfully deleted"}, nil)
+	if err != nil {
+		app.logError(r, err)
+		app.errorResponse(w, r, http.StatusInternalServerError, "failed to encode response")
+		return
+	}
+}
+
+// watchlistActionHandler applies a bulk action to every favorite of a
+// watchlist: pause or resume price monitoring, change the currency
+// (converting target prices), move them to another watchlist, or delete
+// them. Pausing and resuming skip favorites that do not allow it.
+func (app *application) watchlistActionHandler(w http.ResponseWriter, r *http.Request) {
+	watchlist, ok := app.readWatchlist(w, r)
+	if !ok {
+		return
+	}
+
+	owner := app.favoriteOwner(r)
+
+	var req WatchlistActionRequest
+
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		app.errorResponse(w, r, http.StatusBadRequest, "invalid JSON")
+		return
+	}
+
+	req.Currency = strings.ToUpper(strings.TrimSpace(req.Currency))
+
+	v := validator.New()
+	v.Check(slices.Contains(watchlistActions, req.Action), "action", "must be pause, resume, change_currency, move or delete")
+	if req.Action == watchlistActionCurrency {
+		app.validateFavoriteCurrency(v, req.Currency)
+	}
+
+	if !v.Valid() {
+		app.errorResponse(w, r, http.StatusUnprocessableEntity, v.Errors)
+		return
+	}
+
+	if req.Action == watchlistActionDelete {
+		deleted, err := app.models.Favorites.DeleteForWatchlist(watchlist.ID, owner)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		err = app.writeJSON(w, http.StatusOK, envelope{"data": map[string]interface{}{"deleted": deleted}}, nil)
+		if err != nil {
+			app.logError(r, err)
+			app.errorResponse(w, r, http.StatusInternalServerError, "failed to encode response")
+		}
+		return
+	}
+
+	if req.Action == watchlistActionMove && !app.checkWatchlist(w, r, req.WatchlistID, owner) {
+		return
+	}
+
+	records, err := app.models.Favorites.ListForWatchlist(watchlist.ID, owner)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	for i := range records {
+		switch req.Action {
+		case watchlistActionPause:
+			if records[i].CanPause() {
+				records[i].Status = models.FavoriteStatusPaused
+			}
+		case watchlistActionResume:
+			if records[i].CanResume() {
+				records[i].Status = models.FavoriteStatusActive
+			}
+		case watchlistActionMove:
+			records[i].WatchlistID = req.WatchlistID
+		case watchlistActionCurrency:
+			if records[i].Currency == req.Currency {
+				continue
+			}
+			if err := app.convertFavoriteCurrency(&records[i], req.Currency); err != nil {
+				app.convertPriceResponse(w, r, err)
+				return
+			}
+		}
+	}
+
+	err = app.models.Favorites.UpdateAll(records)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrDuplicateFavorite):
+			app.errorResponse(w, r, http.StatusConflict, "some favorites would duplicate identical watches")
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	favorites := make([]HotelFavorite, len(records))
+	for i, record := range records {
+		favorites[i] = hotelFavoriteFromModel(record)
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"data": map[string]interface{}{"favorites": favorites}}, nil)
+	if err != nil {
+		app.logError(r, err)
+		app.errorResponse(w, r, http.StatusInternalServerError, "failed to encode response")
+		return
+	}
+}
+
+func (app *application) readWatchlistIDParam(w http.ResponseWriter, r *http.Request) (int, bool) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	watchlistID, err := strconv.Atoi(params.ByName("watchlist_id"))
+	if err != nil || watchlistID <= 0 {
+		app.errorResponse(w, r, http.StatusBadRequest, "invalid watchlist_id parameter")
+		return 0, false
+	}
+
+	return watchlistID, true
+}
+
+// readWatchlist loads the watchlist named by the :watchlist_id URL
+// parameter. Watchlists of others are reported as not found.
+func (app *application) readWatchlist(w http.ResponseWriter, r *http.Request) (*models.Watchlist, bool) {
+	watchlistID, ok := app.readWatchlistIDParam(w, r)
+	if !ok {
+		return nil, false
+	}
+
+	watchlist, err := app.models.Watchlists.Get(watchlistID, app.favoriteOwner(r))
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return nil, false
+	}
+
+	return watchlist, true
+}
+
+// checkWatchlist verifies that a favorite of owner can be put in
+// watchlistID: nil, or one of owner's watchlists.
+func (app *application) checkWatchlist(w http.ResponseWriter, r *http.Request, watchlistID *int, owner models.FavoriteOwner) bool {
+	if watchlistID == nil {
+		return true
+	}
+
+	_, err := app.models.Watchlists.Get(*watchlistID, owner)
+	if err != nil {
+		switch {
+		case errors.Is(err, models.ErrRecordNotFound):
+			app.errorResponse(w, r, http.StatusUnprocessableEntity, map[string]string{"watchlist_id": "must be one of your watchlists"})
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return false
+	}
+
+	return true
+}
+
+func ValidateWatchlist(v *validator.Validator, watchlist *models.Watchlist) {
+	v.Check(watchlist.Name != "", "name", "must be provided")
+	v.Check(len(watchlist.Name) <= 100, "name", "must not be more than 100 characters long")
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/julienschmidt/httprouter"
+	models "github.com/madfelps/challenge-nuitee/internal/data"
+	"github.com/madfelps/challenge-nuitee/internal/validator"
+)
+
+func TestValidateWatchlist(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected bool
+	}{
+		{name: "Lisbon trip", expected: true},
+		{name: strings.Repeat("a", 100), expected: true},
+		{name: "", expected: false},
+		{name: strings.Repeat("a", 101), expected: false},
+	}
+
+	for _, tt := range tests {
+		v := validator.New()
+		ValidateWatchlist(v, &models.Watchlist{Name: tt.name})
+
+		if v.Valid() != tt.expected {
+			t.Errorf("ValidateWatchlist(%q) = %v, expected %v", tt.name, v.Valid(), tt.expected)
+		}
+	}
+}
+
+func TestReadWatchlistIDParam(t *testing.T) {
+	tests := []struct {
+		param    string
+		expected bool
+	}{
+		{param: "1", expected: true},
+		{param: "0", expected: false},
+		{param: "-3", expected: false},
+		{param: "abc", expected: false},
+	}
+
+	app := &application{}
+
+	for _, tt := range tests {
+		t.Run(tt.param, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/v1/me/watchlists/"+tt.param, nil)
+			ctx := context.WithValue(r.Context(), httprouter.ParamsKey, httprouter.Params{{Key: "watchlist_id", Value: tt.param}})
+			r = r.WithContext(ctx)
+			w := httptest.NewRecorder()
+
+			_, ok := app.readWatchlistIDParam(w, r)
+			if ok != tt.expected {
+				t.Errorf("readWatchlistIDParam(%q) = %v, expected %v", tt.param, ok, tt.expected)
+			}
+			if !ok && w.Code != http.StatusBadRequest {
+				t.Errorf("expected status %d, got %d", http.StatusBadRequest, w.Code)
+			}
+		})
+	}
+}
+
+func TestCheckWatchlistWithoutWatchlist(t *testing.T) {
+	app := &application{}
+	r := httptest.NewRequest(http.MethodPost, "/v1/me/favorites", nil)
+	w := httptest.NewRecorder()
+
+	if !app.checkWatchlist(w, r, nil, models.FavoriteOwner{}) {
+		t.Errorf("checkWatchlist(nil) = false, expected true")
+	}
+}
@@ -5,9 +5,17 @@ go 1.24.0
 toolchain go1.24.9
 
 require (
+	github.com/golang-jwt/jwt/v5 v5.3.1
 	github.com/julienschmidt/httprouter v1.3.0
 	github.com/lib/pq v1.10.9
+	github.com/redis/go-redis/v9 v9.22.0
+	golang.org/x/crypto v0.40.0
+	golang.org/x/sync v0.16.0
 	golang.org/x/time v0.14.0
 )
 
-require github.com/liteapi-travel/go-sdk/v3 v3.0.0-20240527122043-0d1a179b3b9c
+require (
+	github.com/cespare/xxhash/v2 v2.3.0 // indirect
+	go.uber.org/atomic v1.11.0 // indirect
+	golang.org/x/sys v0.34.0 // indirect
+)
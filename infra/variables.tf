@@ -46,9 +46,21 @@ variable "lite_api_url" {
   default     = "https://api.liteapi.travel/v3.0"
 }
 
+variable "trusted_proxies" {
+  description = "Comma-separated CIDRs of the ingress proxies whose X-Forwarded-For header the API trusts"
+  type        = string
+  default     = ""
+}
+
 variable "lite_api_key" {
   description = "LiteAPI key"
   type        = string
   sensitive   = true
 }
 
+variable "jwt_signing_key" {
+  description = "Access token signing key (at least 32 bytes)"
+  type        = string
+  sensitive   = true
+}
+
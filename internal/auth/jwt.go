@@ -0,0 +1,118 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	ErrInvalidToken = errors.New("invalid token")
+	ErrExpiredToken = errors.New("expired token")
+)
+
+const (
+	AlgorithmHS256 = "HS256"
+	AlgorithmEdDSA = "EdDSA"
+)
+
+// Issuer signs and verifies stateless access tokens. The subject claim holds
+// the user ID.
+type Issuer struct {
+	method    jwt.SigningMethod
+	signKey   interface{}
+	verifyKey interface{}
+	issuer    string
+	ttl       time.Duration
+	now       func() time.Time
+}
+
+// NewIssuer builds an Issuer for algorithm. For HS256 key is the shared
+// secret and must be at least 32 bytes; for EdDSA it is the base64 encoding
+// of a 32 byte Ed25519 seed.
+func NewIssuer(algorithm, key, issuer string, ttl time.Duration) (*Issuer, error) {
+	i := &Issuer{
+		issuer: issuer,
+		ttl:    ttl,
+		now:    time.Now,
+	}
+
+	switch algorithm {
+	case AlgorithmHS256:
+		if len(key) < 32 {
+			return nil, errors.New("HS256 signing key must be at least 32 bytes")
+		}
+		i.method = jwt.SigningMethodHS256
+		i.signKey = []byte(key)
+		i.verifyKey = []byte(key)
+	case AlgorithmEdDSA:
+		seed, err := base64.StdEncoding.DecodeString(key)
+		if err != nil || len(seed) != ed25519.SeedSize {
+			return nil, errors.New("EdDSA signing key must be a base64-encoded 32 byte seed")
+		}
+		privateKey := ed25519.NewKeyFromSeed(seed)
+		i.method = jwt.SigningMethodEdDSA
+		i.signKey = privateKey
+		i.verifyKey = privateKey.Public()
+	default:
+		return nil, fmt.Errorf("unsupported signing algorithm %q", algorithm)
+	}
+
+	return i, nil
+}
+
+// Issue returns a signed access token for userID and its expiry.
+func (i *Issuer) Issue(userID int) (string, time.Time, error) {
+	now := i.now()
+	expiry := now.Add(i.ttl)
+
+	claims := jwt.RegisteredClaims{
+		Subject:   strconv.Itoa(userID),
+		Issuer:    i.issuer,
+		Audience:  jwt.ClaimStrings{i.issuer},
+		IssuedAt:  jwt.NewNumericDate(now),
+		NotBefore: jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(expiry),
+	}
+
+	token, err := jwt.NewWithClaims(i.method, claims).SignedString(i.signKey)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	return token, expiry, nil
+}
+
+// Verify checks the signature, algorithm, issuer, audience and validity
+// window of token and returns the user ID it was issued for.
+func (i *Issuer) Verify(token string) (int, error) {
+	var claims jwt.RegisteredClaims
+
+	_, err := jwt.ParseWithClaims(token, &claims, func(*jwt.Token) (interface{}, error) {
+		return i.verifyKey, nil
+	},
+		jwt.WithValidMethods([]string{i.method.Alg()}),
+		jwt.WithIssuer(i.issuer),
+		jwt.WithAudience(i.issuer),
+		jwt.WithExpirationRequired(),
+		jwt.WithTimeFunc(i.now),
+	)
+	if err != nil {
+		if errors.Is(err, jwt.ErrTokenExpired) {
+			return 0, ErrExpiredToken
+		}
+		return 0, ErrInvalidToken
+	}
+
+	userID, err := strconv.Atoi(claims.Subject)
+	if err != nil || userID <= 0 {
+		return 0, ErrInvalidToken
+	}
+
+	return userID, nil
+}
@@ -0,0 +1,84 @@
+package auth
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIssuerRoundTrip(t *testing.T) {
+	hsKey := strings.Repeat("k", 32)
+	edKey := base64.StdEncoding.EncodeToString([]byte(strings.Repeat("s", 32)))
+
+	tests := []struct {
+		algorithm string
+		key       string
+	}{
+		{AlgorithmHS256, hsKey},
+		{AlgorithmEdDSA, edKey},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.algorithm, func(t *testing.T) {
+			issuer, err := NewIssuer(tt.algorithm, tt.key, "test", 15*time.Minute)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			token, expiry, err := issuer.Issue(42)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if d := time.Until(expiry); d <= 14*time.Minute || d > 15*time.Minute {
+				t.Errorf("expiry in %v, expected 15m", d)
+			}
+
+			userID, err := issuer.Verify(token)
+			if err != nil || userID != 42 {
+				t.Errorf("Verify() = %d, %v, expected 42", userID, err)
+			}
+
+			if _, err := issuer.Verify(token + "x"); !errors.Is(err, ErrInvalidToken) {
+				t.Errorf("tampered token: err = %v, expected ErrInvalidToken", err)
+			}
+
+			issuer.now = func() time.Time { return time.Now().Add(time.Hour) }
+			if _, err := issuer.Verify(token); !errors.Is(err, ErrExpiredToken) {
+				t.Errorf("expired token: err = %v, expected ErrExpiredToken", err)
+			}
+		})
+	}
+}
+
+func TestIssuerRejectsForeignTokens(t *testing.T) {
+	issuer, _ := NewIssuer(AlgorithmHS256, strings.Repeat("a", 32), "test", time.Minute)
+	otherKey, _ := NewIssuer(AlgorithmHS256, strings.Repeat("b", 32), "test", time.Minute)
+	otherIssuer, _ := NewIssuer(AlgorithmHS256, strings.Repeat("a", 32), "other", time.Minute)
+
+	for name, foreign := range map[string]*Issuer{"other key": otherKey, "other issuer": otherIssuer} {
+		token, _, _ := foreign.Issue(1)
+		if _, err := issuer.Verify(token); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("%s: err = %v, expected ErrInvalidToken", name, err)
+		}
+	}
+
+	// A token signed with "none" must never be accepted.
+	none := "eyJhbGciOiJub25lIiwidHlwIjoiSldUIn0.eyJzdWIiOiIxIiwiaXNzIjoidGVzdCJ9."
+	if _, err := issuer.Verify(none); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("alg none: err = %v, expected ErrInvalidToken", err)
+	}
+}
+
+func TestNewIssuerRejectsWeakKeys(t *testing.T) {
+	if _, err := NewIssuer(AlgorithmHS256, "short", "test", time.Minute); err == nil {
+		t.Error("expected error for a short HS256 key")
+	}
+	if _, err := NewIssuer(AlgorithmEdDSA, "not base64!", "test", time.Minute); err == nil {
+		t.Error("expected error for an invalid EdDSA seed")
+	}
+	if _, err := NewIssuer("RS256", strings.Repeat("k", 32), "test", time.Minute); err == nil {
+		t.Error("expected error for an unsupported algorithm")
+	}
+}
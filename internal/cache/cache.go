@@ -0,0 +1,91 @@
+package cache
+
+import (
+	"context"
+	"expvar"
+	"time"
+
+	"github.com/madfelps/challenge-nuitee/internal/upstream"
+	"golang.org/x/sync/singleflight"
+)
+
+// Store is a byte-oriented key/value backend with per-entry expiry.
+type Store interface {
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+}
+
+// Cache puts a Store in front of an expensive loader. Concurrent misses for
+// the same key are coalesced into a single load. Hits, misses, coalesced
+// calls and store errors are counted per namespace in stats.
+type Cache struct {
+	store Store
+	group singleflight.Group
+	stats *expvar.Map
+}
+
+func New(store Store, stats *expvar.Map) *Cache {
+	return &Cache{
+		store: store,
+		stats: stats,
+	}
+}
+
+// Fetch returns the value cached under namespace and key, calling load on a
+// miss and caching its result for ttl. Errors from load are not cached. A
+// non-positive ttl bypasses the cache entirely.
+//
+// A load shared by concurrent misses runs on a context detached from the
+// caller that started it, so one caller giving up does not fail the others;
+// load must bound it itself. Each caller stops waiting when its own ctx is
+// done. Loads are only shared between callers of the same upstream priority,
+// so a background caller never slows down an interactive one.
+func (c *Cache) Fetch(ctx context.Context, namespace, key string, ttl time.Duration, load func(ctx context.Context) ([]byte, error)) ([]byte, error) {
+	if ttl <= 0 {
+		return load(ctx)
+	}
+
+	fullKey := namespace + ":" + key
+
+	value, found, err := c.store.Get(ctx, fullKey)
+	switch {
+	case err != nil:
+		c.stats.Add(namespace+".errors", 1)
+	case found:
+		c.stats.Add(namespace+".hits", 1)
+		return value, nil
+	}
+
+	c.stats.Add(namespace+".misses", 1)
+
+	loadCtx := context.WithoutCancel(ctx)
+	flight := fullKey + "@" + upstream.PriorityFrom(ctx).String()
+
+	ch := c.group.DoChan(flight, func() (interface{}, error) {
+		value, err := load(loadCtx)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := c.store.Set(loadCtx, fullKey, value, ttl); err != nil {
+			c.stats.Add(namespace+".errors", 1)
+		}
+
+		return value, nil
+	})
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case res := <-ch:
+		if res.Shared {
+			c.stats.Add(namespace+".coalesced", 1)
+		}
+
+		if res.Err != nil {
+			return nil, res.Err
+		}
+
+		return res.Val.([]byte), nil
+	}
+}
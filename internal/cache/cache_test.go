@@ -0,0 +1,199 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/madfelps/challenge-nuitee/internal/upstream"
+)
+
+func TestMemoryStoreExpiryAndEviction(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+
+	s := NewMemoryStore(2)
+	s.now = func() time.Time { return now }
+
+	s.Set(ctx, "a", []byte("1"), time.Minute)
+	s.Set(ctx, "b", []byte("2"), time.Hour)
+
+	// Touch a so b becomes the least recently used entry.
+	if _, found, _ := s.Get(ctx, "a"); !found {
+		t.Fatal("a not found")
+	}
+
+	s.Set(ctx, "c", []byte("3"), time.Hour)
+
+	if _, found, _ := s.Get(ctx, "b"); found {
+		t.Error("least recently used entry was not evicted")
+	}
+	if s.Len() != 2 {
+		t.Errorf("Len() = %d, expected 2", s.Len())
+	}
+
+	now = now.Add(2 * time.Minute)
+
+	if _, found, _ := s.Get(ctx, "a"); found {
+		t.Error("expired entry returned")
+	}
+	if value, found, _ := s.Get(ctx, "c"); !found || string(value) != "3" {
+		t.Errorf("Get(c) = %q, %v, expected \"3\", true", value, found)
+	}
+}
+
+func TestCacheFetch(t *testing.T) {
+	ctx := context.Background()
+	stats := new(expvar.Map).Init()
+	c := New(NewMemoryStore(10), stats)
+
+	var loads int32
+	load := func(context.Context) ([]byte, error) {
+		atomic.AddInt32(&loads, 1)
+		return []byte("value"), nil
+	}
+
+	for i := 0; i < 3; i++ {
+		value, err := c.Fetch(ctx, "ns", "key", time.Minute, load)
+		if err != nil || string(value) != "value" {
+			t.Fatalf("Fetch() = %q, %v", value, err)
+		}
+	}
+
+	if loads != 1 {
+		t.Errorf("loads = %d, expected 1", loads)
+	}
+	if hits := stats.Get("ns.hits").String(); hits != "2" {
+		t.Errorf("hits = %s, expected 2", hits)
+	}
+	if misses := stats.Get("ns.misses").String(); misses != "1" {
+		t.Errorf("misses = %s, expected 1", misses)
+	}
+
+	failing := func(context.Context) ([]byte, error) {
+		atomic.AddInt32(&loads, 1)
+		return nil, errors.New("upstream down")
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := c.Fetch(ctx, "ns", "other", time.Minute, failing); err == nil {
+			t.Fatal("expected error from failing loader")
+		}
+	}
+	if loads != 3 {
+		t.Errorf("loads = %d, expected errors not to be cached", loads)
+	}
+
+	c.Fetch(ctx, "ns", "key", 0, load)
+	if loads != 4 {
+		t.Errorf("loads = %d, expected a zero TTL to bypass the cache", loads)
+	}
+}
+
+func TestCacheFetchCoalescesConcurrentMisses(t *testing.T) {
+	ctx := context.Background()
+	c := New(NewMemoryStore(10), new(expvar.Map).Init())
+
+	var loads int32
+	release := make(chan struct{})
+
+	load := func(context.Context) ([]byte, error) {
+		atomic.AddInt32(&loads, 1)
+		<-release
+		return []byte("value"), nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.Fetch(ctx, "ns", "key", time.Minute, load)
+		}()
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if loads != 1 {
+		t.Errorf("loads = %d, expected concurrent misses to share one load", loads)
+	}
+}
+
+func TestCacheFetchSurvivesLeaderCancellation(t *testing.T) {
+	c := New(NewMemoryStore(10), new(expvar.Map).Init())
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	load := func(ctx context.Context) ([]byte, error) {
+		close(started)
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-release:
+			return []byte("value"), nil
+		}
+	}
+
+	leaderCtx, cancel := context.WithCancel(context.Background())
+	leaderErr := make(chan error, 1)
+	go func() {
+		_, err := c.Fetch(leaderCtx, "ns", "key", time.Minute, load)
+		leaderErr <- err
+	}()
+	<-started
+
+	followerValue := make(chan []byte, 1)
+	go func() {
+		value, _ := c.Fetch(context.Background(), "ns", "key", time.Minute, load)
+		followerValue <- value
+	}()
+
+	cancel()
+	if err := <-leaderErr; !errors.Is(err, context.Canceled) {
+		t.Errorf("leader err = %v, expected context.Canceled", err)
+	}
+
+	close(release)
+	if value := <-followerValue; string(value) != "value" {
+		t.Errorf("follower got %q, expected the shared load to outlive the leader", value)
+	}
+}
+
+func TestCacheFetchDoesNotShareAcrossPriorities(t *testing.T) {
+	c := New(NewMemoryStore(10), new(expvar.Map).Init())
+
+	var loads int32
+	release := make(chan struct{})
+
+	load := func(context.Context) ([]byte, error) {
+		atomic.AddInt32(&loads, 1)
+		<-release
+		return []byte("value"), nil
+	}
+
+	background := upstream.WithPriority(context.Background(), upstream.PriorityBackground)
+
+	var wg sync.WaitGroup
+	for _, ctx := range []context.Context{background, context.Background()} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.Fetch(ctx, "ns", "key", time.Minute, load)
+		}()
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if loads != 2 {
+		t.Errorf("loads = %d, expected an interactive miss not to wait on a background load", loads)
+	}
+}
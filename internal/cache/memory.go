@@ -0,0 +1,90 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryStore is an in-process LRU store holding at most maxEntries values.
+type MemoryStore struct {
+	maxEntries int
+
+	mu    sync.Mutex
+	ll    *list.List
+	items map[string]*list.Element
+	now   func() time.Time
+}
+
+type entry struct {
+	key     string
+	value   []byte
+	expires time.Time
+}
+
+func NewMemoryStore(maxEntries int) *MemoryStore {
+	return &MemoryStore{
+		maxEntries: maxEntries,
+		ll:         list.New(),
+		items:      make(map[string]*list.Element),
+		now:        time.Now,
+	}
+}
+
+func (s *MemoryStore) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	el, ok := s.items[key]
+	if !ok {
+		return nil, false, nil
+	}
+
+	e := el.Value.(*entry)
+	if !s.now().Before(e.expires) {
+		s.remove(el)
+		return nil, false, nil
+	}
+
+	s.ll.MoveToFront(el)
+
+	return e.value, true, nil
+}
+
+func (s *MemoryStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	expires := s.now().Add(ttl)
+
+	if el, ok := s.items[key]; ok {
+		e := el.Value.(*entry)
+		e.value = value
+		e.expires = expires
+		s.ll.MoveToFront(el)
+		return nil
+	}
+
+	s.items[key] = s.ll.PushFront(&entry{key: key, value: value, expires: expires})
+
+	for s.maxEntries > 0 && s.ll.Len() > s.maxEntries {
+		s.remove(s.ll.Back())
+	}
+
+	return nil
+}
+
+// Len returns the number of entries, expired ones included until they are
+// evicted or looked up.
+func (s *MemoryStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.ll.Len()
+}
+
+func (s *MemoryStore) remove(el *list.Element) {
+	s.ll.Remove(el)
+	delete(s.items, el.Value.(*entry).key)
+}
@@ -0,0 +1,261 @@
+package models
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base32"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	// APIKeyPrefix starts every personal API key, so they can be told apart
+	// from access tokens in the Authorization header.
+	APIKeyPrefix = "hpm_"
+
+	APIKeyScopeRead  = "read"
+	APIKeyScopeWrite = "write"
+)
+
+// APIKeyScopes lists the scopes a personal API key can be given. Keys with
+// the read scope only can make GET and HEAD requests.
+var APIKeyScopes = []string{APIKeyScopeRead, APIKeyScopeWrite}
+
+var apiKeyEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// APIKey is a named, long-lived credential a user creates for scripts. Keys
+// have the form hpm_<prefix>_<secret>: the prefix is stored in clear to look
+// the key up, while only the SHA-256 hash of the whole key is stored.
+// Plaintext is set only on keys created in this process.
+type APIKey struct {
+	ID         int        `json:"id"`
+	UserID     int        `json:"-"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Plaintext  string     `json:"key,omitempty"`
+	Hash       []byte     `json:"-"`
+	Scopes     []string   `json:"scopes"`
+	Expiry     *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// Allows reports whether the key may make a request with method.
+func (k *APIKey) Allows(method string) bool {
+	for _, scope := range k.Scopes {
+		if scope == APIKeyScopeWrite {
+			return true
+		}
+	}
+
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		for _, scope := range k.Scopes {
+			if scope == APIKeyScopeRead {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func generateAPIKey() (prefix, plaintext string, err error) {
+	randomBytes := make([]byte, 25)
+	if _, err := rand.Read(randomBytes); err != nil {
+		return "", "", err
+	}
+
+	prefix = strings.ToLower(apiKeyEncoding.EncodeToString(randomBytes[:5]))
+	secret := strings.ToLower(apiKeyEncoding.EncodeToString(randomBytes[5:]))
+
+	return prefix, APIKeyPrefix + prefix + "_" + secret, nil
+}
+
+// parseAPIKey returns the lookup prefix of plaintext, or false when it does
+// not have the shape of a personal API key.
+func parseAPIKey(plaintext string) (string, bool) {
+	rest, found := strings.CutPrefix(plaintext, APIKeyPrefix)
+	if !found {
+		return "", false
+	}
+
+	prefix, secret, found := strings.Cut(rest, "_")
+	if !found || len(prefix) != 8 || len(secret) != 32 {
+		return "", false
+	}
+
+	return prefix, true
+}
+
+type APIKeyModel struct {
+	DB *sql.DB
+}
+
+// New creates and stores a key for userID. A nil expiry never expires.
+func (m APIKeyModel) New(userID int, name string, scopes []string, expiry *time.Time) (*APIKey, error) {
+	prefix, plaintext, err := generateAPIKey()
+	if err != nil {
+		return nil, err
+	}
+
+	key := &APIKey{
+		UserID:    userID,
+		Name:      name,
+		Prefix:    prefix,
+		Plaintext: plaintext,
+		Hash:      HashToken(plaintext),
+		Scopes:    scopes,
+		Expiry:    expiry,
+	}
+
+	query := `
+		INSERT INTO api_keys (user_id, name, prefix, hash, scopes, expiry)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at`
+
+	args := []interface{}{key.UserID, key.Name, key.Prefix, key.Hash, pq.Array(key.Scopes), key.Expiry}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err = m.DB.QueryRowContext(ctx, query, args...).Scan(&key.ID, &key.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return key, nil
+}
+
+// GetForPlaintext returns the unexpired key matching plaintext, or
+// ErrRecordNotFound.
+func (m APIKeyModel) GetForPlaintext(plaintext string) (*APIKey, error) {
+	prefix, ok := parseAPIKey(plaintext)
+	if !ok {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+		SELECT id, user_id, name, prefix, hash, scopes, expiry, last_used_at, created_at
+		FROM api_keys
+		WHERE prefix = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var key APIKey
+
+	err := m.DB.QueryRowContext(ctx, query, prefix).Scan(
+		&key.ID,
+		&key.UserID,
+		&key.Name,
+		&key.Prefix,
+		&key.Hash,
+		pq.Array(&key.Scopes),
+		&key.Expiry,
+		&key.LastUsedAt,
+		&key.CreatedAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	if subtle.ConstantTimeCompare(key.Hash, HashToken(plaintext)) != 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	if key.Expiry != nil && !key.Expiry.After(time.Now()) {
+		return nil, ErrRecordNotFound
+	}
+
+	return &key, nil
+}
+
+func (m APIKeyModel) ListForUser(userID int) ([]APIKey, error) {
+	query := `
+		SELECT id, user_id, name, prefix, scopes, expiry, last_used_at, created_at
+		FROM api_keys
+		WHERE user_id = $1
+		ORDER BY created_at DESC, id DESC`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []APIKey{}
+	for rows.Next() {
+		var key APIKey
+		err := rows.Scan(&key.ID, &key.UserID, &key.Name, &key.Prefix, pq.Array(&key.Scopes), &key.Expiry, &key.LastUsedAt, &key.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, rows.Err()
+}
+
+// DeleteForUser revokes key id when it belongs to userID, and returns
+// ErrRecordNotFound otherwise.
+func (m APIKeyModel) DeleteForUser(id, userID int) error {
+	query := `DELETE FROM api_keys WHERE id = $1 AND user_id = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, id, userID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// DeleteAllForUser revokes every key of userID.
+func (m APIKeyModel) DeleteAllForUser(userID int) error {
+	query := `DELETE FROM api_keys WHERE user_id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, userID)
+	return err
+}
+
+// Touch records that key id was just used. It writes at most once a minute
+// per key, so busy scripts do not cause a write per request.
+func (m APIKeyModel) Touch(id int) error {
+	query := `
+		UPDATE api_keys SET last_used_at = NOW()
+		WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < NOW() - INTERVAL '1 minute')`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, id)
+	return err
+}
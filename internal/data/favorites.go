@@ -3,41 +3,243 @@ package models
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"fmt"
 	"time"
+
+	"github.com/lib/pq"
 )
 
+// WatchCriteria narrows which offers can trigger a price alert for a
+// favorite. The zero value accepts any offer.
+type WatchCriteria struct {
+	RefundableOnly bool     `json:"refundable_only"`
+	BoardTypes     []string `json:"board_types"`
+	MinCapacity    int      `json:"min_capacity"`
+	MaxTaxes       *float64 `json:"max_taxes"`
+}
+
+func (c WatchCriteria) IsZero() bool {
+	return !c.RefundableOnly && len(c.BoardTypes) == 0 && c.MinCapacity == 0 && c.MaxTaxes == nil
+}
+
+// Stay is the trip a favorite is priced for. Favorites without dates are
+// priced for the default stay of the monitor.
+type Stay struct {
+	CheckIn  *time.Time `json:"check_in"`
+	CheckOut *time.Time `json:"check_out"`
+	Adults   int        `json:"adults"`
+	Children []int64    `json:"children"`
+}
+
+// Favorite statuses. The price monitor only checks active favorites. It
+// marks favorites fulfilled once their target price is reached, and expired
+// once their check-in date or end date has passed.
+const (
+	FavoriteStatusActive    = "active"
+	FavoriteStatusPaused    = "paused"
+	FavoriteStatusExpired   = "expired"
+	FavoriteStatusFulfilled = "fulfilled"
+)
+
+var ErrDuplicateFavorite = errors.New("duplicate favorite")
+
+// Favorite is a hotel watched for a target price. The same hotel can be
+// watched several times for different stays or currencies. Favorites with an
+// OrganizationID are shared by the members of that organization, and UserID
+// is the member who created them.
 type Favorite struct {
-	ID          int       `json:"id"`
-	UserID      int       `json:"user_id"`
-	HotelID     string    `json:"hotel_id"`
-	TargetPrice float64   `json:"target_price"`
-	CreatedAt   time.Time `json:"created_at"`
+	ID             int           `json:"id"`
+	UserID         int           `json:"user_id"`
+	OrganizationID *int          `json:"organization_id,omitempty"`
+	WatchlistID    *int          `json:"watchlist_id"`
+	Status         string        `json:"status"`
+	HotelID        string        `json:"hotel_id"`
+	Stay           Stay          `json:"stay"`
+	EndDate        *time.Time    `json:"end_date"`
+	TargetPrice    float64       `json:"target_price"`
+	Currency       string        `json:"currency"`
+	Criteria       WatchCriteria `json:"criteria"`
+	CreatedAt      time.Time     `json:"created_at"`
+}
+
+// CanPause reports whether monitoring of the favorite can be paused.
+func (f Favorite) CanPause() bool {
+	return f.Status == FavoriteStatusActive || f.Status == FavoriteStatusPaused
+}
+
+// CanResume reports whether monitoring of the favorite can be resumed.
+// Expired favorites cannot: their stay or end date has passed.
+func (f Favorite) CanResume() bool {
+	return f.Status != FavoriteStatusExpired
+}
+
+// FavoriteOwner selects whose favorites a query works on: those of an
+// organization when OrganizationID is set, otherwise the personal favorites
+// of UserID.
+type FavoriteOwner struct {
+	UserID         int
+	OrganizationID *int
+}
+
+// condition returns the SQL condition matching the owner's favorites, using
+// placeholder $n, and its argument.
+func (o FavoriteOwner) condition(n int) (string, interface{}) {
+	if o.OrganizationID != nil {
+		return fmt.Sprintf("organization_id = $%d", n), *o.OrganizationID
+	}
+	return fmt.Sprintf("user_id = $%d AND organization_id IS NULL", n), o.UserID
 }
 
 type FavoriteModel struct {
 	DB *sql.DB
 }
 
-func (m FavoriteModel) ListAllFavorites() ([]Favorite, error) {
+func (m FavoriteModel) Insert(favorite *Favorite) error {
 	query := `
-		SELECT id, user_id, hotel_id, target_price, created_at
-		FROM users_favorites
-		ORDER BY created_at DESC
-	`
+		INSERT INTO users_favorites (user_id, organization_id, watchlist_id, status, hotel_id, check_in, check_out, adults, children, end_date, target_price, currency, refundable_only, board_types, min_capacity, max_taxes)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)
+		RETURNING id, created_at`
+
+	if favorite.Criteria.BoardTypes == nil {
+		favorite.Criteria.BoardTypes = []string{}
+	}
+	if favorite.Stay.Adults == 0 {
+		favorite.Stay.Adults = 1
+	}
+	if favorite.Stay.Children == nil {
+		favorite.Stay.Children = []int64{}
+	}
+	if favorite.Status == "" {
+		favorite.Status = FavoriteStatusActive
+	}
+
+	args := []interface{}{
+		favorite.UserID,
+		favorite.OrganizationID,
+		favorite.WatchlistID,
+		favorite.Status,
+		favorite.HotelID,
+		favorite.Stay.CheckIn,
+		favorite.Stay.CheckOut,
+		favorite.Stay.Adults,
+		pq.Array(favorite.Stay.Children),
+		favorite.EndDate,
+		favorite.TargetPrice,
+		favorite.Currency,
+		favorite.Criteria.RefundableOnly,
+		pq.Array(favorite.Criteria.BoardTypes),
+		favorite.Criteria.MinCapacity,
+		favorite.Criteria.MaxTaxes,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&favorite.ID, &favorite.CreatedAt)
+	return favoriteError(err)
+}
+
+const favoriteColumns = `
+	SELECT id, user_id, organization_id, watchlist_id, status, hotel_id, check_in, check_out, adults, children, end_date, target_price, currency, refundable_only, board_types, min_capacity, max_taxes, created_at
+	FROM users_favorites`
+
+// ListAllFavorites returns the active favorites of every owner, for the
+// price monitor.
+func (m FavoriteModel) ListAllFavorites() ([]Favorite, error) {
+	return m.list(favoriteColumns+` WHERE status = $1 ORDER BY created_at DESC`, FavoriteStatusActive)
+}
+
+// ListForUser returns the personal favorites of userID.
+func (m FavoriteModel) ListForUser(userID int) ([]Favorite, error) {
+	return m.List(FavoriteOwner{UserID: userID})
+}
+
+func (m FavoriteModel) List(owner FavoriteOwner) ([]Favorite, error) {
+	condition, arg := owner.condition(1)
+	return m.list(favoriteColumns+` WHERE `+condition+` ORDER BY created_at DESC`, arg)
+}
+
+// ListForWatchlist returns the favorites of owner in watchlistID.
+func (m FavoriteModel) ListForWatchlist(watchlistID int, owner FavoriteOwner) ([]Favorite, error) {
+	condition, arg := owner.condition(2)
+	return m.list(favoriteColumns+` WHERE watchlist_id = $1 AND `+condition+` ORDER BY created_at DESC`, watchlistID, arg)
+}
+
+// Get returns a favorite only if it belongs to owner, so favorites of
+// others are indistinguishable from missing ones.
+func (m FavoriteModel) Get(id int, owner FavoriteOwner) (*Favorite, error) {
+	condition, arg := owner.condition(2)
+
+	favorites, err := m.list(favoriteColumns+` WHERE id = $1 AND `+condition, id, arg)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(favorites) == 0 {
+		return nil, ErrRecordNotFound
+	}
+
+	return &favorites[0], nil
+}
+
+func (m FavoriteModel) Delete(id int, owner FavoriteOwner) error {
+	condition, arg := owner.condition(2)
+	query := `DELETE FROM users_favorites WHERE id = $1 AND ` + condition
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, id, arg)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
 
+func (m FavoriteModel) list(query string, args ...interface{}) ([]Favorite, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	rows, err := m.DB.QueryContext(ctx, query)
+	rows, err := m.DB.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var favorites []Favorite
+	favorites := []Favorite{}
 	for rows.Next() {
 		var f Favorite
-		err := rows.Scan(&f.ID, &f.UserID, &f.HotelID, &f.TargetPrice, &f.CreatedAt)
+		err := rows.Scan(
+			&f.ID,
+			&f.UserID,
+			&f.OrganizationID,
+			&f.WatchlistID,
+			&f.Status,
+			&f.HotelID,
+			&f.Stay.CheckIn,
+			&f.Stay.CheckOut,
+			&f.Stay.Adults,
+			pq.Array(&f.Stay.Children),
+			&f.EndDate,
+			&f.TargetPrice,
+			&f.Currency,
+			&f.Criteria.RefundableOnly,
+			pq.Array(&f.Criteria.BoardTypes),
+			&f.Criteria.MinCapacity,
+			&f.Criteria.MaxTaxes,
+			&f.CreatedAt,
+		)
 		if err != nil {
 			return nil, err
 		}
@@ -50,3 +252,120 @@ func (m FavoriteModel) ListAllFavorites() ([]Favorite, error) {
 
 	return favorites, nil
 }
+
+// Update saves the watchlist, status, target price, currency and max taxes
+// of favorite. It returns ErrDuplicateFavorite when an identical watch already
+// exists in the destination watchlist.
+func (m FavoriteModel) Update(favorite *Favorite) error {
+	query := `
+		UPDATE users_favorites
+		SET watchlist_id = $1, status = $2, target_price = $3, currency = $4, max_taxes = $5
+		WHERE id = $6`
+
+	args := []interface{}{
+		favorite.WatchlistID,
+		favorite.Status,
+		favorite.TargetPrice,
+		favorite.Currency,
+		favorite.Criteria.MaxTaxes,
+		favorite.ID,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, args...)
+	return favoriteError(err)
+}
+
+// UpdateAll saves several favorites at once with Update, all or none.
+func (m FavoriteModel) UpdateAll(favorites []Favorite) error {
+	query := `
+		UPDATE users_favorites
+		SET watchlist_id = $1, status = $2, target_price = $3, currency = $4, max_taxes = $5
+		WHERE id = $6`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, f := range favorites {
+		_, err := tx.ExecContext(ctx, query, f.WatchlistID, f.Status, f.TargetPrice, f.Currency, f.Criteria.MaxTaxes, f.ID)
+		if err != nil {
+			return favoriteError(err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// UpdateStatus sets the status of the favorite id, whatever its owner.
+func (m FavoriteModel) UpdateStatus(id int, status string) error {
+	query := `UPDATE users_favorites SET status = $1 WHERE id = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, status, id)
+	return err
+}
+
+// MarkFulfilled marks the favorite id fulfilled, unless it was paused
+// meanwhile.
+func (m FavoriteModel) MarkFulfilled(id int) error {
+	query := `UPDATE users_favorites SET status = $1 WHERE id = $2 AND status = $3`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, FavoriteStatusFulfilled, id, FavoriteStatusActive)
+	return err
+}
+
+// ExpireDue marks expired every favorite not yet expired or fulfilled whose
+// check-in date or end date is before today, and returns how many were.
+func (m FavoriteModel) ExpireDue(today time.Time) (int64, error) {
+	query := `
+		UPDATE users_favorites SET status = $1
+		WHERE status IN ($2, $3) AND (check_in < $4 OR end_date < $4)`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, FavoriteStatusExpired, FavoriteStatusActive, FavoriteStatusPaused, today.Format("2006-01-02"))
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+// DeleteForWatchlist deletes every favorite of owner in watchlistID and
+// returns how many were deleted.
+func (m FavoriteModel) DeleteForWatchlist(watchlistID int, owner FavoriteOwner) (int64, error) {
+	condition, arg := owner.condition(2)
+	query := `DELETE FROM users_favorites WHERE watchlist_id = $1 AND ` + condition
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, watchlistID, arg)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+func favoriteError(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return ErrDuplicateFavorite
+	}
+	return err
+}
@@ -0,0 +1,87 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/madfelps/challenge-nuitee/internal/fx"
+)
+
+type FXRateModel struct {
+	DB *sql.DB
+}
+
+// Insert stores a rates snapshot. Snapshots are kept so every recorded price
+// observation can be traced back to the rates it was converted with. A
+// snapshot already stored, by another replica for instance, is left as is.
+func (m FXRateModel) Insert(rates *fx.Rates) error {
+	query := `
+		INSERT INTO fx_rates (base, currency, rate, fetched_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (fetched_at, base, currency) DO NOTHING`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for currency, rate := range rates.Rates {
+		_, err := tx.ExecContext(ctx, query, rates.Base, currency, rate, rates.FetchedAt)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Latest returns the most recent snapshot, or ErrRecordNotFound if no rates
+// were ever stored. Rates of different bases are never mixed, even when
+// they were fetched at the same time.
+func (m FXRateModel) Latest() (*fx.Rates, error) {
+	query := `
+		SELECT base, currency, rate, fetched_at
+		FROM fx_rates
+		WHERE (fetched_at, base) = (
+			SELECT fetched_at, base FROM fx_rates
+			ORDER BY fetched_at DESC, base
+			LIMIT 1
+		)`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rates := &fx.Rates{Rates: make(map[string]float64)}
+
+	for rows.Next() {
+		var currency string
+		var rate float64
+
+		err := rows.Scan(&rates.Base, &currency, &rate, &rates.FetchedAt)
+		if err != nil {
+			return nil, err
+		}
+		rates.Rates[currency] = rate
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(rates.Rates) == 0 {
+		return nil, ErrRecordNotFound
+	}
+
+	return rates, nil
+}
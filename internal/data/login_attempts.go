@@ -0,0 +1,81 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// Outcomes recorded for login attempts. Only failed credentials count
+// towards throttling; a success or a password reset clears the failures of
+// an account.
+const (
+	LoginOutcomeSuccess            = "success"
+	LoginOutcomeInvalidCredentials = "invalid_credentials"
+	LoginOutcomeThrottled          = "throttled"
+	LoginOutcomePasswordReset      = "password_reset"
+)
+
+// LoginAttempt is the audit record of one login attempt. UserID is nil when
+// the email does not belong to any account.
+type LoginAttempt struct {
+	ID        int64     `json:"id"`
+	Email     string    `json:"email"`
+	UserID    *int      `json:"user_id,omitempty"`
+	IP        string    `json:"ip"`
+	Outcome   string    `json:"outcome"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// LoginFailures counts recent failed logins for an email address and for an
+// IP address, with the time of the last one of each.
+type LoginFailures struct {
+	Account     int
+	LastAccount time.Time
+	IP          int
+	LastIP      time.Time
+}
+
+type LoginAttemptModel struct {
+	DB *sql.DB
+}
+
+func (m LoginAttemptModel) Insert(attempt *LoginAttempt) error {
+	query := `
+		INSERT INTO login_attempts (email, user_id, ip, outcome)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return m.DB.QueryRowContext(ctx, query, attempt.Email, attempt.UserID, attempt.IP, attempt.Outcome).Scan(&attempt.ID, &attempt.CreatedAt)
+}
+
+// Failures counts the failed logins since since: for email, only those after
+// its last successful login or password reset; for ip, all of them.
+func (m LoginAttemptModel) Failures(email, ip string, since time.Time) (LoginFailures, error) {
+	query := `
+		WITH cleared AS (
+			SELECT COALESCE(MAX(created_at), $3) AS since
+			FROM login_attempts
+			WHERE email = $1 AND outcome IN ('success', 'password_reset') AND created_at > $3
+		)
+		SELECT
+			(SELECT COUNT(*) FROM login_attempts, cleared
+				WHERE email = $1 AND outcome = 'invalid_credentials' AND created_at > cleared.since),
+			(SELECT COALESCE(MAX(created_at), 'epoch') FROM login_attempts, cleared
+				WHERE email = $1 AND outcome = 'invalid_credentials' AND created_at > cleared.since),
+			(SELECT COUNT(*) FROM login_attempts
+				WHERE ip = $2 AND outcome = 'invalid_credentials' AND created_at > $3),
+			(SELECT COALESCE(MAX(created_at), 'epoch') FROM login_attempts
+				WHERE ip = $2 AND outcome = 'invalid_credentials' AND created_at > $3)`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var failures LoginFailures
+
+	err := m.DB.QueryRowContext(ctx, query, email, ip, since).Scan(&failures.Account, &failures.LastAccount, &failures.IP, &failures.LastIP)
+	return failures, err
+}
@@ -1,17 +1,46 @@
 package models
 
-import "database/sql"
+import (
+	"database/sql"
+	"errors"
+)
+
+// ErrRecordNotFound is returned by every model when the record asked for
+// does not exist.
+var ErrRecordNotFound = errors.New("record not found")
 
 type Models struct {
-	Users         UserModel
-	Favorites     FavoriteModel
-	Notifications NotificationModel
+	Users             UserModel
+	Favorites         FavoriteModel
+	Notifications     NotificationModel
+	Tenants           TenantModel
+	FXRates           FXRateModel
+	PriceObservations PriceObservationModel
+	Preferences       PreferencesModel
+	Tokens            TokenModel
+	Roles             RoleModel
+	Permissions       PermissionModel
+	APIKeys           APIKeyModel
+	LoginAttempts     LoginAttemptModel
+	Organizations     OrganizationModel
+	Watchlists        WatchlistModel
 }
 
 func NewModels(db *sql.DB) Models {
 	return Models{
-		Users:         UserModel{DB: db},
-		Favorites:     FavoriteModel{DB: db},
-		Notifications: NotificationModel{DB: db},
+		Users:             UserModel{DB: db},
+		Favorites:         FavoriteModel{DB: db},
+		Notifications:     NotificationModel{DB: db},
+		Tenants:           TenantModel{DB: db},
+		FXRates:           FXRateModel{DB: db},
+		PriceObservations: PriceObservationModel{DB: db},
+		Preferences:       PreferencesModel{DB: db},
+		Tokens:            TokenModel{DB: db},
+		Roles:             RoleModel{DB: db},
+		Permissions:       PermissionModel{DB: db},
+		APIKeys:           APIKeyModel{DB: db},
+		LoginAttempts:     LoginAttemptModel{DB: db},
+		Organizations:     OrganizationModel{DB: db},
+		Watchlists:        WatchlistModel{DB: db},
 	}
 }
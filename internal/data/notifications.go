@@ -1,7 +1,83 @@
 package models
 
-import "database/sql"
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// Notification is an alert addressed to a user through one channel. SentAt
+// is nil until the alert was delivered.
+type Notification struct {
+	ID         int        `json:"id"`
+	UserID     int        `json:"user_id"`
+	FavoriteID *int       `json:"favorite_id,omitempty"`
+	Channel    string     `json:"channel"`
+	Message    string     `json:"message"`
+	CreatedAt  time.Time  `json:"created_at"`
+	SentAt     *time.Time `json:"sent_at,omitempty"`
+}
 
 type NotificationModel struct {
 	DB *sql.DB
 }
+
+func (m NotificationModel) Insert(notification *Notification) error {
+	query := `
+		INSERT INTO notifications (user_id, favorite_id, channel, message, sent_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at`
+
+	args := []interface{}{
+		notification.UserID,
+		notification.FavoriteID,
+		notification.Channel,
+		notification.Message,
+		notification.SentAt,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return m.DB.QueryRowContext(ctx, query, args...).Scan(&notification.ID, &notification.CreatedAt)
+}
+
+func (m NotificationModel) MarkSent(id int) error {
+	query := `UPDATE notifications SET sent_at = NOW() WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, id)
+	return err
+}
+
+// ListForUser returns every notification addressed to userID, oldest first.
+func (m NotificationModel) ListForUser(userID int) ([]Notification, error) {
+	query := `
+		SELECT id, user_id, favorite_id, channel, message, created_at, sent_at
+		FROM notifications
+		WHERE user_id = $1
+		ORDER BY created_at, id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	notifications := []Notification{}
+	for rows.Next() {
+		var n Notification
+		err := rows.Scan(&n.ID, &n.UserID, &n.FavoriteID, &n.Channel, &n.Message, &n.CreatedAt, &n.SentAt)
+		if err != nil {
+			return nil, err
+		}
+		notifications = append(notifications, n)
+	}
+
+	return notifications, rows.Err()
+}
@@ -0,0 +1,295 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Organization roles. Owners and admins manage members; only owners can
+// grant the owner role or delete the organization.
+const (
+	OrganizationRoleOwner  = "owner"
+	OrganizationRoleAdmin  = "admin"
+	OrganizationRoleMember = "member"
+)
+
+// OrganizationRoles lists the roles a member can have.
+var OrganizationRoles = []string{OrganizationRoleOwner, OrganizationRoleAdmin, OrganizationRoleMember}
+
+var (
+	ErrDuplicateMember = errors.New("duplicate member")
+	ErrLastOwner       = errors.New("last owner")
+)
+
+type Organization struct {
+	ID        int       `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Member is a user's membership of an organization. Subscribed members
+// receive the price alerts of the organization's favorites.
+type Member struct {
+	OrganizationID int       `json:"organization_id"`
+	UserID         int       `json:"user_id"`
+	Name           string    `json:"name"`
+	Email          string    `json:"email"`
+	Role           string    `json:"role"`
+	Subscribed     bool      `json:"subscribed"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// CanManageMembers reports whether the member may add, change and remove
+// members.
+func (m *Member) CanManageMembers() bool {
+	return m.Role == OrganizationRoleOwner || m.Role == OrganizationRoleAdmin
+}
+
+type OrganizationModel struct {
+	DB *sql.DB
+}
+
+// Insert creates an organization with ownerID as its first owner,
+// subscribed to its alerts.
+func (m OrganizationModel) Insert(org *Organization, ownerID int) error {
+	query := `
+		WITH new_organization AS (
+			INSERT INTO organizations (name)
+			VALUES ($1)
+			RETURNING id, created_at
+		), owner AS (
+			INSERT INTO organization_members (organization_id, user_id, role, subscribed)
+			SELECT id, $2, 'owner', TRUE FROM new_organization
+		)
+		SELECT id, created_at FROM new_organization`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return m.DB.QueryRowContext(ctx, query, org.Name, ownerID).Scan(&org.ID, &org.CreatedAt)
+}
+
+// Get returns an organization, or ErrRecordNotFound.
+func (m OrganizationModel) Get(id int) (*Organization, error) {
+	query := `SELECT id, name, created_at FROM organizations WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var org Organization
+
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(&org.ID, &org.Name, &org.CreatedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &org, nil
+}
+
+// ListForUser returns the organizations userID is a member of.
+func (m OrganizationModel) ListForUser(userID int) ([]Organization, error) {
+	query := `
+		SELECT o.id, o.name, o.created_at
+		FROM organizations o
+		INNER JOIN organization_members om ON om.organization_id = o.id
+		WHERE om.user_id = $1
+		ORDER BY o.name, o.id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	organizations := []Organization{}
+	for rows.Next() {
+		var org Organization
+		if err := rows.Scan(&org.ID, &org.Name, &org.CreatedAt); err != nil {
+			return nil, err
+		}
+		organizations = append(organizations, org)
+	}
+
+	return organizations, rows.Err()
+}
+
+func (m OrganizationModel) Delete(id int) error {
+	query := `DELETE FROM organizations WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, id)
+	return err
+}
+
+const memberColumns = `
+	SELECT om.organization_id, om.user_id, u.name, u.email, om.role, om.subscribed, om.created_at
+	FROM organization_members om
+	INNER JOIN users u ON u.id = om.user_id`
+
+// GetMember returns the membership of userID in organizationID, or
+// ErrRecordNotFound when they are not a member.
+func (m OrganizationModel) GetMember(organizationID, userID int) (*Member, error) {
+	members, err := m.listMembers(memberColumns+` WHERE om.organization_id = $1 AND om.user_id = $2`, organizationID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(members) == 0 {
+		return nil, ErrRecordNotFound
+	}
+
+	return &members[0], nil
+}
+
+func (m OrganizationModel) ListMembers(organizationID int) ([]Member, error) {
+	return m.listMembers(memberColumns+` WHERE om.organization_id = $1 ORDER BY om.created_at, om.user_id`, organizationID)
+}
+
+// ListSubscribers returns the IDs of the members receiving the price alerts
+// of organizationID.
+func (m OrganizationModel) ListSubscribers(organizationID int) ([]int, error) {
+	query := `
+		SELECT user_id FROM organization_members
+		WHERE organization_id = $1 AND subscribed
+		ORDER BY user_id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, organizationID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	userIDs := []int{}
+	for rows.Next() {
+		var userID int
+		if err := rows.Scan(&userID); err != nil {
+			return nil, err
+		}
+		userIDs = append(userIDs, userID)
+	}
+
+	return userIDs, rows.Err()
+}
+
+func (m OrganizationModel) listMembers(query string, args ...interface{}) ([]Member, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	members := []Member{}
+	for rows.Next() {
+		var member Member
+		err := rows.Scan(&member.OrganizationID, &member.UserID, &member.Name, &member.Email, &member.Role, &member.Subscribed, &member.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		members = append(members, member)
+	}
+
+	return members, rows.Err()
+}
+
+// AddMember adds userID to organizationID with role. The member is not
+// subscribed to its alerts until they opt in.
+func (m OrganizationModel) AddMember(organizationID, userID int, role string) error {
+	query := `
+		INSERT INTO organization_members (organization_id, user_id, role, subscribed)
+		VALUES ($1, $2, $3, FALSE)`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, organizationID, userID, role)
+	if err != nil {
+		switch {
+		case err.Error() == `pq: duplicate key value violates unique constraint "organization_members_pkey"`:
+			return ErrDuplicateMember
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// UpdateMember saves the role and subscription of member. It returns
+// ErrLastOwner instead of demoting the only owner.
+func (m OrganizationModel) UpdateMember(member *Member) error {
+	query := `
+		UPDATE organization_members
+		SET role = $3, subscribed = $4
+		WHERE organization_id = $1 AND user_id = $2
+		AND ($3 = 'owner' OR EXISTS (
+			SELECT 1 FROM organization_members
+			WHERE organization_id = $1 AND user_id <> $2 AND role = 'owner'
+		) OR role <> 'owner')`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, member.OrganizationID, member.UserID, member.Role, member.Subscribed)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrLastOwner
+	}
+
+	return nil
+}
+
+// RemoveMember removes userID from organizationID. It returns ErrLastOwner
+// instead of removing the only owner. Favorites the member created stay
+// with the organization.
+func (m OrganizationModel) RemoveMember(organizationID, userID int) error {
+	query := `
+		DELETE FROM organization_members
+		WHERE organization_id = $1 AND user_id = $2
+		AND (role <> 'owner' OR EXISTS (
+			SELECT 1 FROM organization_members
+			WHERE organization_id = $1 AND user_id <> $2 AND role = 'owner'
+		))`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, organizationID, userID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrLastOwner
+	}
+
+	return nil
+}
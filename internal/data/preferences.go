@@ -0,0 +1,122 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	AlertChannelLog   = "log"
+	AlertChannelEmail = "email"
+
+	DefaultCurrency    = "USD"
+	DefaultNationality = "US"
+)
+
+// AlertChannels lists the channels price alerts can be delivered through.
+var AlertChannels = []string{AlertChannelLog, AlertChannelEmail}
+
+type Preferences struct {
+	UserID        int       `json:"-"`
+	Currency      string    `json:"currency"`
+	Nationality   string    `json:"nationality"`
+	Timezone      string    `json:"timezone"`
+	Locale        string    `json:"locale"`
+	AlertChannels []string  `json:"alert_channels"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// DefaultPreferences returns the preferences used for users who never saved
+// their own. They match the column defaults of user_preferences.
+func DefaultPreferences(userID int) *Preferences {
+	return &Preferences{
+		UserID:        userID,
+		Currency:      DefaultCurrency,
+		Nationality:   DefaultNationality,
+		Timezone:      "UTC",
+		Locale:        "en-US",
+		AlertChannels: []string{AlertChannelLog},
+	}
+}
+
+// Location returns the time zone of the preferences, falling back to UTC if
+// it cannot be loaded.
+func (p *Preferences) Location() *time.Location {
+	loc, err := time.LoadLocation(p.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+type PreferencesModel struct {
+	DB *sql.DB
+}
+
+// Get returns the preferences of a user, or the defaults if none were saved.
+func (m PreferencesModel) Get(userID int) (*Preferences, error) {
+	query := `
+		SELECT currency, nationality, timezone, locale, alert_channels, updated_at
+		FROM user_preferences
+		WHERE user_id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	prefs := &Preferences{UserID: userID}
+
+	err := m.DB.QueryRowContext(ctx, query, userID).Scan(
+		&prefs.Currency,
+		&prefs.Nationality,
+		&prefs.Timezone,
+		&prefs.Locale,
+		pq.Array(&prefs.AlertChannels),
+		&prefs.UpdatedAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return DefaultPreferences(userID), nil
+		default:
+			return nil, err
+		}
+	}
+
+	return prefs, nil
+}
+
+func (m PreferencesModel) Upsert(prefs *Preferences) error {
+	query := `
+		INSERT INTO user_preferences (user_id, currency, nationality, timezone, locale, alert_channels, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, NOW())
+		ON CONFLICT (user_id) DO UPDATE SET
+			currency = EXCLUDED.currency,
+			nationality = EXCLUDED.nationality,
+			timezone = EXCLUDED.timezone,
+			locale = EXCLUDED.locale,
+			alert_channels = EXCLUDED.alert_channels,
+			updated_at = EXCLUDED.updated_at
+		RETURNING updated_at`
+
+	if prefs.AlertChannels == nil {
+		prefs.AlertChannels = []string{}
+	}
+
+	args := []interface{}{
+		prefs.UserID,
+		prefs.Currency,
+		prefs.Nationality,
+		prefs.Timezone,
+		prefs.Locale,
+		pq.Array(prefs.AlertChannels),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return m.DB.QueryRowContext(ctx, query, args...).Scan(&prefs.UpdatedAt)
+}
@@ -0,0 +1,85 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// PriceObservation is a price seen by the monitor for a favorite, in the
+// supplier currency and converted into the favorite's currency. FXRate and
+// FXRatesFetchedAt identify the rates used; FXRatesFetchedAt is nil when no
+// conversion was needed.
+type PriceObservation struct {
+	ID                int        `json:"id"`
+	FavoriteID        int        `json:"favorite_id"`
+	HotelID           string     `json:"hotel_id"`
+	Price             float64    `json:"price"`
+	Currency          string     `json:"currency"`
+	FXRate            float64    `json:"fx_rate"`
+	FXRatesFetchedAt  *time.Time `json:"fx_rates_fetched_at,omitempty"`
+	ConvertedPrice    float64    `json:"converted_price"`
+	ConvertedCurrency string     `json:"converted_currency"`
+	ObservedAt        time.Time  `json:"observed_at"`
+}
+
+type PriceObservationModel struct {
+	DB *sql.DB
+}
+
+func (m PriceObservationModel) Insert(observation *PriceObservation) error {
+	query := `
+		INSERT INTO price_observations (favorite_id, hotel_id, price, currency, fx_rate, fx_rates_fetched_at, converted_price, converted_currency)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id, observed_at`
+
+	args := []interface{}{
+		observation.FavoriteID,
+		observation.HotelID,
+		observation.Price,
+		observation.Currency,
+		observation.FXRate,
+		observation.FXRatesFetchedAt,
+		observation.ConvertedPrice,
+		observation.ConvertedCurrency,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return m.DB.QueryRowContext(ctx, query, args...).Scan(&observation.ID, &observation.ObservedAt)
+}
+
+// ListForUser returns every price observed for the favorites of userID,
+// oldest first.
+func (m PriceObservationModel) ListForUser(userID int) ([]PriceObservation, error) {
+	query := `
+		SELECT po.id, po.favorite_id, po.hotel_id, po.price, po.currency, po.fx_rate, po.fx_rates_fetched_at,
+			po.converted_price, po.converted_currency, po.observed_at
+		FROM price_observations po
+		INNER JOIN users_favorites uf ON uf.id = po.favorite_id
+		WHERE uf.user_id = $1
+		ORDER BY po.observed_at, po.id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	observations := []PriceObservation{}
+	for rows.Next() {
+		var o PriceObservation
+		err := rows.Scan(&o.ID, &o.FavoriteID, &o.HotelID, &o.Price, &o.Currency, &o.FXRate, &o.FXRatesFetchedAt,
+			&o.ConvertedPrice, &o.ConvertedCurrency, &o.ObservedAt)
+		if err != nil {
+			return nil, err
+		}
+		observations = append(observations, o)
+	}
+
+	return observations, rows.Err()
+}
@@ -0,0 +1,189 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	RoleUser    = "user"
+	RoleSupport = "support"
+	RoleAdmin   = "admin"
+
+	PermissionUsersRead   = "users:read"
+	PermissionRolesWrite  = "roles:write"
+	PermissionMetricsRead = "metrics:read"
+)
+
+var (
+	ErrUnknownRole = errors.New("unknown role")
+)
+
+type Permissions []string
+
+func (p Permissions) Include(code string) bool {
+	return slices.Contains(p, code)
+}
+
+type Role struct {
+	Name        string      `json:"name"`
+	Permissions Permissions `json:"permissions"`
+}
+
+type RoleModel struct {
+	DB *sql.DB
+}
+
+func (m RoleModel) List() ([]Role, error) {
+	query := `
+		SELECT r.name, COALESCE(array_agg(p.code ORDER BY p.code) FILTER (WHERE p.code IS NOT NULL), '{}')
+		FROM roles r
+		LEFT JOIN roles_permissions rp ON rp.role_id = r.id
+		LEFT JOIN permissions p ON p.id = rp.permission_id
+		GROUP BY r.id, r.name
+		ORDER BY r.id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	roles := []Role{}
+	for rows.Next() {
+		var role Role
+		if err := rows.Scan(&role.Name, pq.Array(&role.Permissions)); err != nil {
+			return nil, err
+		}
+		roles = append(roles, role)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return roles, nil
+}
+
+func (m RoleModel) GetAllForUser(userID int) ([]string, error) {
+	query := `
+		SELECT r.name
+		FROM roles r
+		INNER JOIN users_roles ur ON ur.role_id = r.id
+		WHERE ur.user_id = $1
+		ORDER BY r.id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	roles := []string{}
+	for rows.Next() {
+		var role string
+		if err := rows.Scan(&role); err != nil {
+			return nil, err
+		}
+		roles = append(roles, role)
+	}
+
+	return roles, rows.Err()
+}
+
+// SetForUser replaces the roles of userID. Unknown role names return
+// ErrUnknownRole and leave the user's roles untouched.
+func (m RoleModel) SetForUser(userID int, roles []string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM users_roles WHERE user_id = $1`, userID)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO users_roles (user_id, role_id)
+		SELECT $1, r.id FROM roles r WHERE r.name = ANY($2)`
+
+	result, err := tx.ExecContext(ctx, query, userID, pq.Array(roles))
+	if err != nil {
+		return err
+	}
+
+	inserted, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if int(inserted) != len(roles) {
+		return ErrUnknownRole
+	}
+
+	return tx.Commit()
+}
+
+// AddForUser grants role to userID, keeping the roles it already has.
+func (m RoleModel) AddForUser(userID int, role string) error {
+	query := `
+		INSERT INTO users_roles (user_id, role_id)
+		SELECT $1, r.id FROM roles r WHERE r.name = $2
+		ON CONFLICT DO NOTHING`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, userID, role)
+	return err
+}
+
+type PermissionModel struct {
+	DB *sql.DB
+}
+
+// GetAllForUser returns the permissions granted to userID through its roles.
+func (m PermissionModel) GetAllForUser(userID int) (Permissions, error) {
+	query := `
+		SELECT DISTINCT p.code
+		FROM permissions p
+		INNER JOIN roles_permissions rp ON rp.permission_id = p.id
+		INNER JOIN users_roles ur ON ur.role_id = rp.role_id
+		WHERE ur.user_id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var permissions Permissions
+	for rows.Next() {
+		var permission string
+		if err := rows.Scan(&permission); err != nil {
+			return nil, err
+		}
+		permissions = append(permissions, permission)
+	}
+
+	return permissions, rows.Err()
+}
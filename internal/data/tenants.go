@@ -0,0 +1,66 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Tenant is a client of our API. LiteAPIKeyEncrypted holds the tenant's own
+// LiteAPI key, encrypted by the caller; it is nil when the tenant uses the
+// server's key.
+type Tenant struct {
+	ID                  int
+	Name                string
+	APIKeyHash          []byte
+	LiteAPIKeyEncrypted []byte
+	CreatedAt           time.Time
+}
+
+type TenantModel struct {
+	DB *sql.DB
+}
+
+func (m TenantModel) Insert(tenant *Tenant) error {
+	query := `
+		INSERT INTO tenants (name, api_key_hash, liteapi_key_encrypted)
+		VALUES ($1, $2, $3)
+		RETURNING id, created_at`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return m.DB.QueryRowContext(ctx, query, tenant.Name, tenant.APIKeyHash, tenant.LiteAPIKeyEncrypted).
+		Scan(&tenant.ID, &tenant.CreatedAt)
+}
+
+func (m TenantModel) GetByKeyHash(hash []byte) (*Tenant, error) {
+	query := `
+		SELECT id, name, api_key_hash, liteapi_key_encrypted, created_at
+		FROM tenants
+		WHERE api_key_hash = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var tenant Tenant
+
+	err := m.DB.QueryRowContext(ctx, query, hash).Scan(
+		&tenant.ID,
+		&tenant.Name,
+		&tenant.APIKeyHash,
+		&tenant.LiteAPIKeyEncrypted,
+		&tenant.CreatedAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &tenant, nil
+}
@@ -0,0 +1,226 @@
+package models
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base32"
+	"errors"
+	"time"
+)
+
+const (
+	ScopeRefresh       = "refresh"
+	ScopeActivation    = "activation"
+	ScopePasswordReset = "password-reset"
+)
+
+var (
+	// ErrTokenReused is returned when a refresh token that was already
+	// rotated is presented again. Its whole family has been revoked.
+	ErrTokenReused = errors.New("token reused")
+)
+
+// Token is an opaque token. Only its SHA-256 hash is stored; Plaintext is
+// set only on tokens created in this process. Tokens created by rotating
+// another one share its Family, so a stolen and replayed token can revoke
+// every descendant at once.
+type Token struct {
+	Plaintext string
+	Hash      []byte
+	UserID    int
+	Family    []byte
+	Expiry    time.Time
+	Scope     string
+}
+
+func generateToken(userID int, ttl time.Duration, scope string, family []byte) (*Token, error) {
+	token := &Token{
+		UserID: userID,
+		Family: family,
+		Expiry: time.Now().Add(ttl),
+		Scope:  scope,
+	}
+
+	randomBytes := make([]byte, 32)
+	if _, err := rand.Read(randomBytes); err != nil {
+		return nil, err
+	}
+
+	token.Plaintext = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(randomBytes)
+	token.Hash = HashToken(token.Plaintext)
+
+	return token, nil
+}
+
+func HashToken(plaintext string) []byte {
+	hash := sha256.Sum256([]byte(plaintext))
+	return hash[:]
+}
+
+type TokenModel struct {
+	DB *sql.DB
+}
+
+// New creates and stores a token starting a new family.
+func (m TokenModel) New(userID int, ttl time.Duration, scope string) (*Token, error) {
+	family := make([]byte, 16)
+	if _, err := rand.Read(family); err != nil {
+		return nil, err
+	}
+
+	token, err := generateToken(userID, ttl, scope, family)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err = insertToken(ctx, m.DB, token)
+	return token, err
+}
+
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+func insertToken(ctx context.Context, db execer, token *Token) error {
+	query := `
+		INSERT INTO tokens (hash, user_id, family, scope, expiry)
+		VALUES ($1, $2, $3, $4, $5)`
+
+	_, err := db.ExecContext(ctx, query, token.Hash, token.UserID, token.Family, token.Scope, token.Expiry)
+	return err
+}
+
+// Rotate exchanges a valid token for a new one of the same family, valid for
+// ttl. Presenting a token that was already rotated revokes its family and
+// returns ErrTokenReused; unknown, expired or revoked tokens return
+// ErrRecordNotFound.
+func (m TokenModel) Rotate(plaintext string, ttl time.Duration, scope string) (*Token, error) {
+	query := `
+		SELECT user_id, family, expiry, used_at IS NOT NULL, revoked_at IS NOT NULL
+		FROM tokens
+		WHERE hash = $1 AND scope = $2
+		FOR UPDATE`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var current Token
+	var used, revoked bool
+
+	err = tx.QueryRowContext(ctx, query, HashToken(plaintext), scope).Scan(
+		&current.UserID,
+		&current.Family,
+		&current.Expiry,
+		&used,
+		&revoked,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	if revoked || time.Now().After(current.Expiry) {
+		return nil, ErrRecordNotFound
+	}
+
+	if used {
+		_, err = tx.ExecContext(ctx, `
+			UPDATE tokens SET revoked_at = NOW()
+			WHERE family = $1 AND revoked_at IS NULL`, current.Family)
+		if err != nil {
+			return nil, err
+		}
+
+		if err = tx.Commit(); err != nil {
+			return nil, err
+		}
+		return nil, ErrTokenReused
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE tokens SET used_at = NOW() WHERE hash = $1`, HashToken(plaintext))
+	if err != nil {
+		return nil, err
+	}
+
+	next, err := generateToken(current.UserID, ttl, scope, current.Family)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = insertToken(ctx, tx, next); err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return next, nil
+}
+
+// RevokeFamily revokes the family of the given token, ending that session.
+func (m TokenModel) RevokeFamily(plaintext, scope string) error {
+	query := `
+		UPDATE tokens SET revoked_at = NOW()
+		WHERE revoked_at IS NULL AND family = (
+			SELECT family FROM tokens WHERE hash = $1 AND scope = $2
+		)`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, HashToken(plaintext), scope)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// RevokeAllForUser revokes every token of the given scope owned by userID.
+func (m TokenModel) RevokeAllForUser(userID int, scope string) error {
+	query := `
+		UPDATE tokens SET revoked_at = NOW()
+		WHERE user_id = $1 AND scope = $2 AND revoked_at IS NULL`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, userID, scope)
+	return err
+}
+
+// DeleteAllForUser removes every token of the given scope owned by userID.
+func (m TokenModel) DeleteAllForUser(scope string, userID int) error {
+	query := `DELETE FROM tokens WHERE scope = $1 AND user_id = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, scope, userID)
+	return err
+}
@@ -15,11 +15,19 @@ var (
 	ErrDuplicateEmail = errors.New("duplicate email")
 )
 
+// Insert creates a user with the default user role.
 func (m UserModel) Insert(name, email, passwordHash string) (int, error) {
 	query := `
-		INSERT INTO users (name, email, password_hash)
-		VALUES ($1, $2, $3)
-		RETURNING id`
+		WITH new_user AS (
+			INSERT INTO users (name, email, password_hash)
+			VALUES ($1, $2, $3)
+			RETURNING id
+		), default_role AS (
+			INSERT INTO users_roles (user_id, role_id)
+			SELECT new_user.id, roles.id FROM new_user, roles
+			WHERE roles.name = 'user'
+		)
+		SELECT id FROM new_user`
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
@@ -38,17 +46,27 @@ func (m UserModel) Insert(name, email, passwordHash string) (int, error) {
 	return userID, nil
 }
 
+// AnonymousUser is stored in the request context when no credentials were
+// sent.
+var AnonymousUser = &User{}
+
 type User struct {
-	ID        int       `json:"id"`
-	Name      string    `json:"name"`
-	Email     string    `json:"email"`
-	CreatedAt time.Time `json:"created_at"`
+	ID           int       `json:"id"`
+	Name         string    `json:"name"`
+	Email        string    `json:"email"`
+	PasswordHash string    `json:"-"`
+	Activated    bool      `json:"activated"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+func (u *User) IsAnonymous() bool {
+	return u == AnonymousUser
 }
 
 func (m UserModel) List(limit, offset int) ([]User, int, error) {
 	query := `
-		SELECT id, name, email, created_at 
-		FROM users 
+		SELECT id, name, email, activated, created_at
+		FROM users
 		ORDER BY created_at DESC 
 		LIMIT $1 OFFSET $2`
 
@@ -64,7 +82,7 @@ func (m UserModel) List(limit, offset int) ([]User, int, error) {
 	var users []User
 	for rows.Next() {
 		var user User
-		err := rows.Scan(&user.ID, &user.Name, &user.Email, &user.CreatedAt)
+		err := rows.Scan(&user.ID, &user.Name, &user.Email, &user.Activated, &user.CreatedAt)
 		if err != nil {
 			return nil, 0, err
 		}
@@ -84,3 +102,193 @@ func (m UserModel) List(limit, offset int) ([]User, int, error) {
 
 	return users, total, nil
 }
+
+func (m UserModel) Exists(id int) (bool, error) {
+	query := `SELECT EXISTS(SELECT 1 FROM users WHERE id = $1)`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var exists bool
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(&exists)
+
+	return exists, err
+}
+
+func (m UserModel) Get(id int) (*User, error) {
+	query := `
+		SELECT id, name, email, password_hash, activated, created_at
+		FROM users
+		WHERE id = $1`
+
+	return m.getOne(query, id)
+}
+
+func (m UserModel) GetByEmail(email string) (*User, error) {
+	query := `
+		SELECT id, name, email, password_hash, activated, created_at
+		FROM users
+		WHERE email = $1`
+
+	return m.getOne(query, email)
+}
+
+// GetForToken returns the owner of a valid, unused token of the given scope.
+func (m UserModel) GetForToken(scope, tokenPlaintext string) (*User, error) {
+	query := `
+		SELECT users.id, users.name, users.email, users.password_hash, users.activated, users.created_at
+		FROM users
+		INNER JOIN tokens ON tokens.user_id = users.id
+		WHERE tokens.hash = $1
+		AND tokens.scope = $2
+		AND tokens.expiry > $3
+		AND tokens.used_at IS NULL
+		AND tokens.revoked_at IS NULL`
+
+	return m.getOne(query, HashToken(tokenPlaintext), scope, time.Now())
+}
+
+func (m UserModel) Activate(id int) error {
+	query := `UPDATE users SET activated = TRUE WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, id)
+	return err
+}
+
+func (m UserModel) getOne(query string, args ...interface{}) (*User, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var user User
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(
+		&user.ID,
+		&user.Name,
+		&user.Email,
+		&user.PasswordHash,
+		&user.Activated,
+		&user.CreatedAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &user, nil
+}
+
+func (m UserModel) UpdatePasswordHash(id int, passwordHash string) error {
+	query := `UPDATE users SET password_hash = $1 WHERE id = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, passwordHash, id)
+	return err
+}
+
+// Update saves the name, email and activation status of user.
+func (m UserModel) Update(user *User) error {
+	query := `
+		UPDATE users
+		SET name = $1, email = $2, activated = $3
+		WHERE id = $4`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, user.Name, user.Email, user.Activated, user.ID)
+	if err != nil {
+		switch {
+		case err.Error() == `pq: duplicate key value violates unique constraint "users_email_key"`:
+			return ErrDuplicateEmail
+		default:
+			return err
+		}
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// Delete removes a user. Favorites, price observations, preferences,
+// notifications, tokens, role and organization memberships go with it
+// through ON DELETE CASCADE. Organizations they belong to are kept: the
+// oldest remaining member becomes owner when they were the only one, and
+// favorites they created are handed to an owner. Organizations left
+// without members are deleted.
+func (m UserModel) Delete(id int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	queries := []string{
+		`UPDATE organization_members om SET role = 'owner'
+		WHERE (om.organization_id, om.user_id) IN (
+			SELECT DISTINCT ON (o.organization_id) o.organization_id, o.user_id
+			FROM organization_members o
+			WHERE o.user_id <> $1
+			AND o.organization_id IN (
+				SELECT organization_id FROM organization_members WHERE user_id = $1 AND role = 'owner'
+			)
+			AND NOT EXISTS (
+				SELECT 1 FROM organization_members other
+				WHERE other.organization_id = o.organization_id AND other.user_id <> $1 AND other.role = 'owner'
+			)
+			ORDER BY o.organization_id, o.created_at, o.user_id
+		)`,
+		`UPDATE users_favorites f SET user_id = (
+			SELECT om.user_id FROM organization_members om
+			WHERE om.organization_id = f.organization_id AND om.user_id <> $1 AND om.role = 'owner'
+			ORDER BY om.created_at, om.user_id
+			LIMIT 1
+		)
+		WHERE f.user_id = $1 AND EXISTS (
+			SELECT 1 FROM organization_members om
+			WHERE om.organization_id = f.organization_id AND om.user_id <> $1 AND om.role = 'owner'
+		)`,
+		`DELETE FROM organizations o
+		WHERE EXISTS (SELECT 1 FROM organization_members WHERE organization_id = o.id AND user_id = $1)
+		AND NOT EXISTS (SELECT 1 FROM organization_members WHERE organization_id = o.id AND user_id <> $1)`,
+	}
+
+	for _, query := range queries {
+		if _, err := tx.ExecContext(ctx, query, id); err != nil {
+			return err
+		}
+	}
+
+	result, err := tx.ExecContext(ctx, `DELETE FROM users WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrRecordNotFound
+	}
+
+	return tx.Commit()
+}
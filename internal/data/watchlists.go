@@ -0,0 +1,140 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+var ErrDuplicateWatchlist = errors.New("duplicate watchlist")
+
+// Watchlist is a named collection of favorites, such as "Lisbon trip". Like
+// favorites, it belongs to a user or to an organization.
+type Watchlist struct {
+	ID             int       `json:"id"`
+	OrganizationID *int      `json:"organization_id,omitempty"`
+	Name           string    `json:"name"`
+	Favorites      int       `json:"favorites"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+type WatchlistModel struct {
+	DB *sql.DB
+}
+
+// Insert creates a watchlist for owner.
+func (m WatchlistModel) Insert(watchlist *Watchlist, owner FavoriteOwner) error {
+	query := `
+		INSERT INTO watchlists (user_id, organization_id, name)
+		VALUES ($1, $2, $3)
+		RETURNING id, created_at`
+
+	var userID *int
+	if owner.OrganizationID == nil {
+		userID = &owner.UserID
+	}
+	watchlist.OrganizationID = owner.OrganizationID
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, userID, owner.OrganizationID, watchlist.Name).Scan(&watchlist.ID, &watchlist.CreatedAt)
+	return watchlistError(err)
+}
+
+const watchlistColumns = `
+	SELECT w.id, w.organization_id, w.name, w.created_at,
+		(SELECT COUNT(*) FROM users_favorites f WHERE f.watchlist_id = w.id)
+	FROM watchlists w`
+
+func (m WatchlistModel) List(owner FavoriteOwner) ([]Watchlist, error) {
+	condition, arg := owner.condition(1)
+	return m.list(watchlistColumns+` WHERE w.`+condition+` ORDER BY lower(w.name), w.id`, arg)
+}
+
+// Get returns a watchlist only if it belongs to owner.
+func (m WatchlistModel) Get(id int, owner FavoriteOwner) (*Watchlist, error) {
+	condition, arg := owner.condition(2)
+
+	watchlists, err := m.list(watchlistColumns+` WHERE w.id = $1 AND w.`+condition, id, arg)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(watchlists) == 0 {
+		return nil, ErrRecordNotFound
+	}
+
+	return &watchlists[0], nil
+}
+
+// Rename saves the name of watchlist.
+func (m WatchlistModel) Rename(watchlist *Watchlist) error {
+	query := `UPDATE watchlists SET name = $1 WHERE id = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, watchlist.Name, watchlist.ID)
+	return watchlistError(err)
+}
+
+// Delete removes a watchlist of owner. Its favorites are kept, outside of
+// any watchlist.
+func (m WatchlistModel) Delete(id int, owner FavoriteOwner) error {
+	condition, arg := owner.condition(2)
+	query := `DELETE FROM watchlists WHERE id = $1 AND ` + condition
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	// Favorites of the watchlist are kept outside of any watchlist, which
+	// collides with favorites of the same hotels already there.
+	result, err := m.DB.ExecContext(ctx, query, id, arg)
+	if err != nil {
+		return favoriteError(err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+func (m WatchlistModel) list(query string, args ...interface{}) ([]Watchlist, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	watchlists := []Watchlist{}
+	for rows.Next() {
+		var w Watchlist
+		if err := rows.Scan(&w.ID, &w.OrganizationID, &w.Name, &w.CreatedAt, &w.Favorites); err != nil {
+			return nil, err
+		}
+		watchlists = append(watchlists, w)
+	}
+
+	return watchlists, rows.Err()
+}
+
+func watchlistError(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return ErrDuplicateWatchlist
+	}
+	return err
+}
@@ -0,0 +1,5 @@
+ALTER TABLE IF EXISTS users_favorites
+    DROP COLUMN IF EXISTS refundable_only,
+    DROP COLUMN IF EXISTS board_types,
+    DROP COLUMN IF EXISTS min_capacity,
+    DROP COLUMN IF EXISTS max_taxes;
\ No newline at end of file
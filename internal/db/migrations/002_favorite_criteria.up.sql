@@ -0,0 +1,5 @@
+ALTER TABLE users_favorites
+    ADD COLUMN refundable_only BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN board_types TEXT[] NOT NULL DEFAULT '{}',
+    ADD COLUMN min_capacity INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN max_taxes NUMERIC(10,2);
\ No newline at end of file
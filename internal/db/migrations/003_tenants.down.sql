@@ -0,0 +1 @@
+DROP TABLE IF EXISTS tenants;
\ No newline at end of file
@@ -0,0 +1,7 @@
+CREATE TABLE tenants (
+    id SERIAL PRIMARY KEY,
+    name TEXT NOT NULL,
+    api_key_hash BYTEA UNIQUE NOT NULL,
+    liteapi_key_encrypted BYTEA,
+    created_at TIMESTAMP DEFAULT NOW()
+);
\ No newline at end of file
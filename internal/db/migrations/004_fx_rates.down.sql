@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS price_observations;
+
+ALTER TABLE IF EXISTS users_favorites
+    DROP COLUMN IF EXISTS currency;
+
+DROP TABLE IF EXISTS fx_rates;
\ No newline at end of file
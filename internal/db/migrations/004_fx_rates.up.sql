@@ -0,0 +1,25 @@
+CREATE TABLE fx_rates (
+    base TEXT NOT NULL,
+    currency TEXT NOT NULL,
+    rate NUMERIC(20,10) NOT NULL,
+    fetched_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (fetched_at, currency)
+);
+
+ALTER TABLE users_favorites
+    ADD COLUMN currency TEXT NOT NULL DEFAULT 'USD';
+
+CREATE TABLE price_observations (
+    id SERIAL PRIMARY KEY,
+    favorite_id INTEGER NOT NULL REFERENCES users_favorites(id) ON DELETE CASCADE,
+    hotel_id TEXT NOT NULL,
+    price NUMERIC(10,2) NOT NULL,
+    currency TEXT NOT NULL,
+    fx_rate NUMERIC(20,10) NOT NULL,
+    fx_rates_fetched_at TIMESTAMP,
+    converted_price NUMERIC(10,2) NOT NULL,
+    converted_currency TEXT NOT NULL,
+    observed_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX price_observations_favorite_id_idx ON price_observations (favorite_id, observed_at);
\ No newline at end of file
@@ -0,0 +1,21 @@
+CREATE TABLE user_preferences (
+    user_id INTEGER PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    currency TEXT NOT NULL DEFAULT 'USD',
+    nationality TEXT NOT NULL DEFAULT 'US',
+    timezone TEXT NOT NULL DEFAULT 'UTC',
+    locale TEXT NOT NULL DEFAULT 'en-US',
+    alert_channels TEXT[] NOT NULL DEFAULT '{log}',
+    updated_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE TABLE notifications (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    favorite_id INTEGER REFERENCES users_favorites(id) ON DELETE SET NULL,
+    channel TEXT NOT NULL,
+    message TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT NOW(),
+    sent_at TIMESTAMP
+);
+
+CREATE INDEX notifications_pending_idx ON notifications (channel, created_at) WHERE sent_at IS NULL;
\ No newline at end of file
@@ -0,0 +1,13 @@
+CREATE TABLE tokens (
+    hash BYTEA PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    family BYTEA NOT NULL,
+    scope TEXT NOT NULL,
+    expiry TIMESTAMP(0) WITH TIME ZONE NOT NULL,
+    created_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    used_at TIMESTAMP(0) WITH TIME ZONE,
+    revoked_at TIMESTAMP(0) WITH TIME ZONE
+);
+
+CREATE INDEX tokens_user_id_idx ON tokens (user_id, scope);
+CREATE INDEX tokens_family_idx ON tokens (family);
\ No newline at end of file
@@ -0,0 +1,34 @@
+CREATE TABLE roles (
+    id SERIAL PRIMARY KEY,
+    name TEXT UNIQUE NOT NULL
+);
+
+CREATE TABLE permissions (
+    id SERIAL PRIMARY KEY,
+    code TEXT UNIQUE NOT NULL
+);
+
+CREATE TABLE roles_permissions (
+    role_id INTEGER NOT NULL REFERENCES roles(id) ON DELETE CASCADE,
+    permission_id INTEGER NOT NULL REFERENCES permissions(id) ON DELETE CASCADE,
+    PRIMARY KEY (role_id, permission_id)
+);
+
+CREATE TABLE users_roles (
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    role_id INTEGER NOT NULL REFERENCES roles(id) ON DELETE CASCADE,
+    PRIMARY KEY (user_id, role_id)
+);
+
+INSERT INTO roles (name) VALUES ('user'), ('support'), ('admin');
+
+INSERT INTO permissions (code) VALUES ('users:read'), ('roles:write'), ('metrics:read');
+
+INSERT INTO roles_permissions (role_id, permission_id)
+SELECT r.id, p.id FROM roles r, permissions p
+WHERE (r.name = 'support' AND p.code IN ('users:read', 'metrics:read'))
+   OR (r.name = 'admin' AND p.code IN ('users:read', 'roles:write', 'metrics:read'));
+
+INSERT INTO users_roles (user_id, role_id)
+SELECT u.id, r.id FROM users u, roles r
+WHERE r.name = 'user';
\ No newline at end of file
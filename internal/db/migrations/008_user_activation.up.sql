@@ -0,0 +1,2 @@
+ALTER TABLE users
+    ADD COLUMN activated BOOLEAN NOT NULL DEFAULT FALSE;
\ No newline at end of file
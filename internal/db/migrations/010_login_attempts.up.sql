@@ -0,0 +1,11 @@
+CREATE TABLE login_attempts (
+    id BIGSERIAL PRIMARY KEY,
+    email TEXT NOT NULL,
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    ip TEXT NOT NULL,
+    outcome TEXT NOT NULL,
+    created_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX login_attempts_email_idx ON login_attempts (email, created_at);
+CREATE INDEX login_attempts_ip_idx ON login_attempts (ip, created_at) WHERE outcome = 'invalid_credentials';
\ No newline at end of file
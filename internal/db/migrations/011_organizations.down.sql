@@ -0,0 +1,9 @@
+DROP INDEX IF EXISTS users_favorites_organization_hotel_idx;
+DROP INDEX IF EXISTS users_favorites_user_hotel_idx;
+CREATE UNIQUE INDEX IF NOT EXISTS users_favorites_user_id_hotel_id_key ON users_favorites (user_id, hotel_id);
+
+ALTER TABLE IF EXISTS users_favorites
+    DROP COLUMN IF EXISTS organization_id;
+
+DROP TABLE IF EXISTS organization_members;
+DROP TABLE IF EXISTS organizations;
\ No newline at end of file
@@ -0,0 +1,25 @@
+CREATE TABLE organizations (
+    id SERIAL PRIMARY KEY,
+    name TEXT NOT NULL,
+    created_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE organization_members (
+    organization_id INTEGER NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    role TEXT NOT NULL CHECK (role IN ('owner', 'admin', 'member')),
+    subscribed BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (organization_id, user_id)
+);
+
+CREATE INDEX organization_members_user_id_idx ON organization_members (user_id);
+
+-- Favorites with an organization are shared by its members; user_id is the
+-- member who created them.
+ALTER TABLE users_favorites
+    ADD COLUMN organization_id INTEGER REFERENCES organizations(id) ON DELETE CASCADE;
+
+ALTER TABLE users_favorites DROP CONSTRAINT users_favorites_user_id_hotel_id_key;
+CREATE UNIQUE INDEX users_favorites_user_hotel_idx ON users_favorites (user_id, hotel_id) WHERE organization_id IS NULL;
+CREATE UNIQUE INDEX users_favorites_organization_hotel_idx ON users_favorites (organization_id, hotel_id) WHERE organization_id IS NOT NULL;
\ No newline at end of file
@@ -0,0 +1,10 @@
+DROP INDEX IF EXISTS users_favorites_organization_watchlist_hotel_idx;
+DROP INDEX IF EXISTS users_favorites_user_watchlist_hotel_idx;
+CREATE UNIQUE INDEX IF NOT EXISTS users_favorites_user_hotel_idx ON users_favorites (user_id, hotel_id) WHERE organization_id IS NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS users_favorites_organization_hotel_idx ON users_favorites (organization_id, hotel_id) WHERE organization_id IS NOT NULL;
+
+ALTER TABLE IF EXISTS users_favorites
+    DROP COLUMN IF EXISTS status,
+    DROP COLUMN IF EXISTS watchlist_id;
+
+DROP TABLE IF EXISTS watchlists;
\ No newline at end of file
@@ -0,0 +1,25 @@
+-- Watchlists belong to a user, or to an organization when organization_id
+-- is set.
+CREATE TABLE watchlists (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    organization_id INTEGER REFERENCES organizations(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    created_at TIMESTAMP(0) WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    CHECK ((user_id IS NULL) <> (organization_id IS NULL))
+);
+
+CREATE UNIQUE INDEX watchlists_user_name_idx ON watchlists (user_id, lower(name)) WHERE organization_id IS NULL;
+CREATE UNIQUE INDEX watchlists_organization_name_idx ON watchlists (organization_id, lower(name)) WHERE organization_id IS NOT NULL;
+
+ALTER TABLE users_favorites
+    ADD COLUMN watchlist_id INTEGER REFERENCES watchlists(id) ON DELETE SET NULL,
+    ADD COLUMN status TEXT NOT NULL DEFAULT 'active' CHECK (status IN ('active', 'paused'));
+
+CREATE INDEX users_favorites_watchlist_id_idx ON users_favorites (watchlist_id);
+
+-- A hotel can now be watched once per watchlist instead of once per owner.
+DROP INDEX users_favorites_user_hotel_idx;
+DROP INDEX users_favorites_organization_hotel_idx;
+CREATE UNIQUE INDEX users_favorites_user_watchlist_hotel_idx ON users_favorites (user_id, COALESCE(watchlist_id, 0), hotel_id) WHERE organization_id IS NULL;
+CREATE UNIQUE INDEX users_favorites_organization_watchlist_hotel_idx ON users_favorites (organization_id, COALESCE(watchlist_id, 0), hotel_id) WHERE organization_id IS NOT NULL;
\ No newline at end of file
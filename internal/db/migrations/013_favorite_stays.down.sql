@@ -0,0 +1,11 @@
+DROP INDEX IF EXISTS users_favorites_organization_watch_idx;
+DROP INDEX IF EXISTS users_favorites_user_watch_idx;
+CREATE UNIQUE INDEX IF NOT EXISTS users_favorites_user_watchlist_hotel_idx ON users_favorites (user_id, COALESCE(watchlist_id, 0), hotel_id) WHERE organization_id IS NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS users_favorites_organization_watchlist_hotel_idx ON users_favorites (organization_id, COALESCE(watchlist_id, 0), hotel_id) WHERE organization_id IS NOT NULL;
+
+ALTER TABLE IF EXISTS users_favorites
+    DROP CONSTRAINT IF EXISTS users_favorites_stay_check,
+    DROP COLUMN IF EXISTS children,
+    DROP COLUMN IF EXISTS adults,
+    DROP COLUMN IF EXISTS check_out,
+    DROP COLUMN IF EXISTS check_in;
\ No newline at end of file
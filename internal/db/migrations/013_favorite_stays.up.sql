@@ -0,0 +1,23 @@
+-- Favorites can be priced for a given trip. Favorites without dates keep
+-- being priced for the default stay of the monitor.
+ALTER TABLE users_favorites
+    ADD COLUMN check_in DATE,
+    ADD COLUMN check_out DATE,
+    ADD COLUMN adults INTEGER NOT NULL DEFAULT 1 CHECK (adults BETWEEN 1 AND 8),
+    ADD COLUMN children INTEGER[] NOT NULL DEFAULT '{}',
+    ADD CONSTRAINT users_favorites_stay_check CHECK (
+        (check_in IS NULL AND check_out IS NULL) OR check_out > check_in
+    );
+
+-- The same hotel can be watched for several trips: only identical watches
+-- are duplicates.
+DROP INDEX users_favorites_user_watchlist_hotel_idx;
+DROP INDEX users_favorites_organization_watchlist_hotel_idx;
+CREATE UNIQUE INDEX users_favorites_user_watch_idx ON users_favorites (
+    user_id, COALESCE(watchlist_id, 0), hotel_id,
+    COALESCE(check_in, 'infinity'::date), COALESCE(check_out, 'infinity'::date), adults, children, currency
+) WHERE organization_id IS NULL;
+CREATE UNIQUE INDEX users_favorites_organization_watch_idx ON users_favorites (
+    organization_id, COALESCE(watchlist_id, 0), hotel_id,
+    COALESCE(check_in, 'infinity'::date), COALESCE(check_out, 'infinity'::date), adults, children, currency
+) WHERE organization_id IS NOT NULL;
\ No newline at end of file
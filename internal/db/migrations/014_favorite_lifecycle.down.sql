@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS users_favorites_status_idx;
+
+UPDATE users_favorites SET status = 'paused' WHERE status IN ('expired', 'fulfilled');
+
+ALTER TABLE IF EXISTS users_favorites
+    DROP CONSTRAINT IF EXISTS users_favorites_status_check,
+    ADD CONSTRAINT users_favorites_status_check CHECK (status IN ('active', 'paused')),
+    DROP COLUMN IF EXISTS end_date;
\ No newline at end of file
@@ -0,0 +1,8 @@
+-- Favorites expire once their check-in date or optional end date has
+-- passed, and are fulfilled once their target price was reached.
+ALTER TABLE users_favorites
+    ADD COLUMN end_date DATE,
+    DROP CONSTRAINT users_favorites_status_check,
+    ADD CONSTRAINT users_favorites_status_check CHECK (status IN ('active', 'paused', 'expired', 'fulfilled'));
+
+CREATE INDEX users_favorites_status_idx ON users_favorites (status);
\ No newline at end of file
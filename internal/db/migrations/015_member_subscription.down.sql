@@ -0,0 +1 @@
+ALTER TABLE IF EXISTS organization_members ALTER COLUMN subscribed SET DEFAULT TRUE;
@@ -0,0 +1,2 @@
+-- Members added by someone else must opt in to an organization's alerts.
+ALTER TABLE organization_members ALTER COLUMN subscribed SET DEFAULT FALSE;
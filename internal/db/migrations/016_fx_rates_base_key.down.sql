@@ -0,0 +1,3 @@
+ALTER TABLE IF EXISTS fx_rates
+    DROP CONSTRAINT IF EXISTS fx_rates_pkey,
+    ADD PRIMARY KEY (fetched_at, currency);
@@ -0,0 +1,4 @@
+-- Snapshots with different bases may share a timestamp.
+ALTER TABLE fx_rates
+    DROP CONSTRAINT fx_rates_pkey,
+    ADD PRIMARY KEY (fetched_at, base, currency);
@@ -0,0 +1,198 @@
+package fx
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	ErrNoRates         = errors.New("exchange rates are not loaded")
+	ErrUnknownCurrency = errors.New("unknown currency")
+
+	CurrencyRX = regexp.MustCompile("^[A-Z]{3}$")
+)
+
+// Rates is a snapshot of exchange rates: Rates[c] is the amount of currency
+// c worth one unit of Base.
+type Rates struct {
+	Base      string             `json:"base"`
+	Rates     map[string]float64 `json:"rates"`
+	FetchedAt time.Time          `json:"updated_at"`
+}
+
+// Rate returns the multiplier converting amounts in from into to, going
+// through the base currency when neither side is the base.
+func (r *Rates) Rate(from, to string) (float64, error) {
+	if from == to {
+		return 1, nil
+	}
+
+	fromRate, err := r.perBase(from)
+	if err != nil {
+		return 0, err
+	}
+
+	toRate, err := r.perBase(to)
+	if err != nil {
+		return 0, err
+	}
+
+	return toRate / fromRate, nil
+}
+
+func (r *Rates) perBase(currency string) (float64, error) {
+	if currency == r.Base {
+		return 1, nil
+	}
+
+	rate, ok := r.Rates[currency]
+	if !ok {
+		return 0, fmt.Errorf("%w: %s", ErrUnknownCurrency, currency)
+	}
+
+	return rate, nil
+}
+
+func (r *Rates) validate() error {
+	if !CurrencyRX.MatchString(r.Base) {
+		return fmt.Errorf("invalid base currency %q", r.Base)
+	}
+
+	if len(r.Rates) == 0 {
+		return errors.New("no rates")
+	}
+
+	for currency, rate := range r.Rates {
+		if !CurrencyRX.MatchString(currency) {
+			return fmt.Errorf("invalid currency %q", currency)
+		}
+		if rate <= 0 || math.IsInf(rate, 0) || math.IsNaN(rate) {
+			return fmt.Errorf("invalid rate %v for %s", rate, currency)
+		}
+	}
+
+	return nil
+}
+
+// Load reads a rates snapshot from source, which is either an http(s) URL or
+// a path to a JSON file of the form
+//
+//	{"base": "USD", "updated_at": "2026-01-02T15:04:05Z", "rates": {"EUR": 0.92}}
+//
+// When updated_at is missing the snapshot is stamped with the current time.
+func Load(ctx context.Context, client *http.Client, source string) (*Rates, error) {
+	var body []byte
+	var err error
+
+	if strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://") {
+		body, err = fetch(ctx, client, source)
+	} else {
+		body, err = os.ReadFile(source)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var rates Rates
+	if err := json.Unmarshal(body, &rates); err != nil {
+		return nil, fmt.Errorf("decoding exchange rates: %w", err)
+	}
+
+	rates.Base = strings.ToUpper(rates.Base)
+	if err := rates.validate(); err != nil {
+		return nil, fmt.Errorf("invalid exchange rates: %w", err)
+	}
+
+	if rates.FetchedAt.IsZero() {
+		rates.FetchedAt = time.Now()
+	}
+	rates.FetchedAt = rates.FetchedAt.UTC()
+
+	return &rates, nil
+}
+
+func fetch(ctx context.Context, client *http.Client, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("exchange rates source returned status %d", res.StatusCode)
+	}
+
+	return io.ReadAll(io.LimitReader(res.Body, 1<<20))
+}
+
+// Converter holds the current rates snapshot. It is safe for concurrent use
+// and the snapshot can be replaced while conversions are running.
+type Converter struct {
+	current atomic.Pointer[Rates]
+}
+
+func NewConverter() *Converter {
+	return &Converter{}
+}
+
+func (c *Converter) Set(rates *Rates) {
+	c.current.Store(rates)
+}
+
+// Current returns the current snapshot, or nil if none was loaded yet.
+func (c *Converter) Current() *Rates {
+	return c.current.Load()
+}
+
+// Conversion records how an amount was converted so it can be stored next to
+// the converted value.
+type Conversion struct {
+	Amount    float64
+	Currency  string
+	Rate      float64
+	FetchedAt *time.Time
+}
+
+// Convert converts amount from one currency into another, rounding to
+// cents. Converting a currency into itself always succeeds, even before any
+// rates are loaded.
+func (c *Converter) Convert(amount float64, from, to string) (Conversion, error) {
+	if from == to {
+		return Conversion{Amount: amount, Currency: to, Rate: 1}, nil
+	}
+
+	rates := c.Current()
+	if rates == nil {
+		return Conversion{}, ErrNoRates
+	}
+
+	rate, err := rates.Rate(from, to)
+	if err != nil {
+		return Conversion{}, err
+	}
+
+	fetchedAt := rates.FetchedAt
+
+	return Conversion{
+		Amount:    math.Round(amount*rate*100) / 100,
+		Currency:  to,
+		Rate:      rate,
+		FetchedAt: &fetchedAt,
+	}, nil
+}
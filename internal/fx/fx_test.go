@@ -0,0 +1,86 @@
+package fx
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestConverterConvert(t *testing.T) {
+	rates, err := Load(context.Background(), http.DefaultClient, "testdata/rates.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := NewConverter()
+
+	if _, err := c.Convert(100, "USD", "EUR"); !errors.Is(err, ErrNoRates) {
+		t.Errorf("err = %v before loading rates, expected ErrNoRates", err)
+	}
+	if conv, err := c.Convert(100, "USD", "USD"); err != nil || conv.Amount != 100 {
+		t.Errorf("same currency: %+v, %v", conv, err)
+	}
+
+	c.Set(rates)
+
+	tests := []struct {
+		name     string
+		amount   float64
+		from, to string
+		expected float64
+	}{
+		{"from base", 100, "USD", "EUR", 80},
+		{"to base", 80, "EUR", "USD", 100},
+		{"cross rate", 100, "EUR", "BRL", 625},
+		{"rounds to cents", 1, "BRL", "GBP", 0.1},
+		{"same currency", 42.5, "GBP", "GBP", 42.5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conv, err := c.Convert(tt.amount, tt.from, tt.to)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if conv.Amount != tt.expected {
+				t.Errorf("Convert(%v, %s, %s) = %v, expected %v", tt.amount, tt.from, tt.to, conv.Amount, tt.expected)
+			}
+		})
+	}
+
+	if _, err := c.Convert(1, "USD", "JPY"); !errors.Is(err, ErrUnknownCurrency) {
+		t.Errorf("err = %v, expected ErrUnknownCurrency", err)
+	}
+}
+
+func TestLoadFromURL(t *testing.T) {
+	body, err := os.ReadFile("testdata/rates.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(body)
+	}))
+	defer srv.Close()
+
+	rates, err := Load(context.Background(), srv.Client(), srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rates.Base != "USD" || rates.Rates["EUR"] != 0.8 || rates.FetchedAt.IsZero() {
+		t.Errorf("unexpected rates: %+v", rates)
+	}
+
+	bad := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"base": "USD", "rates": {"EUR": -1}}`))
+	}))
+	defer bad.Close()
+
+	if _, err := Load(context.Background(), bad.Client(), bad.URL); err == nil {
+		t.Error("expected error for a negative rate")
+	}
+}
@@ -0,0 +1,192 @@
+package mailer
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"html/template"
+	"mime/multipart"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+	texttemplate "text/template"
+	"time"
+)
+
+//go:embed "templates"
+var templateFS embed.FS
+
+// Mailer sends an email built from one of the embedded templates. Each
+// template defines "subject", "plainBody" and "htmlBody".
+type Mailer interface {
+	Send(recipient, templateFile string, data interface{}) error
+}
+
+// Message is a rendered email.
+type Message struct {
+	Recipient string
+	Subject   string
+	PlainBody string
+	HTMLBody  string
+}
+
+// Render executes templateFile with data.
+func Render(templateFile string, data interface{}) (*Message, error) {
+	textTmpl, err := texttemplate.New("email").ParseFS(templateFS, "templates/"+templateFile)
+	if err != nil {
+		return nil, err
+	}
+
+	subject := new(bytes.Buffer)
+	if err := textTmpl.ExecuteTemplate(subject, "subject", data); err != nil {
+		return nil, err
+	}
+
+	plainBody := new(bytes.Buffer)
+	if err := textTmpl.ExecuteTemplate(plainBody, "plainBody", data); err != nil {
+		return nil, err
+	}
+
+	htmlTmpl, err := template.New("email").ParseFS(templateFS, "templates/"+templateFile)
+	if err != nil {
+		return nil, err
+	}
+
+	htmlBody := new(bytes.Buffer)
+	if err := htmlTmpl.ExecuteTemplate(htmlBody, "htmlBody", data); err != nil {
+		return nil, err
+	}
+
+	return &Message{
+		Subject:   strings.TrimSpace(subject.String()),
+		PlainBody: plainBody.String(),
+		HTMLBody:  htmlBody.String(),
+	}, nil
+}
+
+// SMTP sends emails through an SMTP server, retrying transient failures.
+type SMTP struct {
+	addr   string
+	auth   smtp.Auth
+	sender string
+}
+
+func NewSMTP(host string, port int, username, password, sender string) *SMTP {
+	m := &SMTP{
+		addr:   host + ":" + strconv.Itoa(port),
+		sender: sender,
+	}
+
+	if username != "" {
+		m.auth = smtp.PlainAuth("", username, password, host)
+	}
+
+	return m
+}
+
+func (m *SMTP) Send(recipient, templateFile string, data interface{}) error {
+	msg, err := Render(templateFile, data)
+	if err != nil {
+		return err
+	}
+	msg.Recipient = recipient
+
+	body, err := m.build(msg)
+	if err != nil {
+		return err
+	}
+
+	for i := 1; i <= 3; i++ {
+		err = smtp.SendMail(m.addr, m.auth, m.sender, []string{recipient}, body)
+		if err == nil {
+			return nil
+		}
+
+		time.Sleep(500 * time.Millisecond)
+	}
+
+	return err
+}
+
+func (m *SMTP) build(msg *Message) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	writer := multipart.NewWriter(buf)
+
+	fmt.Fprintf(buf, "From: %s\r\n", m.sender)
+	fmt.Fprintf(buf, "To: %s\r\n", msg.Recipient)
+	fmt.Fprintf(buf, "Subject: %s\r\n", msg.Subject)
+	fmt.Fprintf(buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(buf, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(buf, "Content-Type: multipart/alternative; boundary=%s\r\n\r\n", writer.Boundary())
+
+	parts := []struct {
+		contentType string
+		body        string
+	}{
+		{"text/plain; charset=UTF-8", msg.PlainBody},
+		{"text/html; charset=UTF-8", msg.HTMLBody},
+	}
+
+	for _, part := range parts {
+		w, err := writer.CreatePart(textproto.MIMEHeader{"Content-Type": {part.contentType}})
+		if err != nil {
+			return nil, err
+		}
+		if _, err := w.Write([]byte(part.body)); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// logCapacity bounds the messages a Log mailer keeps for Sent.
+const logCapacity = 100
+
+// Log renders emails and hands them to a logging function instead of
+// sending them. It is meant for development and tests, and keeps the last
+// logCapacity messages.
+type Log struct {
+	mu   sync.Mutex
+	sent []Message
+	log  func(Message)
+}
+
+func NewLog(log func(Message)) *Log {
+	return &Log{log: log}
+}
+
+func (m *Log) Send(recipient, templateFile string, data interface{}) error {
+	msg, err := Render(templateFile, data)
+	if err != nil {
+		return err
+	}
+	msg.Recipient = recipient
+
+	m.mu.Lock()
+	m.sent = append(m.sent, *msg)
+	if len(m.sent) > logCapacity {
+		m.sent = m.sent[len(m.sent)-logCapacity:]
+	}
+	m.mu.Unlock()
+
+	if m.log != nil {
+		m.log(*msg)
+	}
+
+	return nil
+}
+
+// Sent returns the messages sent so far.
+func (m *Log) Sent() []Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return append([]Message(nil), m.sent...)
+}
@@ -0,0 +1,80 @@
+package mailer
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRenderTemplates(t *testing.T) {
+	tests := []struct {
+		template string
+		data     map[string]interface{}
+		expected string
+	}{
+		{
+			template: "user_welcome.tmpl",
+			data: map[string]interface{}{
+				"name":            "Alice <admin>",
+				"userID":          1,
+				"activationToken": "TOKEN123",
+				"expiresIn":       "3 days",
+			},
+			expected: "TOKEN123",
+		},
+		{
+			template: "price_alert.tmpl",
+			data: map[string]interface{}{
+				"name":      "Alice",
+				"hotelName": "Hotel Lisboa",
+				"message":   "Current price 90.00 EUR is lower than target 100.00 EUR",
+			},
+			expected: "90.00 EUR",
+		},
+		{
+			template: "token_password_reset.tmpl",
+			data: map[string]interface{}{
+				"name":               "Alice",
+				"passwordResetToken": "RESET123",
+				"expiresIn":          "45 minutes",
+			},
+			expected: "RESET123",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.template, func(t *testing.T) {
+			msg, err := Render(tt.template, tt.data)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if msg.Subject == "" || strings.Contains(msg.Subject, "\n") {
+				t.Errorf("invalid subject %q", msg.Subject)
+			}
+			if !strings.Contains(msg.PlainBody, tt.expected) || !strings.Contains(msg.HTMLBody, tt.expected) {
+				t.Errorf("bodies do not contain %q", tt.expected)
+			}
+			if strings.Contains(msg.HTMLBody, "<admin>") {
+				t.Error("HTML body is not escaped")
+			}
+		})
+	}
+}
+
+func TestLogMailer(t *testing.T) {
+	var logged []Message
+	m := NewLog(func(msg Message) { logged = append(logged, msg) })
+
+	err := m.Send("alice@example.com", "price_alert.tmpl", map[string]interface{}{"name": "Alice", "hotelName": "H", "message": "m"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if sent := m.Sent(); len(sent) != 1 || sent[0].Recipient != "alice@example.com" || len(logged) != 1 {
+		t.Errorf("unexpected sent messages: %+v", sent)
+	}
+
+	if err := m.Send("alice@example.com", "missing.tmpl", nil); err == nil {
+		t.Error("expected error for a missing template")
+	}
+}
@@ -0,0 +1,53 @@
+package secrets
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestCipherRoundTrip(t *testing.T) {
+	c, err := New(bytes.Repeat([]byte{7}, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	plaintext := []byte("sand_c0155ab8-xxxx")
+
+	first, err := c.Encrypt(plaintext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, _ := c.Encrypt(plaintext)
+
+	if bytes.Equal(first, second) {
+		t.Error("encrypting twice produced the same ciphertext")
+	}
+	if bytes.Contains(first, plaintext) {
+		t.Error("ciphertext contains the plaintext")
+	}
+
+	got, err := c.Decrypt(first)
+	if err != nil || !bytes.Equal(got, plaintext) {
+		t.Errorf("Decrypt() = %q, %v, expected %q", got, err, plaintext)
+	}
+
+	first[len(first)-1] ^= 1
+	if _, err := c.Decrypt(first); !errors.Is(err, ErrInvalidCiphertext) {
+		t.Errorf("tampered ciphertext: err = %v, expected ErrInvalidCiphertext", err)
+	}
+
+	other, _ := New(bytes.Repeat([]byte{8}, 32))
+	if _, err := other.Decrypt(second); !errors.Is(err, ErrInvalidCiphertext) {
+		t.Errorf("wrong key: err = %v, expected ErrInvalidCiphertext", err)
+	}
+}
+
+func TestNewRejectsShortKeys(t *testing.T) {
+	if _, err := New([]byte("too short")); err == nil {
+		t.Error("expected error for a short key")
+	}
+	if _, err := NewFromBase64("not base64!"); err == nil {
+		t.Error("expected error for invalid base64")
+	}
+}
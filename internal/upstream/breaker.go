@@ -0,0 +1,152 @@
+package upstream
+
+import (
+	"sync"
+	"time"
+)
+
+type State int8
+
+const (
+	StateClosed State = iota
+	StateOpen
+	StateHalfOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half-open"
+	default:
+		return ""
+	}
+}
+
+// Breaker is a consecutive-failure circuit breaker. After threshold failures
+// in a row it opens and rejects calls for cooldown, then lets a single probe
+// through (half-open) whose outcome closes or re-opens it.
+type Breaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu        sync.Mutex
+	state     State
+	failures  int
+	openedAt  time.Time
+	probing   bool
+	now       func() time.Time
+	listeners []func(State)
+}
+
+func NewBreaker(threshold int, cooldown time.Duration) *Breaker {
+	return &Breaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+		now:       time.Now,
+	}
+}
+
+// Allow reports whether a call may proceed.
+func (b *Breaker) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case StateOpen:
+		if b.now().Sub(b.openedAt) < b.cooldown {
+			return false
+		}
+		b.setState(StateHalfOpen)
+		b.probing = true
+		return true
+	case StateHalfOpen:
+		if b.probing {
+			return false
+		}
+		b.probing = true
+		return true
+	default:
+		return true
+	}
+}
+
+func (b *Breaker) Success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures = 0
+	b.probing = false
+	b.setState(StateClosed)
+}
+
+func (b *Breaker) Failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	b.probing = false
+
+	if b.state == StateHalfOpen || b.failures >= b.threshold {
+		b.openedAt = b.now()
+		b.setState(StateOpen)
+	}
+}
+
+// Abandon releases a call let through by Allow whose outcome is unknown,
+// because the caller gave up. A half-open breaker stays half-open so the next
+// call can probe.
+func (b *Breaker) Abandon() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+}
+
+// State returns the current state. An open breaker whose cooldown elapsed is
+// reported as half-open since the next call will be let through.
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == StateOpen && b.now().Sub(b.openedAt) >= b.cooldown {
+		return StateHalfOpen
+	}
+
+	return b.state
+}
+
+// RetryAfter returns how long until an open breaker lets a probe through.
+func (b *Breaker) RetryAfter() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state != StateOpen {
+		return 0
+	}
+
+	return max(0, b.cooldown-b.now().Sub(b.openedAt))
+}
+
+// OnStateChange registers fn to be called, with the breaker lock held, on
+// every state transition.
+func (b *Breaker) OnStateChange(fn func(State)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.listeners = append(b.listeners, fn)
+}
+
+func (b *Breaker) setState(state State) {
+	if b.state == state {
+		return
+	}
+
+	b.state = state
+	for _, fn := range b.listeners {
+		fn(state)
+	}
+}
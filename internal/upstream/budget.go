@@ -0,0 +1,273 @@
+package upstream
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+var (
+	ErrQuotaExhausted = errors.New("upstream daily quota exhausted")
+)
+
+type Priority int8
+
+const (
+	// PriorityInteractive is used for calls made on behalf of a user request.
+	PriorityInteractive Priority = iota
+	// PriorityBackground is used for calls made by background jobs such as
+	// the price monitor. They yield to interactive calls.
+	PriorityBackground
+)
+
+func (p Priority) String() string {
+	switch p {
+	case PriorityInteractive:
+		return "interactive"
+	case PriorityBackground:
+		return "background"
+	default:
+		return ""
+	}
+}
+
+type priorityKey struct{}
+
+// WithPriority returns a copy of ctx carrying p. Calls made with a context
+// that has no priority are treated as interactive.
+func WithPriority(ctx context.Context, p Priority) context.Context {
+	return context.WithValue(ctx, priorityKey{}, p)
+}
+
+func PriorityFrom(ctx context.Context) Priority {
+	if p, ok := ctx.Value(priorityKey{}).(Priority); ok {
+		return p
+	}
+	return PriorityInteractive
+}
+
+type BudgetConfig struct {
+	// RPS and Burst size the token bucket shared by every outbound call. A
+	// non-positive RPS disables rate limiting.
+	RPS   float64
+	Burst int
+	// DailyQuota is the number of calls allowed per UTC day. Zero disables
+	// quota tracking.
+	DailyQuota int
+	// SoftLimit is the fraction of the daily quota after which background
+	// calls are spread over the rest of the day.
+	SoftLimit float64
+	// InteractiveReserve is the fraction of the daily quota background calls
+	// may never use.
+	InteractiveReserve float64
+}
+
+// Budget rations outbound calls. Every call takes a token from a shared
+// bucket, but background calls leave half of the burst for interactive ones,
+// and once the daily quota nears its limit background calls are slowed down
+// and finally refused before interactive traffic is affected.
+type Budget struct {
+	limiter       *rate.Limiter
+	reserveTokens float64
+	interval      time.Duration
+
+	quota   int
+	soft    float64
+	reserve float64
+
+	mu   sync.Mutex
+	day  string
+	used int
+	now  func() time.Time
+}
+
+// NewBudget returns a Budget for cfg, or an error if cfg is inconsistent.
+func NewBudget(cfg BudgetConfig) (*Budget, error) {
+	switch {
+	case cfg.RPS > 0 && cfg.Burst < 1:
+		return nil, errors.New("upstream budget: burst must be at least 1 when rps is set")
+	case cfg.DailyQuota < 0:
+		return nil, errors.New("upstream budget: daily quota must not be negative")
+	case cfg.SoftLimit < 0 || cfg.SoftLimit > 1:
+		return nil, errors.New("upstream budget: soft limit must be between 0 and 1")
+	case cfg.InteractiveReserve < 0 || cfg.InteractiveReserve > 1:
+		return nil, errors.New("upstream budget: interactive reserve must be between 0 and 1")
+	}
+
+	b := &Budget{
+		limiter:  rate.NewLimiter(rate.Inf, 0),
+		quota:    cfg.DailyQuota,
+		soft:     cfg.SoftLimit,
+		reserve:  cfg.InteractiveReserve,
+		interval: 100 * time.Millisecond,
+		now:      time.Now,
+	}
+
+	if cfg.RPS > 0 {
+		b.limiter = rate.NewLimiter(rate.Limit(cfg.RPS), cfg.Burst)
+		// Background calls need reserveTokens+1 tokens, which must fit in
+		// the bucket or they would wait forever.
+		b.reserveTokens = min(float64(cfg.Burst)/2, float64(cfg.Burst-1))
+		b.interval = time.Duration(float64(time.Second) / cfg.RPS)
+	}
+
+	return b, nil
+}
+
+// Wait blocks until a call with priority p may be sent. The call is counted
+// against the daily quota before waiting, so concurrent callers cannot all
+// pass the quota check, and given back if the wait fails.
+func (b *Budget) Wait(ctx context.Context, p Priority) error {
+	day, delay, err := b.take(p)
+	if err != nil {
+		return err
+	}
+
+	if delay > 0 {
+		if err := sleep(ctx, delay); err != nil {
+			b.release(day)
+			return err
+		}
+	}
+
+	if p == PriorityBackground {
+		err = b.waitBackground(ctx)
+	} else {
+		err = b.limiter.Wait(ctx)
+	}
+	if err != nil {
+		b.release(day)
+		return err
+	}
+
+	return nil
+}
+
+// BackgroundExhausted reports whether background calls are currently refused
+// because of the daily quota.
+func (b *Budget) BackgroundExhausted() bool {
+	_, err := b.quotaDelay(PriorityBackground)
+	return errors.Is(err, ErrQuotaExhausted)
+}
+
+// Usage returns the calls made so far today and the daily quota.
+func (b *Budget) Usage() (used, quota int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.rollover()
+	return b.used, b.quota
+}
+
+func (b *Budget) waitBackground(ctx context.Context) error {
+	if b.limiter.Limit() == rate.Inf {
+		return nil
+	}
+
+	for {
+		if b.limiter.Tokens() >= b.reserveTokens+1 && b.limiter.Allow() {
+			return nil
+		}
+
+		if err := sleep(ctx, b.interval); err != nil {
+			return err
+		}
+	}
+}
+
+// take records a call with priority p against today's quota and returns the
+// day it was recorded on and how long the call should wait before going out.
+func (b *Budget) take(p Priority) (string, time.Duration, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.rollover()
+
+	delay, err := b.delay(p)
+	if err != nil {
+		return "", 0, err
+	}
+
+	b.used++
+
+	return b.day, delay, nil
+}
+
+// release gives back a call taken on day that was never sent.
+func (b *Budget) release(day string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.rollover()
+
+	if b.day == day && b.used > 0 {
+		b.used--
+	}
+}
+
+// quotaDelay returns how long a call with priority p should wait before
+// going out, or ErrQuotaExhausted if it must not go out today.
+func (b *Budget) quotaDelay(p Priority) (time.Duration, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.rollover()
+
+	return b.delay(p)
+}
+
+// delay implements quotaDelay. It must be called with b.mu held.
+func (b *Budget) delay(p Priority) (time.Duration, error) {
+	if b.quota <= 0 {
+		return 0, nil
+	}
+
+	if b.used >= b.quota {
+		return 0, ErrQuotaExhausted
+	}
+
+	if p != PriorityBackground {
+		return 0, nil
+	}
+
+	backgroundLimit := int(float64(b.quota) * (1 - b.reserve))
+	if b.used >= backgroundLimit {
+		return 0, ErrQuotaExhausted
+	}
+
+	if float64(b.used) < float64(b.quota)*b.soft {
+		return 0, nil
+	}
+
+	now := b.now().UTC()
+	midnight := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.UTC)
+
+	delay := midnight.Sub(now) / time.Duration(backgroundLimit-b.used)
+
+	return min(delay, 5*time.Minute), nil
+}
+
+// rollover resets the counter when the UTC day changes. It must be called
+// with b.mu held.
+func (b *Budget) rollover() {
+	day := b.now().UTC().Format("2006-01-02")
+	if day != b.day {
+		b.day = day
+		b.used = 0
+	}
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,188 @@
+package upstream
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newTestBudget(t *testing.T, cfg BudgetConfig) *Budget {
+	t.Helper()
+
+	b, err := NewBudget(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestNewBudgetValidatesConfig(t *testing.T) {
+	tests := []struct {
+		name  string
+		cfg   BudgetConfig
+		valid bool
+	}{
+		{name: "unlimited", cfg: BudgetConfig{}, valid: true},
+		{name: "burst of one", cfg: BudgetConfig{RPS: 1, Burst: 1}, valid: true},
+		{name: "rps without burst", cfg: BudgetConfig{RPS: 1}, valid: false},
+		{name: "negative quota", cfg: BudgetConfig{DailyQuota: -1}, valid: false},
+		{name: "soft limit above one", cfg: BudgetConfig{DailyQuota: 10, SoftLimit: 1.5}, valid: false},
+		{name: "negative reserve", cfg: BudgetConfig{DailyQuota: 10, InteractiveReserve: -0.1}, valid: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewBudget(tt.cfg)
+			if (err == nil) != tt.valid {
+				t.Errorf("NewBudget(%+v) err = %v, expected valid = %t", tt.cfg, err, tt.valid)
+			}
+		})
+	}
+}
+
+func TestBudgetDailyQuota(t *testing.T) {
+	now := time.Date(2030, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	b := newTestBudget(t, BudgetConfig{DailyQuota: 10, SoftLimit: 0.5, InteractiveReserve: 0.2})
+	b.now = func() time.Time { return now }
+
+	ctx := context.Background()
+
+	for i := 0; i < 5; i++ {
+		delay, err := b.quotaDelay(PriorityBackground)
+		if err != nil || delay != 0 {
+			t.Fatalf("call %d below soft limit: delay = %v, err = %v", i, delay, err)
+		}
+		b.Wait(ctx, PriorityBackground)
+	}
+
+	// 5 of 8 background calls used: the remaining 3 are spread until
+	// midnight, capped at five minutes each.
+	delay, err := b.quotaDelay(PriorityBackground)
+	if err != nil || delay != 5*time.Minute {
+		t.Errorf("above soft limit: delay = %v, err = %v, expected 5m", delay, err)
+	}
+
+	b.mu.Lock()
+	b.used = 8
+	b.mu.Unlock()
+
+	if !b.BackgroundExhausted() {
+		t.Error("background calls allowed inside the interactive reserve")
+	}
+
+	if err := b.Wait(ctx, PriorityInteractive); err != nil {
+		t.Errorf("interactive call refused inside its reserve: %v", err)
+	}
+	b.Wait(ctx, PriorityInteractive)
+
+	if err := b.Wait(ctx, PriorityInteractive); !errors.Is(err, ErrQuotaExhausted) {
+		t.Errorf("err = %v, expected ErrQuotaExhausted once the quota is used", err)
+	}
+
+	now = now.Add(12 * time.Hour)
+
+	if used, _ := b.Usage(); used != 0 {
+		t.Errorf("used = %d after midnight, expected 0", used)
+	}
+	if b.BackgroundExhausted() {
+		t.Error("quota not reset after midnight")
+	}
+}
+
+func TestBudgetBackgroundYieldsTokens(t *testing.T) {
+	b := newTestBudget(t, BudgetConfig{RPS: 1, Burst: 4})
+	b.interval = time.Millisecond
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	// Background calls stop once only half of the burst is left.
+	for i := 0; i < 2; i++ {
+		if err := b.Wait(ctx, PriorityBackground); err != nil {
+			t.Fatalf("background call %d: %v", i, err)
+		}
+	}
+	if err := b.Wait(ctx, PriorityBackground); err == nil {
+		t.Fatal("background call consumed the interactive share of the burst")
+	}
+
+	interactiveCtx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	for i := 0; i < 2; i++ {
+		if err := b.Wait(interactiveCtx, PriorityInteractive); err != nil {
+			t.Fatalf("interactive call %d: %v", i, err)
+		}
+	}
+}
+
+func TestBudgetBackgroundWithBurstOfOne(t *testing.T) {
+	b := newTestBudget(t, BudgetConfig{RPS: 1, Burst: 1})
+	b.interval = time.Millisecond
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if err := b.Wait(ctx, PriorityBackground); err != nil {
+		t.Fatalf("background call with a burst of one never went out: %v", err)
+	}
+}
+
+func TestBudgetQuotaUnderConcurrency(t *testing.T) {
+	b := newTestBudget(t, BudgetConfig{RPS: 1000, Burst: 1, DailyQuota: 5})
+
+	var sent atomic.Int32
+	var wg sync.WaitGroup
+
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if b.Wait(context.Background(), PriorityInteractive) == nil {
+				sent.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if sent.Load() != 5 {
+		t.Errorf("%d calls sent, expected the daily quota of 5", sent.Load())
+	}
+	if used, _ := b.Usage(); used != 5 {
+		t.Errorf("used = %d, expected 5", used)
+	}
+}
+
+func TestBudgetReleasesFailedWaits(t *testing.T) {
+	b := newTestBudget(t, BudgetConfig{RPS: 1, Burst: 1, DailyQuota: 10})
+
+	if err := b.Wait(context.Background(), PriorityInteractive); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if err := b.Wait(ctx, PriorityInteractive); err == nil {
+		t.Fatal("call went out without a token")
+	}
+
+	if used, _ := b.Usage(); used != 1 {
+		t.Errorf("used = %d, expected the failed wait to be given back", used)
+	}
+}
+
+func TestPriorityFrom(t *testing.T) {
+	if p := PriorityFrom(context.Background()); p != PriorityInteractive {
+		t.Errorf("default priority = %s, expected interactive", p)
+	}
+
+	ctx := WithPriority(context.Background(), PriorityBackground)
+	if p := PriorityFrom(ctx); p != PriorityBackground {
+		t.Errorf("priority = %s, expected background", p)
+	}
+}
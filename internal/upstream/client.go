@@ -0,0 +1,216 @@
+package upstream
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+var (
+	ErrCircuitOpen = errors.New("upstream circuit breaker is open")
+)
+
+type Config struct {
+	Timeout          time.Duration
+	MaxRetries       int
+	BaseBackoff      time.Duration
+	MaxBackoff       time.Duration
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+	Budget           BudgetConfig
+}
+
+// Client wraps an http.Client with a per-attempt timeout, retries with
+// jittered exponential backoff on 429/5xx and transport errors, a circuit
+// breaker and a call budget shared by every call.
+type Client struct {
+	HTTP    *http.Client
+	Breaker *Breaker
+	Budget  *Budget
+
+	timeout     time.Duration
+	maxRetries  int
+	baseBackoff time.Duration
+	maxBackoff  time.Duration
+}
+
+func New(cfg Config) (*Client, error) {
+	budget, err := NewBudget(cfg.Budget)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Client{
+		HTTP:        &http.Client{},
+		Breaker:     NewBreaker(cfg.BreakerThreshold, cfg.BreakerCooldown),
+		Budget:      budget,
+		timeout:     cfg.Timeout,
+		maxRetries:  cfg.MaxRetries,
+		baseBackoff: cfg.BaseBackoff,
+		maxBackoff:  cfg.MaxBackoff,
+	}, nil
+}
+
+// Do sends req, retrying transient failures. Requests with a body must be
+// replayable, which http.NewRequest guarantees for bytes and strings readers.
+// When the breaker is open Do fails fast with ErrCircuitOpen. A response
+// whose Retry-After cannot be waited out is returned as is. Every attempt,
+// retries included, is charged to the budget at the priority carried by the
+// request context.
+func (c *Client) Do(req *http.Request) (*http.Response, error) {
+	var lastErr error
+
+	for attempt := 0; attempt <= c.maxRetries; attempt++ {
+		// Checking the state first avoids charging the budget for a call
+		// the breaker would reject anyway.
+		if c.Breaker.State() == StateOpen {
+			return nil, circuitOpenError(lastErr)
+		}
+
+		if err := c.Budget.Wait(req.Context(), PriorityFrom(req.Context())); err != nil {
+			return nil, err
+		}
+
+		if !c.Breaker.Allow() {
+			return nil, circuitOpenError(lastErr)
+		}
+
+		res, err := c.attempt(req)
+
+		switch {
+		case err != nil:
+			// A caller that gave up says nothing about the upstream, so
+			// it must not count towards opening the breaker.
+			if req.Context().Err() != nil {
+				c.Breaker.Abandon()
+				return nil, err
+			}
+			c.Breaker.Failure()
+			lastErr = err
+		case res.StatusCode >= 500:
+			c.Breaker.Failure()
+			lastErr = fmt.Errorf("upstream returned status %d", res.StatusCode)
+		case res.StatusCode == http.StatusTooManyRequests:
+			c.Breaker.Success()
+			lastErr = fmt.Errorf("upstream returned status %d", res.StatusCode)
+		default:
+			c.Breaker.Success()
+			return res, nil
+		}
+
+		if attempt == c.maxRetries {
+			if res != nil {
+				return res, nil
+			}
+			break
+		}
+
+		wait := c.backoff(attempt)
+		if res != nil {
+			if retryAfter, ok := parseRetryAfter(res.Header.Get("Retry-After"), time.Now()); ok {
+				if !c.canWait(req.Context(), retryAfter) {
+					return res, nil
+				}
+				wait = retryAfter
+			}
+			io.Copy(io.Discard, res.Body)
+			res.Body.Close()
+		}
+
+		if err := sleep(req.Context(), wait); err != nil {
+			return nil, err
+		}
+	}
+
+	return nil, lastErr
+}
+
+// canWait reports whether a Retry-After delay can be honoured in full: it
+// must end before the context deadline or, without a deadline, be no longer
+// than the maximum backoff. Retrying any earlier would only be refused again.
+func (c *Client) canWait(ctx context.Context, d time.Duration) bool {
+	if deadline, ok := ctx.Deadline(); ok {
+		return time.Until(deadline) > d
+	}
+	return d <= c.maxBackoff
+}
+
+func circuitOpenError(lastErr error) error {
+	if lastErr != nil {
+		return fmt.Errorf("%w (last error: %v)", ErrCircuitOpen, lastErr)
+	}
+	return ErrCircuitOpen
+}
+
+func (c *Client) attempt(req *http.Request) (*http.Response, error) {
+	ctx, cancel := context.WithTimeout(req.Context(), c.timeout)
+
+	attemptReq := req.Clone(ctx)
+	if req.Body != nil && req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+		attemptReq.Body = body
+	}
+
+	res, err := c.HTTP.Do(attemptReq)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	res.Body = &cancelOnClose{ReadCloser: res.Body, cancel: cancel}
+
+	return res, nil
+}
+
+// backoff returns a full-jitter exponential delay for the given attempt.
+func (c *Client) backoff(attempt int) time.Duration {
+	ceiling := c.baseBackoff << attempt
+	if ceiling <= 0 || ceiling > c.maxBackoff {
+		ceiling = c.maxBackoff
+	}
+
+	return time.Duration(rand.Int63n(int64(ceiling) + 1))
+}
+
+// parseRetryAfter understands both forms of the Retry-After header: a number
+// of seconds or an HTTP date.
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		return max(0, date.Sub(now)), true
+	}
+
+	return 0, false
+}
+
+// cancelOnClose keeps the per-attempt context alive until the caller is done
+// reading the response body.
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c *cancelOnClose) Close() error {
+	err := c.ReadCloser.Close()
+	c.cancel()
+	return err
+}
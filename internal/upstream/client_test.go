@@ -0,0 +1,300 @@
+package upstream
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newTestClient(retries, threshold int) *Client {
+	client, err := New(Config{
+		Timeout:          time.Second,
+		MaxRetries:       retries,
+		BaseBackoff:      time.Millisecond,
+		MaxBackoff:       10 * time.Millisecond,
+		BreakerThreshold: threshold,
+		BreakerCooldown:  time.Minute,
+	})
+	if err != nil {
+		panic(err)
+	}
+	return client
+}
+
+func TestClientRetriesTransientFailures(t *testing.T) {
+	var calls int32
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if string(body) != "payload" {
+			t.Errorf("attempt %d received body %q", calls, body)
+		}
+
+		switch atomic.AddInt32(&calls, 1) {
+		case 1:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case 2:
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+		default:
+			w.Write([]byte("ok"))
+		}
+	}))
+	defer srv.Close()
+
+	client := newTestClient(3, 5)
+
+	req, _ := http.NewRequest(http.MethodPost, srv.URL, strings.NewReader("payload"))
+	res, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		t.Errorf("status = %d, expected %d", res.StatusCode, http.StatusOK)
+	}
+	if calls != 3 {
+		t.Errorf("calls = %d, expected 3", calls)
+	}
+	if client.Breaker.State() != StateClosed {
+		t.Errorf("breaker state = %s, expected closed", client.Breaker.State())
+	}
+}
+
+func TestClientDoesNotRetryClientErrors(t *testing.T) {
+	var calls int32
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer srv.Close()
+
+	client := newTestClient(3, 5)
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+	res, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+
+	if res.StatusCode != http.StatusBadRequest || calls != 1 {
+		t.Errorf("status = %d after %d calls, expected 400 after 1 call", res.StatusCode, calls)
+	}
+}
+
+func TestClientOpensCircuit(t *testing.T) {
+	var calls int32
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	client := newTestClient(5, 3)
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+	_, err := client.Do(req)
+	if !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("err = %v, expected ErrCircuitOpen", err)
+	}
+	if calls != 3 {
+		t.Errorf("calls = %d, expected 3", calls)
+	}
+
+	_, err = client.Do(req)
+	if !errors.Is(err, ErrCircuitOpen) || calls != 3 {
+		t.Errorf("open breaker let a call through: err = %v, calls = %d", err, calls)
+	}
+}
+
+func TestClientIgnoresCallerCancellation(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+
+	client := newTestClient(0, 1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+	_, err := client.Do(req)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v, expected context.DeadlineExceeded", err)
+	}
+	if client.Breaker.State() != StateClosed {
+		t.Errorf("caller deadline left breaker %s, expected closed", client.Breaker.State())
+	}
+}
+
+func TestClientReleasesCancelledProbe(t *testing.T) {
+	var slow atomic.Bool
+	slow.Store(true)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if slow.Load() {
+			<-r.Context().Done()
+		}
+	}))
+	defer srv.Close()
+
+	client := newTestClient(0, 1)
+
+	now := time.Now()
+	client.Breaker.now = func() time.Time { return now }
+	client.Breaker.Failure()
+	now = now.Add(time.Minute)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+	if _, err := client.Do(req); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v, expected context.DeadlineExceeded", err)
+	}
+	if client.Breaker.State() != StateHalfOpen {
+		t.Fatalf("cancelled probe left breaker %s, expected half-open", client.Breaker.State())
+	}
+
+	slow.Store(false)
+
+	req, _ = http.NewRequest(http.MethodGet, srv.URL, nil)
+	res, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("next probe refused: %v", err)
+	}
+	res.Body.Close()
+
+	if client.Breaker.State() != StateClosed {
+		t.Errorf("successful probe left breaker %s, expected closed", client.Breaker.State())
+	}
+}
+
+func TestClientRetryAfter(t *testing.T) {
+	tests := []struct {
+		name       string
+		retryAfter string
+		deadline   time.Duration
+		calls      int32
+		status     int
+	}{
+		{name: "fits the deadline", retryAfter: "1", deadline: 5 * time.Second, calls: 2, status: http.StatusOK},
+		{name: "exceeds the deadline", retryAfter: "10", deadline: 5 * time.Second, calls: 1, status: http.StatusTooManyRequests},
+		{name: "exceeds max backoff without deadline", retryAfter: "1", calls: 1, status: http.StatusTooManyRequests},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls int32
+			var first time.Time
+
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if atomic.AddInt32(&calls, 1) == 1 {
+					first = time.Now()
+					w.Header().Set("Retry-After", tt.retryAfter)
+					w.WriteHeader(http.StatusTooManyRequests)
+					return
+				}
+				if elapsed := time.Since(first); elapsed < time.Second {
+					t.Errorf("retried after %v, expected the full Retry-After", elapsed)
+				}
+			}))
+			defer srv.Close()
+
+			client := newTestClient(3, 5)
+
+			ctx := context.Background()
+			if tt.deadline > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tt.deadline)
+				defer cancel()
+			}
+
+			req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+			res, err := client.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			res.Body.Close()
+
+			if res.StatusCode != tt.status || calls != tt.calls {
+				t.Errorf("status = %d after %d calls, expected %d after %d", res.StatusCode, calls, tt.status, tt.calls)
+			}
+		})
+	}
+}
+
+func TestBreakerHalfOpen(t *testing.T) {
+	now := time.Now()
+
+	b := NewBreaker(2, time.Minute)
+	b.now = func() time.Time { return now }
+
+	b.Failure()
+	b.Failure()
+
+	if b.Allow() {
+		t.Fatal("open breaker allowed a call")
+	}
+
+	now = now.Add(time.Minute)
+
+	if !b.Allow() {
+		t.Fatal("breaker did not allow a probe after cooldown")
+	}
+	if b.Allow() {
+		t.Fatal("half-open breaker allowed a second concurrent probe")
+	}
+
+	b.Failure()
+	if b.State() != StateOpen {
+		t.Fatalf("failed probe left breaker %s, expected open", b.State())
+	}
+
+	now = now.Add(time.Minute)
+	b.Allow()
+	b.Success()
+
+	if b.State() != StateClosed {
+		t.Errorf("successful probe left breaker %s, expected closed", b.State())
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2030, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		value    string
+		expected time.Duration
+		ok       bool
+	}{
+		{name: "seconds", value: "7", expected: 7 * time.Second, ok: true},
+		{name: "http date", value: "Tue, 01 Jan 2030 12:00:30 GMT", expected: 30 * time.Second, ok: true},
+		{name: "date in the past", value: "Tue, 01 Jan 2030 11:00:00 GMT", expected: 0, ok: true},
+		{name: "empty", value: "", ok: false},
+		{name: "negative", value: "-1", ok: false},
+		{name: "garbage", value: "soon", ok: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseRetryAfter(tt.value, now)
+
+			if ok != tt.ok || got != tt.expected {
+				t.Errorf("parseRetryAfter(%q) = %v, %v, expected %v, %v", tt.value, got, ok, tt.expected, tt.ok)
+			}
+		})
+	}
+}